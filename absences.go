@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+var supportPalOperatorAbsentDesc = prometheus.NewDesc("supportpal_operator_absent",
+	"Whether an operator is absent according to the absences file, 1 or 0", []string{"operator"}, nil)
+
+// operatorAbsence is a period an operator is out of office, e.g. on vacation
+type operatorAbsence struct {
+	// Operator is the name of the operator as shown in SupportPal
+	Operator string `yaml:"operator"`
+	// From and To are RFC 3339 times or dates, To includes the whole day
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	start time.Time
+	end   time.Time
+}
+
+// absencesFile is the file format of --operators.absences-file
+type absencesFile struct {
+	Absences []*operatorAbsence `yaml:"absences"`
+}
+
+// loadAbsences reads and validates an absences file
+func loadAbsences(path string) ([]*operatorAbsence, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f absencesFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, a := range f.Absences {
+		if a.Operator == "" {
+			return nil, fmt.Errorf("%s: absence %d has no operator", path, i+1)
+		}
+
+		if a.start, err = parseTime(a.From); err != nil {
+			return nil, fmt.Errorf("%s: absence %d of %s: %w", path, i+1, a.Operator, err)
+		}
+		if a.end, err = parseTime(a.To); err != nil {
+			return nil, fmt.Errorf("%s: absence %d of %s: %w", path, i+1, a.Operator, err)
+		}
+		if _, err := time.Parse("2006-01-02", a.To); err == nil {
+			a.end = a.end.AddDate(0, 0, 1)
+		}
+
+		if !a.end.After(a.start) {
+			return nil, fmt.Errorf("%s: absence %d of %s: to must not be before from", path, i+1, a.Operator)
+		}
+	}
+
+	return f.Absences, nil
+}
+
+// operatorAbsent reports whether the operator is absent at t
+func operatorAbsent(operator string, t time.Time) bool {
+	for _, a := range config.Absences {
+		if strings.EqualFold(operatorName(a.Operator), operator) && !t.Before(a.start) && t.Before(a.end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectAbsences sends whether every operator of the absences file and
+// every enabled operator known from the operator groups is absent
+func collectAbsences(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if len(config.Absences) == 0 {
+		return
+	}
+
+	var operators []string
+	for _, a := range config.Absences {
+		operators = append(operators, operatorName(a.Operator))
+	}
+	for operator := range s.OperatorGroups {
+		operators = append(operators, operator)
+	}
+	slices.Sort(operators)
+
+	for _, operator := range slices.Compact(operators) {
+		var absent float64
+		if operatorAbsent(operator, now) {
+			absent = 1
+		}
+		ch <- prometheus.MustNewConstMetric(supportPalOperatorAbsentDesc, prometheus.GaugeValue, absent, operator)
+	}
+}
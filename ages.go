@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectAges sends the age, business age, SLA ages, waiting time and overdue
+// state of every open ticket in s, and the age of the oldest open ticket and the
+// number of overdue tickets per department
+func collectAges(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	ageDesc := newSeriesDesc(durationName("supportpal_ticket_age"),
+		durationHelp("Time since an open ticket was created"))
+	waitingDesc := newSeriesDesc(durationName("supportpal_ticket_waiting"),
+		durationHelp("Time since the last operator response to an open ticket, or since creation if nobody responded yet"))
+	businessAgeDesc := newSeriesDesc(durationName("supportpal_ticket_business_age"),
+		durationHelp("Working time since an open ticket was created, according to the business hours of its department"))
+	slaAgeDesc := newSeriesDesc(durationName("supportpal_ticket_sla_age"),
+		durationHelp("Time since an open ticket was created without the time it waited on the customer"))
+	slaBusinessAgeDesc := newSeriesDesc(durationName("supportpal_ticket_sla_business_age"),
+		durationHelp("Working time since an open ticket was created without the working time it waited on the customer"))
+	overdueDesc := newSeriesDesc("supportpal_ticket_overdue",
+		"Whether an open ticket with a due time is past it, 1 or 0")
+	oldestDesc := prometheus.NewDesc(durationName("supportpal_oldest_open_ticket_age"),
+		durationHelp("Age of the oldest open ticket"), []string{"department"}, nil)
+	overdueTicketsDesc := prometheus.NewDesc("supportpal_overdue_tickets",
+		"Number of open tickets past their due time", []string{"department"}, nil)
+
+	oldest := make(map[string]int64)
+	overdueTickets := make(labelCounts)
+
+	for _, series := range s.Series {
+		if !series.open() {
+			continue
+		}
+
+		age := now.Unix() - series.Created
+		if age > oldest[series.Department] {
+			oldest[series.Department] = age
+		}
+
+		var overdue float64
+		if series.Due != 0 && series.Due < now.Unix() {
+			overdue = 1
+		}
+		overdueTickets.add(overdue, series.Department)
+
+		if series.Hidden {
+			continue
+		}
+
+		if series.Due != 0 {
+			ch <- overdueDesc.metric(s, series, prometheus.GaugeValue, overdue)
+		}
+
+		ch <- ageDesc.metric(s, series, prometheus.GaugeValue, durationValue(time.Duration(age)*time.Second))
+
+		business := businessCalendarOf(series.DepartmentID)
+		var wait, businessWait time.Duration
+		if config.SLAPause {
+			wait, businessWait = customerWait(series, business, now)
+			ch <- slaAgeDesc.metric(s, series, prometheus.GaugeValue,
+				durationValue(max(time.Duration(age)*time.Second-wait, 0)))
+		}
+
+		if business != nil && series.BusinessAgeAt != 0 {
+			// only the working time since the ticket was built is computed per scrape
+			businessAge := time.Duration(series.BusinessAge)*time.Second +
+				business.duration(time.Unix(series.BusinessAgeAt, 0), now)
+			ch <- businessAgeDesc.metric(s, series, prometheus.GaugeValue, durationValue(businessAge))
+
+			if config.SLAPause {
+				ch <- slaBusinessAgeDesc.metric(s, series, prometheus.GaugeValue,
+					durationValue(max(businessAge-businessWait, 0)))
+			}
+		}
+
+		if series.MessagesFetched {
+			since := series.Created
+			if series.LastResponse > since {
+				since = series.LastResponse
+			}
+
+			ch <- waitingDesc.metric(s, series, prometheus.GaugeValue,
+				durationValue(time.Duration(now.Unix()-since)*time.Second))
+		}
+	}
+
+	for department, age := range oldest {
+		ch <- prometheus.MustNewConstMetric(oldestDesc, prometheus.GaugeValue,
+			durationValue(time.Duration(age)*time.Second), department)
+	}
+	overdueTickets.emit(ch, overdueTicketsDesc, prometheus.GaugeValue)
+}
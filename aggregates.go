@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelCounts accumulates values by label values
+type labelCounts map[string]float64
+
+// add adds v to the count of the given label values
+func (c labelCounts) add(v float64, labelValues ...string) {
+	c[strings.Join(labelValues, "\xff")] += v
+}
+
+// emit sends one metric of the given type per accumulated label set
+func (c labelCounts) emit(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType) {
+	for key, v := range c {
+		ch <- prometheus.MustNewConstMetric(desc, valueType, v, strings.Split(key, "\xff")...)
+	}
+}
+
+var supportPalTicketsByTagDesc = prometheus.NewDesc("supportpal_tickets_by_tag",
+	"Number of collected tickets carrying a tag", []string{"tag"}, nil)
+
+var supportPalTicketsByChannelDesc = prometheus.NewDesc("supportpal_tickets_by_channel",
+	"Number of collected tickets by the channel they were submitted through", []string{"channel"}, nil)
+
+var supportPalTicketsWaitingDesc = prometheus.NewDesc("supportpal_tickets_waiting",
+	"Number of open tickets waiting on an operator or on the customer, by status and last replier", []string{"on"}, nil)
+
+var supportPalUnassignedTicketsDesc = prometheus.NewDesc("supportpal_unassigned_tickets",
+	"Number of open tickets no operator is assigned to", []string{"department", "priority"}, nil)
+
+// collectAggregates sends the metrics aggregated over all tickets of s
+func collectAggregates(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	byTag := make(labelCounts)
+	byChannel := make(labelCounts)
+	unassigned := make(labelCounts)
+	waiting := labelCounts{"operator": 0, "customer": 0}
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		byChannel.add(1, series.Channel)
+
+		if series.open() {
+			var v float64
+			if len(series.Assigned) == 0 {
+				v = 1
+			}
+			unassigned.add(v, series.Department, series.Priority)
+
+			if series.WaitingOnCustomer {
+				waiting.add(1, "customer")
+			} else {
+				waiting.add(1, "operator")
+			}
+		}
+
+		for _, tag := range series.Tags {
+			byTag.add(1, tag)
+		}
+	}
+
+	byTag.emit(ch, supportPalTicketsByTagDesc, prometheus.GaugeValue)
+	byChannel.emit(ch, supportPalTicketsByChannelDesc, prometheus.GaugeValue)
+	unassigned.emit(ch, supportPalUnassignedTicketsDesc, prometheus.GaugeValue)
+	waiting.emit(ch, supportPalTicketsWaitingDesc, prometheus.GaugeValue)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalAlertUnansweredDesc = prometheus.NewDesc("supportpal_alert_unanswered_high_priority",
+	"Number of open tickets of an alert priority no operator responded to for longer than --alerts.unanswered-after", []string{"department"}, nil)
+
+var supportPalAlertSLAAtRiskDesc = prometheus.NewDesc("supportpal_alert_sla_at_risk",
+	"Number of open tickets due within --alerts.sla-at-risk-within or past their due time", []string{"department"}, nil)
+
+// collectAlerts sends the alertable counts of the open tickets in s, one per
+// department with open tickets, 0 while none matches so alert rules can
+// compare with > 0. Each count is only sent while its threshold is set.
+func collectAlerts(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if config.AlertUnansweredAfter <= 0 && config.AlertSLAAtRiskWithin <= 0 {
+		return
+	}
+
+	unanswered := make(labelCounts)
+	atRisk := make(labelCounts)
+
+	for _, series := range s.Series {
+		if !series.open() {
+			continue
+		}
+
+		if config.AlertUnansweredAfter > 0 {
+			var v float64
+			if series.MessagesFetched && series.FirstResponse == 0 && alertPriority(series.Priority) &&
+				now.Sub(time.Unix(series.Created, 0)) > config.AlertUnansweredAfter {
+				v = 1
+			}
+			unanswered.add(v, series.Department)
+		}
+
+		if config.AlertSLAAtRiskWithin > 0 {
+			var v float64
+			if series.Due != 0 && time.Unix(series.Due, 0).Sub(now) <= config.AlertSLAAtRiskWithin {
+				v = 1
+			}
+			atRisk.add(v, series.Department)
+		}
+	}
+
+	unanswered.emit(ch, supportPalAlertUnansweredDesc, prometheus.GaugeValue)
+	atRisk.emit(ch, supportPalAlertSLAAtRiskDesc, prometheus.GaugeValue)
+}
+
+// alertPriority returns whether tickets of priority are watched by
+// supportpal_alert_unanswered_high_priority, compared case-insensitively
+func alertPriority(priority string) bool {
+	return slices.ContainsFunc(config.AlertPriorities, func(p string) bool { return strings.EqualFold(p, priority) })
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// annotationRequest is the body of a Grafana SimpleJSON annotation query.
+// The query of the annotation is a comma separated list of event types.
+type annotationRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// annotation is a ticket event in the Grafana SimpleJSON annotation format
+type annotation struct {
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// annotationEvents are the ticket events available as annotations
+var annotationEvents = []string{"created", "resolved", "reopened", "escalated"}
+
+// ticketAnnotations returns the events of the given types between from and
+// to from the ticket history at now, ordered by time
+func ticketAnnotations(h *eventHistory, from, to, now time.Time, types map[string]bool) []annotation {
+	events := []annotation{}
+
+	for _, event := range h.between(from, to, now, types) {
+		events = append(events, annotation{
+			Time:  event.Time * 1000,
+			Title: "Ticket " + strconv.Itoa(event.TicketID) + " " + event.Type,
+			Text:  event.Department + ": " + event.Status,
+			Tags:  []string{event.Type, event.Department},
+		})
+	}
+
+	return events
+}
+
+// annotationsHandler answers Grafana SimpleJSON annotation queries with the
+// ticket events of the history
+func annotationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req annotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var query struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(req.Annotation, &query)
+
+	types := make(map[string]bool)
+	for _, event := range splitList(strings.ToLower(query.Query)) {
+		types[event] = true
+	}
+	if len(types) == 0 {
+		for _, event := range annotationEvents {
+			types[event] = true
+		}
+	}
+
+	if req.Range.To.IsZero() {
+		req.Range.To = time.Now()
+	}
+
+	events := ticketAnnotations(ticketHistory, req.Range.From, req.Range.To, time.Now(), types)
+	for i := range events {
+		events[i].Annotation = req.Annotation
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// rootHandler answers the connection test of Grafana datasources and links
+// the endpoints for humans
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(`<html><head><title>SupportPal Exporter</title></head><body>
+<h1>SupportPal Exporter</h1>
+<p><a href="/metrics">Metrics</a></p>
+<p><a href="/-/status">Status</a></p>
+</body></html>
+`))
+}
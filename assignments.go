@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalAssignmentShareDesc = prometheus.NewDesc("supportpal_assignment_share_ratio",
+	"Share of an operator in the assignments of tickets created within the assignment window among the operators of a group",
+	[]string{"operator", "group"}, nil)
+
+// fetchOperatorGroups returns the group names of every enabled operator by
+// operator name, or nil if the operators cannot be listed
+func fetchOperatorGroups() map[string][]string {
+	operators, err := apiClient.ListOperators()
+	if err != nil {
+		slog.Error("failed to list operators", "err", err)
+		collectionError("operators")
+		return nil
+	}
+
+	groups := make(map[string][]string)
+	for _, operator := range operators {
+		if operator.Active == 0 {
+			continue
+		}
+
+		name := operatorName(operator.FormattedName)
+		for _, group := range operator.Groups {
+			groups[name] = append(groups[name], group.Name)
+		}
+	}
+
+	return groups
+}
+
+// collectAssignments sends the share of every operator in the assignments
+// of tickets created within the assignment window, per operator group.
+// Operators of a group without assignments have a share of 0, absent
+// operators are left out.
+func collectAssignments(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if s.OperatorGroups == nil {
+		return
+	}
+
+	assigned := make(map[string]map[string]int)
+	for operator, groups := range s.OperatorGroups {
+		if operatorAbsent(operator, now) {
+			continue
+		}
+		for _, group := range groups {
+			if assigned[group] == nil {
+				assigned[group] = make(map[string]int)
+			}
+			assigned[group][operator] = 0
+		}
+	}
+
+	cutoff := now.Add(-config.AssignmentWindow).Unix()
+	for _, series := range s.Series {
+		if series.Deleted != 0 || series.Created < cutoff {
+			continue
+		}
+
+		for _, operator := range series.Assigned {
+			if operatorAbsent(operator, now) {
+				continue
+			}
+			for _, group := range s.OperatorGroups[operator] {
+				assigned[group][operator]++
+			}
+		}
+	}
+
+	for group, operators := range assigned {
+		total := 0
+		for _, n := range operators {
+			total += n
+		}
+		if total == 0 {
+			continue
+		}
+
+		for operator, n := range operators {
+			ch <- prometheus.MustNewConstMetric(supportPalAssignmentShareDesc, prometheus.GaugeValue,
+				float64(n)/float64(total), operator, group)
+		}
+	}
+}
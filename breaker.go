@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+)
+
+// breakerState is the state of the circuit breaker
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// String returns the name of the state
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops the collection loop from hammering a failing backend.
+// It opens after threshold consecutive failures and, once cooldown has
+// passed, lets a single probe through before resuming full collection.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	state     breakerState
+}
+
+// newCircuitBreaker returns a closed circuit breaker
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a request may be sent at now. When the cooldown of an
+// open breaker has passed it moves to half-open and the caller must probe.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b.state != breakerOpen {
+		return true
+	}
+
+	if now.Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.state = breakerHalfOpen
+	return true
+}
+
+// success records a successful request and closes the breaker
+func (b *circuitBreaker) success() {
+	b.failures = 0
+	b.state = breakerClosed
+}
+
+// failure records a failed request and opens the breaker when the threshold
+// is reached or the half-open probe failed
+func (b *circuitBreaker) failure(now time.Time) {
+	b.failures++
+
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
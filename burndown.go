@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// burndownWindow is the trailing window the resolution rate is measured over
+const burndownWindow = 7 * 24 * time.Hour
+
+var supportPalBacklogBurndownDesc = prometheus.NewDesc("supportpal_backlog_burndown_days",
+	"Naive estimate of the days needed to resolve the open tickets of a department at its resolution rate of the last 7 days, +Inf if nothing was resolved",
+	[]string{"department"}, nil)
+
+// churnWindow is the trailing window of the precomputed churn gauges
+const churnWindow = 24 * time.Hour
+
+var supportPalTicketsCreated24hDesc = prometheus.NewDesc("supportpal_tickets_created_24h",
+	"Number of collected tickets of a department created within the last 24 hours", []string{"department"}, nil)
+
+var supportPalTicketsResolved24hDesc = prometheus.NewDesc("supportpal_tickets_resolved_24h",
+	"Number of collected tickets of a department resolved within the last 24 hours", []string{"department"}, nil)
+
+// collectChurn sends the number of tickets created and resolved within the
+// churn window per department, 0 for the other departments of s
+func collectChurn(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	created := make(labelCounts)
+	resolved := make(labelCounts)
+	since := now.Add(-churnWindow).Unix()
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		var c, r float64
+		if series.Created >= since {
+			c = 1
+		}
+		if series.Resolved != 0 && series.Resolved >= since {
+			r = 1
+		}
+		created.add(c, series.Department)
+		resolved.add(r, series.Department)
+	}
+
+	created.emit(ch, supportPalTicketsCreated24hDesc, prometheus.GaugeValue)
+	resolved.emit(ch, supportPalTicketsResolved24hDesc, prometheus.GaugeValue)
+}
+
+var supportPalTicketArrivalRateDesc = prometheus.NewDesc("supportpal_ticket_arrivals_per_hour",
+	"Average number of collected tickets of a priority created per hour over the rate window", []string{"priority"}, nil)
+
+var supportPalTicketResolutionRateDesc = prometheus.NewDesc("supportpal_ticket_resolutions_per_hour",
+	"Average number of collected tickets of a priority resolved per hour over the rate window", []string{"priority"}, nil)
+
+// collectRates sends the hourly arrival and resolution rates per priority
+// over config.RateWindow, 0 for the other priorities of s
+func collectRates(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if config.RateWindow <= 0 {
+		return
+	}
+
+	arrivals := make(labelCounts)
+	resolutions := make(labelCounts)
+	since := now.Add(-config.RateWindow).Unix()
+	perTicket := 1 / config.RateWindow.Hours()
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		var a, r float64
+		if series.Created >= since {
+			a = perTicket
+		}
+		if series.Resolved != 0 && series.Resolved >= since {
+			r = perTicket
+		}
+		arrivals.add(a, series.Priority)
+		resolutions.add(r, series.Priority)
+	}
+
+	arrivals.emit(ch, supportPalTicketArrivalRateDesc, prometheus.GaugeValue)
+	resolutions.emit(ch, supportPalTicketResolutionRateDesc, prometheus.GaugeValue)
+}
+
+// collectBurndown sends the backlog burn-down estimate of every department in s
+func collectBurndown(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	open := make(map[string]int)
+	resolved := make(map[string]int)
+	since := now.Add(-burndownWindow).Unix()
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		if series.open() {
+			open[series.Department]++
+		} else if series.Resolved >= since {
+			resolved[series.Department]++
+		}
+	}
+
+	for department, n := range open {
+		days := math.Inf(1)
+		if rate := float64(resolved[department]) / burndownWindow.Hours() * 24; rate > 0 {
+			days = float64(n) / rate
+		}
+
+		ch <- prometheus.MustNewConstMetric(supportPalBacklogBurndownDesc, prometheus.GaugeValue, days, department)
+	}
+
+	for department := range resolved {
+		if _, ok := open[department]; !ok {
+			ch <- prometheus.MustNewConstMetric(supportPalBacklogBurndownDesc, prometheus.GaugeValue, 0, department)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// holidaySet holds the public holidays of a region
+type holidaySet struct {
+	// dates holds one-off holidays as YYYY-MM-DD
+	dates map[string]bool
+	// yearly holds holidays recurring every year as MM-DD
+	yearly map[string]bool
+}
+
+// contains reports whether the day of t is a holiday
+func (h holidaySet) contains(t time.Time) bool {
+	return h.dates[t.Format("2006-01-02")] || h.yearly[t.Format("01-02")]
+}
+
+// loadHolidays reads a holiday calendar in iCal (.ics) or JSON format
+func loadHolidays(path string) (holidaySet, error) {
+	h := holidaySet{dates: make(map[string]bool), yearly: make(map[string]bool)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		err = parseICal(f, h)
+	} else {
+		err = parseHolidayJSON(f, h)
+	}
+	if err != nil {
+		return h, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return h, nil
+}
+
+// parseICal reads the all-day events of an iCal calendar into h. Events
+// with a yearly recurrence rule are added as recurring holidays.
+func parseICal(f *os.File, h holidaySet) error {
+	var start string
+	var yearly bool
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "BEGIN:VEVENT":
+			start, yearly = "", false
+		case strings.HasPrefix(line, "DTSTART"):
+			if i := strings.LastIndex(line, ":"); i >= 0 && len(line)-i-1 >= 8 {
+				start = line[i+1 : i+9]
+			}
+		case strings.HasPrefix(line, "RRULE:") && strings.Contains(line, "FREQ=YEARLY"):
+			yearly = true
+		case line == "END:VEVENT":
+			day, err := time.Parse("20060102", start)
+			if err != nil {
+				return fmt.Errorf("invalid DTSTART %q", start)
+			}
+
+			if yearly {
+				h.yearly[day.Format("01-02")] = true
+			} else {
+				h.dates[day.Format("2006-01-02")] = true
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseHolidayJSON reads a JSON list of YYYY-MM-DD dates or objects with a
+// date field into h. Dates in MM-DD form recur every year.
+func parseHolidayJSON(f *os.File, h holidaySet) error {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return err
+	}
+
+	for _, raw := range entries {
+		var date string
+		if err := json.Unmarshal(raw, &date); err != nil {
+			var entry struct {
+				Date string `json:"date"`
+			}
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return err
+			}
+			date = entry.Date
+		}
+
+		if _, err := time.Parse("01-02", date); err == nil {
+			h.yearly[date] = true
+			continue
+		}
+
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid holiday date %q", date)
+		}
+		h.dates[date] = true
+	}
+
+	return nil
+}
+
+// businessCalendar defines the working time used by business time metrics
+type businessCalendar struct {
+	location *time.Location
+	days     [7]bool
+	open     time.Duration
+	close    time.Duration
+	holidays holidaySet
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseBusinessHours parses working hours in the form "Mon-Fri 09:00-17:00"
+func parseBusinessHours(s string, location *time.Location, holidays holidaySet) (*businessCalendar, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("business hours %q: expected days and hours, e.g. Mon-Fri 09:00-17:00", s)
+	}
+
+	c := &businessCalendar{location: location, holidays: holidays}
+
+	days := strings.SplitN(strings.ToLower(fields[0]), "-", 2)
+	first, ok := weekdays[days[0]]
+	last := first
+	if len(days) == 2 {
+		last, ok = weekdays[days[1]]
+	}
+	if !ok {
+		return nil, fmt.Errorf("business hours %q: invalid days", s)
+	}
+	for d := first; ; d = (d + 1) % 7 {
+		c.days[d] = true
+		if d == last {
+			break
+		}
+	}
+
+	hours := strings.SplitN(fields[1], "-", 2)
+	if len(hours) != 2 {
+		return nil, fmt.Errorf("business hours %q: invalid hours", s)
+	}
+
+	for i, dst := range []*time.Duration{&c.open, &c.close} {
+		t, err := time.Parse("15:04", hours[i])
+		if err != nil {
+			return nil, fmt.Errorf("business hours %q: %w", s, err)
+		}
+		*dst = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if c.close <= c.open {
+		return nil, fmt.Errorf("business hours %q: closing time must be after opening time", s)
+	}
+
+	return c, nil
+}
+
+// duration returns the working time between from and to
+func (c *businessCalendar) duration(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+
+	from, to = from.In(c.location), to.In(c.location)
+
+	var total time.Duration
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, c.location)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !c.days[day.Weekday()] || c.holidays.contains(day) {
+			continue
+		}
+
+		start, end := day.Add(c.open), day.Add(c.close)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+
+	return total
+}
+
+// departmentHours are the working hours of departments that differ from
+// --business.hours. Timezone and Region default to --business.timezone and
+// --business.region.
+type departmentHours struct {
+	DepartmentIDs []int  `yaml:"department_ids"`
+	Hours         string `yaml:"hours"`
+	Timezone      string `yaml:"timezone"`
+	Region        string `yaml:"region"`
+}
+
+// departmentHoursFile is the file format of --business.departments-file
+type departmentHoursFile struct {
+	Departments []departmentHours `yaml:"departments"`
+}
+
+// loadDepartmentHours reads the working hours of departments and returns
+// the business calendar of every department ID
+func loadDepartmentHours(path, timezone, region string, holidays map[string]holidaySet) (map[int]*businessCalendar, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f departmentHoursFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	calendars := make(map[int]*businessCalendar)
+	for i, d := range f.Departments {
+		if len(d.DepartmentIDs) == 0 {
+			return nil, fmt.Errorf("%s: entry %d has no department IDs", path, i+1)
+		}
+
+		if d.Timezone == "" {
+			d.Timezone = timezone
+		}
+		location, err := time.LoadLocation(d.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+
+		if d.Region == "" {
+			d.Region = region
+		}
+		if _, ok := holidays[d.Region]; d.Region != "" && !ok {
+			return nil, fmt.Errorf("%s: entry %d: no holiday calendar configured for region %s", path, i+1, d.Region)
+		}
+
+		c, err := parseBusinessHours(d.Hours, location, holidays[d.Region])
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+
+		for _, id := range d.DepartmentIDs {
+			if _, ok := calendars[id]; ok {
+				return nil, fmt.Errorf("%s: department %d has working hours twice", path, id)
+			}
+			calendars[id] = c
+		}
+	}
+
+	return calendars, nil
+}
+
+// businessCalendarOf returns the business calendar of a department, nil if
+// business time metrics do not apply to it
+func businessCalendarOf(departmentID int) *businessCalendar {
+	if c, ok := config.DepartmentBusiness[departmentID]; ok {
+		return c
+	}
+	return config.Business
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var supportPalLabelCardinalityDesc = prometheus.NewDesc("supportpal_label_cardinality",
+	"Number of distinct values of a ticket label in the exported tickets, a free text custom field shows as a value close to the number of tickets",
+	[]string{"label"}, nil)
+
+var supportPalLabelTruncations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_label_cardinality_truncations_total",
+	Help: "Number of ticket label values replaced by \"other\" because the custom field label exceeded --customfields.max-values distinct values",
+}, []string{"label"})
+
+// overflowLabelValue replaces the values of a custom field label beyond its
+// distinct value limit
+const overflowLabelValue = "other"
+
+// collectCardinality sends the number of distinct values of every ticket
+// label of the tickets exported in per ticket metrics
+func collectCardinality(ch chan<- prometheus.Metric, s *snapshot) {
+	values := make(map[string]map[string]struct{})
+	for _, name := range s.LabelNames {
+		values[name] = make(map[string]struct{})
+	}
+
+	for _, series := range s.Series {
+		if series.Hidden {
+			continue
+		}
+
+		for i, name := range s.schema(series) {
+			if values[name] == nil {
+				values[name] = make(map[string]struct{})
+			}
+			values[name][series.LabelValues[i]] = struct{}{}
+		}
+	}
+
+	for name, distinct := range values {
+		ch <- prometheus.MustNewConstMetric(supportPalLabelCardinalityDesc, prometheus.GaugeValue, float64(len(distinct)), name)
+	}
+}
+
+// truncateLabelValues limits every custom field label of the entries to its
+// config.MaxFieldValues most frequent values, replacing the other values by
+// overflowLabelValue, and returns the kept values of the truncated labels.
+// Empty values are kept and do not count. Replaced values are counted in
+// supportpal_label_cardinality_truncations_total if count is set.
+func truncateLabelValues(entries []ticketEntry, count bool) map[string]map[string]struct{} {
+	if config.MaxFieldValues <= 0 {
+		return nil
+	}
+
+	base := labelSchema(nil)
+	counts := make(map[string]map[string]int)
+	for _, e := range entries {
+		for name, value := range e.labels {
+			if value == "" || slices.Contains(base, name) {
+				continue
+			}
+			if counts[name] == nil {
+				counts[name] = make(map[string]int)
+			}
+			counts[name][value]++
+		}
+	}
+
+	var kept map[string]map[string]struct{}
+	for name, values := range counts {
+		if len(values) <= config.MaxFieldValues {
+			continue
+		}
+
+		// the most frequent values are kept, ties in the order of the values
+		// so the same values are kept in every cycle
+		sorted := slices.SortedFunc(maps.Keys(values), func(a, b string) int {
+			if c := cmp.Compare(values[b], values[a]); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		})
+
+		if kept == nil {
+			kept = make(map[string]map[string]struct{})
+		}
+		kept[name] = make(map[string]struct{}, config.MaxFieldValues)
+		for _, value := range sorted[:config.MaxFieldValues] {
+			kept[name][value] = struct{}{}
+		}
+		slog.Debug("truncating custom field label", "label", name, "values", len(values), "limit", config.MaxFieldValues)
+	}
+
+	for _, e := range entries {
+		truncateLabels(e.labels, kept, count)
+	}
+
+	return kept
+}
+
+// truncateLabels replaces the values of the truncated labels that are not
+// among their kept values by overflowLabelValue, counting them if count is
+// set
+func truncateLabels(labels prometheus.Labels, kept map[string]map[string]struct{}, count bool) {
+	for name, values := range kept {
+		value, ok := labels[name]
+		if !ok || value == "" {
+			continue
+		}
+
+		if _, ok := values[value]; !ok {
+			labels[name] = overflowLabelValue
+			if count {
+				supportPalLabelTruncations.WithLabelValues(name).Inc()
+			}
+		}
+	}
+}
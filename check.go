@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// runCheck validates the configuration without starting the exporter: it
+// verifies the API connectivity and credentials, resolves the custom fields
+// of the tickets in sync and writes the label schema that would be generated
+// to w. It returns the number of problems found.
+func runCheck(w io.Writer) int {
+	problems := 0
+	problem := func(format string, args ...any) {
+		problems++
+		fmt.Fprintf(w, "FAIL "+format+"\n", args...)
+	}
+
+	// parseConfig exits on an invalid configuration before we get here
+	fmt.Fprintln(w, "ok   configuration")
+
+	if config.WebConfigFile != "" {
+		if _, err := loadWebConfig(config.WebConfigFile); err != nil {
+			problem("web configuration %s: %v", config.WebConfigFile, err)
+		}
+	}
+
+	if config.StatePath != "" {
+		if _, err := loadState(config.StatePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			problem("state file %s: %v", config.StatePath, err)
+		}
+	}
+
+	if err := apiClient.Check(); err != nil {
+		problem("API %s: %v", apiClient.BaseURL(), err)
+		return problems
+	}
+	fmt.Fprintf(w, "ok   API %s (%s)\n", apiClient.BaseURL(), apiClient.APIVersion())
+	refreshFieldLabels()
+
+	tickets := 0
+	var fieldIDs []int
+	err := source.StreamTickets(ticketInSyncBound, func(ticket *supportpal.Ticket) {
+		if !ticketSelected(ticket) {
+			return
+		}
+
+		tickets++
+		for _, customField := range ticket.CustomFields {
+			if !slices.Contains(fieldIDs, customField.FieldID) {
+				fieldIDs = append(fieldIDs, customField.FieldID)
+			}
+		}
+	})
+	if err != nil {
+		problem("list tickets: %v", err)
+		return problems
+	}
+	fmt.Fprintf(w, "ok   %d tickets in sync\n", tickets)
+
+	slices.Sort(fieldIDs)
+	labels := labelSchema(nil)
+	for _, id := range fieldIDs {
+		field, err := source.GetCustomField(id)
+		if errors.Is(err, supportpal.ErrNotFound) {
+			fmt.Fprintf(w, "ok   custom field %d was deleted, ignored\n", id)
+			continue
+		}
+		if err != nil {
+			problem("custom field %d: %v", id, err)
+			continue
+		}
+
+		switch {
+		case isNumericField(field):
+			fmt.Fprintf(w, "ok   custom field %d %q exported as value\n", id, field.Data.Name)
+		case isLabelField(field):
+			name := customFieldLabel(field)
+			fmt.Fprintf(w, "ok   custom field %d %q exported as label %s\n", id, field.Data.Name, name)
+			if !slices.Contains(labels, name) {
+				labels = append(labels, name)
+			}
+		default:
+			fmt.Fprintf(w, "ok   custom field %d %q excluded\n", id, field.Data.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "\nlabel schema (%d labels):\n", len(labels))
+	for _, name := range labels {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+
+	for _, j := range config.Jobs {
+		fmt.Fprintf(w, "\njob %s, metric prefix %s, labels: %s\n", j.Name, j.Prefix, strings.Join(j.schema(labels), ","))
+	}
+
+	return problems
+}
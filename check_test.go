@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+func TestRunCheck(t *testing.T) {
+	srv := httptest.NewServer(fixtureHandler(filepath.Join("testdata", "fixtures")))
+	t.Cleanup(srv.Close)
+
+	defer func(c Config, client *supportpal.Client, s TicketSource) {
+		config, apiClient, source = c, client, s
+	}(config, apiClient, source)
+	config = Config{}
+	connect := func(url string) {
+		apiClient = supportpal.New(supportpal.Config{BaseURL: url, ListTimeout: time.Second, EnrichmentTimeout: time.Second})
+		source = apiClient
+	}
+
+	connect(srv.URL)
+	var out bytes.Buffer
+	if problems := runCheck(&out); problems != 0 {
+		t.Fatalf("%d problems:\n%s", problems, out.String())
+	}
+	for _, line := range []string{
+		"ok   API " + srv.URL,
+		"ok   3 tickets in sync",
+		`ok   custom field 5 "Product area" exported as label product_area`,
+		"  product_area\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	// every problem is reported
+	config.StatePath = filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(config.StatePath, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	connect(srv.URL)
+	out.Reset()
+	if problems := runCheck(&out); problems != 2 || strings.Count(out.String(), "FAIL ") != 2 {
+		t.Errorf("%d problems, want the state file and the API:\n%s", problems, out.String())
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ticketSeries holds the label values and timestamps of a single ticket
+type ticketSeries struct {
+	ID           int
+	Status       string
+	Department   string
+	DepartmentID int    `json:",omitempty"`
+	Priority     string `json:",omitempty"`
+	Channel      string
+	// Organization is the name of the organization of the ticket user
+	Organization   string `json:",omitempty"`
+	OrganizationID int    `json:",omitempty"`
+	// Client is the exported client label of the ticket, after the label
+	// rules and anonymization
+	Client      string `json:",omitempty"`
+	LabelValues []string
+	Created     int64
+	Updated     int64
+	Deleted     int64
+	Resolved    int64
+	Due         int64
+	Tags        []string `json:",omitempty"`
+	// FirstResponse is the time of the first operator response, 0 if
+	// messages were not fetched or nobody responded yet
+	FirstResponse int64 `json:",omitempty"`
+	// FirstResponseBusiness is the working time in seconds until the first
+	// operator response, 0 if business hours are not configured
+	FirstResponseBusiness int64 `json:",omitempty"`
+	// FirstResponder is the operator who sent the first response
+	FirstResponder string `json:",omitempty"`
+	// ResolvedBy is the operator a resolved ticket is attributed to, see
+	// config.ResolvedBy
+	ResolvedBy string `json:",omitempty"`
+	// BusinessAge is the working time in seconds from the creation of an
+	// open ticket until BusinessAgeAt, when business hours apply to it
+	BusinessAge   int64 `json:",omitempty"`
+	BusinessAgeAt int64 `json:",omitempty"`
+	// MessagesFetched is set when the response fields were computed from messages
+	MessagesFetched bool `json:",omitempty"`
+	// LastResponse is the time of the latest operator response
+	LastResponse int64 `json:",omitempty"`
+	// Replies is the number of public replies
+	Replies int `json:",omitempty"`
+	// ReassignmentsFetched is set when Reassignments was computed from the
+	// audit log of the ticket
+	ReassignmentsFetched bool `json:",omitempty"`
+	Reassignments        int  `json:",omitempty"`
+	// Rated is set when the ticket received feedback
+	Rated bool `json:",omitempty"`
+	// Rating is the latest feedback rating of the ticket
+	Rating float64 `json:",omitempty"`
+	// RatedOperator is the operator the latest feedback was left for
+	RatedOperator string `json:",omitempty"`
+	// Dates holds the values of date custom fields by label name
+	Dates map[string]int64 `json:",omitempty"`
+	// Values holds the values of numeric custom fields by label name
+	Values map[string]float64 `json:",omitempty"`
+	// URL is the operator URL of the ticket
+	URL string `json:",omitempty"`
+	// SenderDomain is the email domain of the ticket user and Sender a key
+	// of the address, see senderKey
+	SenderDomain string `json:",omitempty"`
+	Sender       string `json:",omitempty"`
+	// Assigned holds the names of the operators assigned to the ticket
+	Assigned []string `json:",omitempty"`
+	// CustomerWait is the time in seconds an open ticket waited on the
+	// customer in completed waits, CustomerWaitBusiness the working time of
+	// them, and CustomerWaitSince the start of the current wait, 0 if the
+	// ticket waits on an operator. They are set with config.SLAPause.
+	CustomerWait         int64 `json:",omitempty"`
+	CustomerWaitBusiness int64 `json:",omitempty"`
+	CustomerWaitSince    int64 `json:",omitempty"`
+	// WaitingOnCustomer is set when the ticket waits on the customer, see
+	// waitingOnCustomer
+	WaitingOnCustomer bool `json:",omitempty"`
+	// messages are the fetched messages of the ticket until they are
+	// counted by operatorReplies
+	messages []*supportpal.Message
+	// Hidden is set when the ticket is left out of the per ticket metrics
+	// because it lacks custom fields, was closed longer than the grace
+	// period ago or is in the trash with config.Trashed exclude, it still
+	// counts in aggregates
+	Hidden bool `json:",omitempty"`
+}
+
+// snapshot is the complete result of a collection cycle. It is never
+// modified once stored, so scrapes always see a consistent set of tickets.
+type snapshot struct {
+	CollectedAt time.Time
+	LabelNames  []string
+	// Groups holds the label schema of every department when label sets are
+	// split by department, nil pads every ticket to LabelNames
+	Groups map[string][]string `json:",omitempty"`
+	// OperatorGroups holds the groups of every operator by name when
+	// assignment metrics are enabled
+	OperatorGroups map[string][]string `json:",omitempty"`
+	// Deprecated holds the labels of the schema no ticket uses anymore with
+	// the time they were last used. They are exported empty until their
+	// grace period ends and never hide a ticket.
+	Deprecated map[string]time.Time `json:",omitempty"`
+	// KeptValues holds the values kept of every custom field label that
+	// exceeded config.MaxFieldValues, the other values are exported as
+	// overflowLabelValue
+	KeptValues map[string]map[string]struct{} `json:",omitempty"`
+	Series     []*ticketSeries
+	// job marks the snapshot of a job, its truncated label values are
+	// counted by the full snapshot already
+	job bool
+	// fixed is the set of labels not belonging to custom fields, computed
+	// by the first add
+	fixed map[string]bool
+}
+
+// newSnapshot returns an empty snapshot using labelNames as label schema
+func newSnapshot(labelNames []string) *snapshot {
+	return &snapshot{CollectedAt: time.Now(), LabelNames: labelNames}
+}
+
+// schema returns the label names of the series
+func (s *snapshot) schema(series *ticketSeries) []string {
+	if names, ok := s.Groups[series.Department]; ok {
+		return names
+	}
+
+	return s.LabelNames
+}
+
+// open reports whether the ticket is neither resolved nor deleted
+func (s *ticketSeries) open() bool {
+	return s.Resolved == 0 && s.Deleted == 0
+}
+
+// add appends a ticket with the given labels to the snapshot. Labels missing
+// from the schema are left empty, custom fields are handled according to
+// config.MissingFields, and labels outside the schema are dropped. A ticket
+// with the same label values as an earlier one replaces it.
+func (s *snapshot) add(labels prometheus.Labels, series *ticketSeries, seen map[string]int) {
+	if s.fixed == nil {
+		s.fixed = fixedLabels()
+	}
+
+	names := s.schema(series)
+	series.LabelValues = make([]string, len(names))
+	for i, name := range names {
+		value, ok := labels[name]
+		if _, deprecated := s.Deprecated[name]; !ok && !deprecated && !s.fixed[name] {
+			var exported bool
+			value, exported = missingFieldValue()
+			series.Hidden = series.Hidden || !exported
+		}
+		series.LabelValues[i] = value
+	}
+
+	key := seriesKey(names, series.LabelValues)
+	if i, ok := seen[key]; ok {
+		s.Series[i] = series
+		return
+	}
+
+	seen[key] = len(s.Series)
+	s.Series = append(s.Series, series)
+}
+
+// replaceTicket returns a copy of s in which the ticket with the given ID is
+// replaced by series with the given labels, or removed if series is nil
+func (s *snapshot) replaceTicket(id int, labels prometheus.Labels, series *ticketSeries) *snapshot {
+	next := newSnapshot(s.LabelNames)
+	next.Groups = s.Groups
+	next.OperatorGroups = s.OperatorGroups
+	next.Deprecated = s.Deprecated
+	next.KeptValues = s.KeptValues
+	next.job = s.job
+	seen := make(map[string]int)
+
+	for _, old := range s.Series {
+		if old.ID == id {
+			continue
+		}
+
+		seen[seriesKey(s.schema(old), old.LabelValues)] = len(next.Series)
+		next.Series = append(next.Series, old)
+	}
+
+	if series != nil {
+		truncateLabels(labels, s.KeptValues, !s.job)
+		next.add(labels, series, seen)
+	}
+
+	return next
+}
+
+// labelValue returns the value of the label name of series or "" if its
+// schema has no such label
+func (s *snapshot) labelValue(series *ticketSeries, name string) string {
+	if i := slices.Index(s.schema(series), name); i >= 0 {
+		return series.LabelValues[i]
+	}
+	return ""
+}
+
+// seriesKey identifies a series by its label names and values
+func seriesKey(names, values []string) string {
+	return strings.Join(names, "\xff") + "\xfe" + strings.Join(values, "\xff")
+}
+
+// ticket returns the series of the ticket with the given ID or nil
+func (s *snapshot) ticket(id int) *ticketSeries {
+	for _, series := range s.Series {
+		if series.ID == id {
+			return series
+		}
+	}
+
+	return nil
+}
+
+// ticketMetric describes one of the per ticket timestamp metrics
+type ticketMetric struct {
+	name  string
+	help  string
+	value func(*ticketSeries) int64
+	// resolvedBy adds the resolved_by label when config.ResolvedBy is set
+	resolvedBy bool
+}
+
+var ticketMetrics = []ticketMetric{
+	{"supportpal_ticket_created_timestamp_seconds", "Last time a ticket was created", func(s *ticketSeries) int64 { return s.Created }, false},
+	{"supportpal_ticket_updated_timestamp_seconds", "Last time a ticket was updated", func(s *ticketSeries) int64 { return s.Updated }, false},
+	{"supportpal_ticket_deleted_timestamp_seconds", "Last time a ticket was deleted", func(s *ticketSeries) int64 { return s.Deleted }, false},
+	{"supportpal_ticket_resolved_timestamp_seconds", "Last time a ticket was resolved", func(s *ticketSeries) int64 { return s.Resolved }, true},
+}
+
+// seriesDesc describes a per ticket metric. Its descriptor depends on the
+// label schema of the ticket when label sets are split by department.
+type seriesDesc struct {
+	name string
+	help string
+	// extra are the label names preceding the ticket labels
+	extra []string
+	descs map[string]*prometheus.Desc
+}
+
+// newSeriesDesc returns a seriesDesc of a per ticket metric
+func newSeriesDesc(name, help string, extra ...string) *seriesDesc {
+	return &seriesDesc{name: name, help: help, extra: extra, descs: make(map[string]*prometheus.Desc)}
+}
+
+// infoKeyLabels are the only labels of per ticket metrics when the
+// descriptive labels are moved to supportpal_ticket_info. ticket_id comes
+// first, the others are set by --metrics.ticket-info-key-labels.
+var infoKeyLabels = []string{"ticket_id", "status", "priority"}
+
+// desc returns the descriptor of the metric with the given ticket label names
+func (d *seriesDesc) desc(names []string) *prometheus.Desc {
+	key := strings.Join(names, "\xff")
+
+	desc, ok := d.descs[key]
+	if !ok {
+		desc = prometheus.NewDesc(d.name, d.help, append(slices.Clone(d.extra), names...), nil)
+		d.descs[key] = desc
+	}
+
+	return desc
+}
+
+// metric returns the metric of series in s with the values of the extra
+// labels. With config.TicketInfo the ticket is only identified by the
+// infoKeyLabels.
+func (d *seriesDesc) metric(s *snapshot, series *ticketSeries, valueType prometheus.ValueType, value float64, extra ...string) prometheus.Metric {
+	names, values := s.schema(series), series.LabelValues
+	if config.TicketInfo {
+		names = infoKeyLabels
+		values = []string{strconv.Itoa(series.ID)}
+		for _, name := range infoKeyLabels[1:] {
+			values = append(values, s.labelValue(series, name))
+		}
+	}
+
+	return prometheus.MustNewConstMetric(d.desc(names), valueType, value, append(slices.Clone(extra), values...)...)
+}
+
+// ticketCollector exposes the latest snapshot as constant metrics. The label
+// schema can change between snapshots, so it does not describe its metrics
+// upfront and is registered as an unchecked collector.
+type ticketCollector struct {
+	current atomic.Pointer[snapshot]
+	// mu serializes replacing the snapshot with updates of it
+	mu sync.Mutex
+}
+
+// store replaces the snapshot served to scrapes
+func (c *ticketCollector) store(s *snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current.Store(s)
+}
+
+// update replaces the snapshot served to scrapes with the result of fn
+// applied to it. Nothing happens until a first snapshot was stored.
+func (c *ticketCollector) update(fn func(*snapshot) *snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s := c.current.Load(); s != nil {
+		c.current.Store(fn(s))
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *ticketCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (c *ticketCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.current.Load()
+	if s == nil {
+		return
+	}
+
+	now := time.Now()
+	collectSeries(ch, s)
+	collectAges(ch, s, now)
+	collectAggregates(ch, s, now)
+	collectOrganizations(ch, s, now)
+	collectFeedback(ch, s)
+	collectBurndown(ch, s, now)
+	collectChurn(ch, s, now)
+	collectTrash(ch, s, now)
+	collectRates(ch, s, now)
+	collectAssignments(ch, s, now)
+	collectAbsences(ch, s, now)
+	collectReassignments(ch, s)
+	collectLeaderboard(ch, s, now)
+	collectStorms(ch, s, now)
+	collectAlerts(ch, s, now)
+	collectCardinality(ch, s)
+}
+
+// collectSeries sends the per ticket metrics of s. With
+// config.TicketTimestamps the timestamp metrics are sampled at the time they
+// hold.
+func collectSeries(ch chan<- prometheus.Metric, s *snapshot) {
+	for _, m := range ticketMetrics {
+		resolvedBy := m.resolvedBy && config.ResolvedBy != ""
+		var extraLabels []string
+		if resolvedBy {
+			extraLabels = append(extraLabels, "resolved_by")
+		}
+		desc := newSeriesDesc(m.name, unitHelp(m.help, units["timestamp_seconds"]), extraLabels...)
+
+		for _, series := range s.Series {
+			value := m.value(series)
+			if value == 0 || series.Hidden {
+				continue
+			}
+
+			var extra []string
+			if resolvedBy {
+				extra = append(extra, series.ResolvedBy)
+			}
+			metric := desc.metric(s, series, prometheus.GaugeValue, float64(value), extra...)
+			if config.TicketTimestamps {
+				metric = prometheus.NewMetricWithTimestamp(time.Unix(value, 0), metric)
+			}
+			ch <- metric
+		}
+	}
+
+	dueDesc := newSeriesDesc("supportpal_ticket_due_timestamp_seconds",
+		unitHelp("Time a ticket is due", units["timestamp_seconds"]))
+
+	dateDesc := newSeriesDesc("supportpal_ticket_customfield_timestamp_seconds",
+		unitHelp("Value of a date custom field of a ticket", units["timestamp_seconds"]), "field")
+
+	responseDesc := newSeriesDesc(durationName("supportpal_ticket_first_response"),
+		durationHelp("Time from ticket creation to the first operator response"))
+
+	businessResponseDesc := newSeriesDesc(durationName("supportpal_ticket_first_response_business"),
+		durationHelp("Working time from ticket creation to the first operator response"))
+
+	repliesDesc := newSeriesDesc("supportpal_ticket_replies_total",
+		"Number of public replies of a ticket")
+
+	valueDesc := newSeriesDesc("supportpal_ticket_customfield_value",
+		"Value of a numeric custom field of a ticket", "field")
+
+	infoDesc := newSeriesDesc("supportpal_ticket_info",
+		"Descriptive labels of a ticket, always 1", "ticket_id")
+
+	tagDesc := prometheus.NewDesc("supportpal_ticket_tag_info",
+		"Tags of a ticket, always 1", []string{"ticket_id", "tag"}, nil)
+
+	for _, series := range s.Series {
+		for _, tag := range series.Tags {
+			ch <- prometheus.MustNewConstMetric(tagDesc, prometheus.GaugeValue, 1, strconv.Itoa(series.ID), tag)
+		}
+
+		if series.Hidden {
+			continue
+		}
+
+		if config.TicketInfo {
+			ch <- prometheus.MustNewConstMetric(infoDesc.desc(s.schema(series)), prometheus.GaugeValue, 1,
+				append([]string{strconv.Itoa(series.ID)}, series.LabelValues...)...)
+		}
+
+		if series.Due != 0 {
+			ch <- dueDesc.metric(s, series, prometheus.GaugeValue, float64(series.Due))
+		}
+
+		if series.FirstResponse != 0 {
+			ch <- responseDesc.metric(s, series, prometheus.GaugeValue,
+				durationValue(time.Duration(series.FirstResponse-series.Created)*time.Second))
+		}
+
+		if series.MessagesFetched {
+			ch <- repliesDesc.metric(s, series, prometheus.CounterValue, float64(series.Replies))
+		}
+
+		if series.FirstResponseBusiness != 0 {
+			ch <- businessResponseDesc.metric(s, series, prometheus.GaugeValue,
+				durationValue(time.Duration(series.FirstResponseBusiness)*time.Second))
+		}
+
+		for field, ts := range series.Dates {
+			ch <- dateDesc.metric(s, series, prometheus.GaugeValue, float64(ts), field)
+		}
+
+		for field, v := range series.Values {
+			ch <- valueDesc.metric(s, series, prometheus.GaugeValue, v, field)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// exporterCollector is an optional group of metrics that is enabled or
+// disabled with --collector.<name>, like the collectors of node_exporter.
+// The ticket metrics are the base of the exporter and always collected.
+type exporterCollector struct {
+	name string
+	help string
+	// enabled is the configuration field switching the collector, shared
+	// with the older --metrics.* flag of the collector if it has one
+	enabled *bool
+	// enabledByDefault collectors are disabled by --collector.disable-defaults
+	enabledByDefault bool
+}
+
+// exporterCollectors are the optional collectors
+var exporterCollectors = []*exporterCollector{
+	{name: "organizations", help: "organization ticket counts and client resolution ratios", enabled: &config.OrganizationMetrics, enabledByDefault: true},
+	{name: "operators", help: "user and operator headcounts, same as --metrics.inventory", enabled: &config.Inventory},
+	{name: "assignments", help: "assignment shares of operators in their groups, same as --metrics.assignments", enabled: &config.Assignments},
+	{name: "leaderboard", help: "operator response and resolution times, same as --metrics.operator-leaderboard", enabled: &config.Leaderboard},
+	{name: "feedback", help: "ticket feedback ratings, same as --metrics.feedback", enabled: &config.Feedback},
+	{name: "kb", help: "knowledge base articles, same as --metrics.articles", enabled: &config.Articles},
+	{name: "email", help: "email log counters, same as --metrics.emails", enabled: &config.Emails},
+	{name: "backend", help: "SupportPal version and scheduled tasks, same as --metrics.backend", enabled: &config.Backend},
+	{name: "catalog", help: "configured ticket statuses, priorities and departments", enabled: &config.Catalog},
+}
+
+// collectorFlags defines a --collector.<name> flag for every collector and
+// --collector.disable-defaults. They are defined after the --metrics.*
+// flags, so the value of a --metrics.* flag or its environment variable is
+// the default of the collector flag and the flag given last wins.
+func collectorFlags() *bool {
+	for _, c := range exporterCollectors {
+		env := "COLLECTOR_" + strings.ToUpper(c.name)
+		flag.BoolVar(c.enabled, "collector."+c.name, envBool(env, *c.enabled || c.enabledByDefault),
+			"Enable the "+c.name+" collector: "+c.help+" ("+env+")")
+	}
+
+	return flag.Bool("collector.disable-defaults", envBool("COLLECTOR_DISABLE_DEFAULTS", false),
+		"Disable the collectors enabled by default, only those enabled explicitly run (COLLECTOR_DISABLE_DEFAULTS)")
+}
+
+// disableDefaultCollectors disables the collectors enabled by default whose
+// flag or environment variable was not set explicitly
+func disableDefaultCollectors() {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, c := range exporterCollectors {
+		if !c.enabledByDefault || set["collector."+c.name] {
+			continue
+		}
+		if _, ok := os.LookupEnv("COLLECTOR_" + strings.ToUpper(c.name)); ok {
+			continue
+		}
+		*c.enabled = false
+	}
+}
+
+// enabledCollectors returns the names of the enabled collectors
+func enabledCollectors() []string {
+	var names []string
+	for _, c := range exporterCollectors {
+		if *c.enabled {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// persistedCounters are the counters whose values survive restarts when a
+// state path is configured
+var persistedCounters = map[string]*prometheus.CounterVec{
+	"supportpal_tickets_created_total":              supportPalTicketsCreated,
+	"supportpal_tickets_resolved_total":             supportPalTicketsResolved,
+	"supportpal_tickets_resolved_by_operator_total": supportPalTicketsResolvedByOperator,
+	"supportpal_organization_tickets_created_total": supportPalOrganizationTicketsCreated,
+	"supportpal_tickets_deleted_total":              supportPalTicketsDeleted,
+	"supportpal_tickets_purged_total":               supportPalTicketsPurged,
+	"supportpal_ticket_reopened_total":              supportPalTicketsReopened,
+	"supportpal_ticket_priority_changes_total":      supportPalTicketPriorityChanges,
+	"supportpal_operator_replies_total":             supportPalOperatorReplies,
+	"supportpal_ticket_messages_total":              supportPalTicketMessages,
+	"supportpal_ticket_attachments_total":           supportPalTicketAttachments,
+	"supportpal_probable_duplicate_tickets_total":   supportPalDuplicateTickets,
+	"supportpal_emails_sent_total":                  supportPalEmailsSent,
+	"supportpal_emails_received_total":              supportPalEmailsReceived,
+	"supportpal_email_failures_total":               supportPalEmailFailures,
+}
+
+// counterValue is the persisted value of a single counter series
+type counterValue struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// exportCounters returns the current values of the persisted counters
+func exportCounters() []counterValue {
+	var values []counterValue
+
+	for name, vec := range persistedCounters {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			vec.Collect(ch)
+			close(ch)
+		}()
+
+		for metric := range ch {
+			var m dto.Metric
+			if err := metric.Write(&m); err != nil {
+				slog.Error("failed to read counter", "name", name, "err", err)
+				continue
+			}
+
+			labels := make(map[string]string, len(m.Label))
+			for _, pair := range m.Label {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			values = append(values, counterValue{Name: name, Labels: labels, Value: m.GetCounter().GetValue()})
+		}
+	}
+
+	return values
+}
+
+// restoreCounters adds persisted values to the counters. It runs once on
+// startup before any counter was incremented.
+func restoreCounters(values []counterValue) {
+	for _, v := range values {
+		vec, ok := persistedCounters[v.Name]
+		if !ok {
+			continue
+		}
+
+		counter, err := vec.GetMetricWith(v.Labels)
+		if err != nil {
+			slog.Warn("ignoring persisted counter", "name", v.Name, "labels", v.Labels, "err", err)
+			continue
+		}
+
+		counter.Add(v.Value)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/gosimple/slug"
+)
+
+// SupportPal custom field types
+const (
+	fieldTypeCheckbox    = 0
+	fieldTypeDate        = 1
+	fieldTypeMultiSelect = 3
+	fieldTypeNumber      = 4
+	fieldTypeSelect      = 7
+)
+
+// handling of custom field labels missing from a ticket
+const (
+	// missingEmpty exports missing custom fields as empty labels
+	missingEmpty = "empty"
+	// missingSentinel exports missing custom fields as config.MissingFieldSentinel
+	missingSentinel = "sentinel"
+	// missingDrop leaves tickets lacking a custom field out of the per ticket metrics
+	missingDrop = "drop"
+)
+
+// fixedLabels returns the set of label names that do not belong to custom
+// fields. Callers classifying many labels compute it once.
+func fixedLabels() map[string]bool {
+	fixed := make(map[string]bool)
+	for _, name := range labelSchema(nil) {
+		fixed[name] = true
+	}
+	return fixed
+}
+
+// missingFieldValue returns the label value of a custom field missing from
+// a ticket and whether the ticket is still exported in per ticket metrics
+func missingFieldValue() (string, bool) {
+	switch config.MissingFields {
+	case missingSentinel:
+		return config.MissingFieldSentinel, true
+	case missingDrop:
+		return "", false
+	default:
+		return "", true
+	}
+}
+
+// customFieldLabel returns the label name of a custom field. Explicit name
+// overrides win over the transliterated slug of the field name, and fields
+// whose name slugifies to nothing fall back to field_<id>. Slugs that are no
+// valid label name or collide with another label are remapped, see
+// resolveFieldLabels. A field created since the last resolution is named
+// against the names resolved already.
+func customFieldLabel(field *supportpal.GetCustomFieldResponse) string {
+	fieldLabels.Lock()
+	defer fieldLabels.Unlock()
+
+	if name, ok := fieldLabels.names[field.Data.ID]; ok {
+		return name
+	}
+
+	name, reason := fieldLabelBase(&field.Data)
+	if holder, ok := fieldLabels.taken[name]; ok && holder != field.Data.ID {
+		name, reason = suffixedFieldLabel(name, field.Data.ID, fieldLabels.taken), "collides with custom field "+strconv.Itoa(holder)
+	}
+	fieldLabels.names[field.Data.ID] = name
+	fieldLabels.taken[name] = field.Data.ID
+	logFieldRemap(&field.Data, name, reason)
+
+	return name
+}
+
+// reservedLabels are the label names of per ticket metrics besides
+// CommonLabels, whether enabled or not, and the target labels added by
+// Prometheus
+var reservedLabels = []string{"subject_hash", "tags", "assigned", "organization_id", "last_reply_by", "resolved_by", "ticket_id", "status_id", "priority_id", "field", "job", "instance"}
+
+// exporterLabel reports whether name is a label of the per ticket metrics
+// not belonging to a custom field: a common, reserved, organization field or
+// label provider label
+func exporterLabel(name string) bool {
+	return slices.Contains(CommonLabels, name) || slices.Contains(reservedLabels, name) ||
+		slices.Contains(slices.Collect(maps.Values(config.OrganizationFieldLabels)), name) || slices.Contains(providerLabelNames(), name)
+}
+
+// fieldLabels holds the label names of the custom fields by ID and the IDs
+// of the fields by label name
+var fieldLabels = struct {
+	sync.Mutex
+	names map[int]string
+	taken map[string]int
+}{names: make(map[int]string), taken: make(map[string]int)}
+
+// remappedFields holds the last logged label of every remapped custom field
+var remappedFields sync.Map
+
+// resolveFieldLabels names the custom fields over the full field list, so
+// the names do not depend on the order tickets use the fields in. Explicit
+// name overrides are kept, of fields sharing a label name the field with the
+// lowest ID keeps it and the others get their ID appended, again until the
+// name is not taken by another field.
+func resolveFieldLabels(fields []*supportpal.CustomField) {
+	fields = slices.SortedFunc(slices.Values(fields), func(a, b *supportpal.CustomField) int { return a.ID - b.ID })
+
+	bases := make([]string, len(fields))
+	reasons := make([]string, len(fields))
+	taken := make(map[string]int, len(fields))
+	for _, pinned := range []bool{true, false} {
+		for i, field := range fields {
+			if _, ok := config.FieldNames[field.ID]; ok != pinned {
+				continue
+			}
+			bases[i], reasons[i] = fieldLabelBase(field)
+			if _, ok := taken[bases[i]]; !ok {
+				taken[bases[i]] = field.ID
+			}
+		}
+	}
+
+	names := make(map[int]string, len(fields))
+	for i, field := range fields {
+		name, reason := bases[i], reasons[i]
+		if holder := taken[name]; holder != field.ID {
+			name, reason = suffixedFieldLabel(name, field.ID, taken), "collides with custom field "+strconv.Itoa(holder)
+			taken[name] = field.ID
+		}
+		names[field.ID] = name
+		logFieldRemap(field, name, reason)
+	}
+
+	fieldLabels.Lock()
+	defer fieldLabels.Unlock()
+	fieldLabels.names, fieldLabels.taken = names, taken
+}
+
+// refreshFieldLabels resolves the label names of the custom fields at the
+// start of a cycle. If the fields cannot be listed, the names resolved
+// before are kept.
+func refreshFieldLabels() {
+	fields, err := source.ListCustomFields()
+	if err != nil {
+		slog.Warn("failed to list custom fields, keeping their label names", "err", err)
+		return
+	}
+	resolveFieldLabels(fields)
+}
+
+// fieldLabelBase returns the label name of a custom field before collisions
+// with other custom fields are resolved, and why it differs from the slug
+// of its name if it does. Slugs starting with a digit or colliding with a
+// label of the exporter get config.FieldLabelPrefix.
+func fieldLabelBase(field *supportpal.CustomField) (string, string) {
+	if name, ok := config.FieldNames[field.ID]; ok {
+		return name, ""
+	}
+
+	name := fieldSlug(field.Name, config.SlugLanguage)
+	switch {
+	case strings.Trim(name, "_") == "":
+		return "field_" + strconv.Itoa(field.ID), ""
+	case name[0] >= '0' && name[0] <= '9' || strings.HasPrefix(name, "__"):
+		return config.FieldLabelPrefix + name, "invalid label name"
+	case exporterLabel(name):
+		return config.FieldLabelPrefix + name, "collides with an exporter label"
+	default:
+		return name, ""
+	}
+}
+
+// suffixedFieldLabel appends the ID of a custom field to name until it is
+// not taken
+func suffixedFieldLabel(name string, id int, taken map[string]int) string {
+	suffix := "_" + strconv.Itoa(id)
+	for name += suffix; ; name += suffix {
+		if _, ok := taken[name]; !ok {
+			return name
+		}
+	}
+}
+
+// logFieldRemap logs a custom field label that differs from the slug of the
+// field name, once per label
+func logFieldRemap(field *supportpal.CustomField, label, reason string) {
+	if reason == "" {
+		return
+	}
+	if previous, loaded := remappedFields.Swap(field.ID, label); !loaded || previous != label {
+		slog.Warn("remapped custom field label", "field_id", field.ID, "name", field.Name,
+			"slug", fieldSlug(field.Name, config.SlugLanguage), "label", label, "reason", reason)
+	}
+}
+
+// fieldSlugs caches the slugs of custom field names by language and name,
+// every ticket of a cycle would otherwise slug the names of its fields again
+var fieldSlugs sync.Map
+
+// fieldSlug returns the label name slug of a custom field name
+func fieldSlug(name, language string) string {
+	key := language + "\x00" + name
+	if s, ok := fieldSlugs.Load(key); ok {
+		return s.(string)
+	}
+
+	s := slug.Make(name)
+	if language != "" {
+		s = slug.MakeLang(name, language)
+	}
+	s = strings.ReplaceAll(s, "-", "_")
+
+	fieldSlugs.Store(key, s)
+	return s
+}
+
+// fieldMatches reports whether the custom field is named in list by ID or label name
+func fieldMatches(list []string, field *supportpal.GetCustomFieldResponse) bool {
+	if len(list) == 0 {
+		return false
+	}
+
+	id := strconv.Itoa(field.Data.ID)
+	name := customFieldLabel(field)
+
+	for _, item := range list {
+		if item == id || item == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNumericField reports whether the custom field is exported as a value instead of a label
+func isNumericField(field *supportpal.GetCustomFieldResponse) bool {
+	return fieldMatches(config.NumericFields, field)
+}
+
+// isLabelField reports whether the custom field is exported as a label
+func isLabelField(field *supportpal.GetCustomFieldResponse) bool {
+	if config.DisableFieldLabels || isNumericField(field) {
+		return false
+	}
+
+	if len(config.IncludeFields) > 0 && !fieldMatches(config.IncludeFields, field) {
+		return false
+	}
+
+	return !fieldMatches(config.ExcludeFields, field)
+}
+
+// optionValue returns the slug of the option with the given id or id itself if unknown
+func optionValue(field *supportpal.GetCustomFieldResponse, id string) string {
+	nVal, err := strconv.Atoi(strings.TrimSpace(id))
+	if err != nil {
+		return id
+	}
+
+	for _, option := range field.Data.Options {
+		if option.ID == nVal {
+			return makeSlug(option.Value)
+		}
+	}
+
+	return id
+}
+
+// parseFieldDate parses the value of a date custom field, which is either a
+// unix timestamp or a plain date
+func parseFieldDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0).UTC(), true
+	}
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// renderCustomField returns the human readable label value of a custom field value
+func renderCustomField(field *supportpal.GetCustomFieldResponse, value string) string {
+	switch field.Data.Type {
+	case fieldTypeSelect:
+		return optionValue(field, value)
+	case fieldTypeMultiSelect:
+		ids := strings.FieldsFunc(strings.Trim(value, "[]"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+
+		values := make([]string, 0, len(ids))
+		for _, id := range ids {
+			values = append(values, optionValue(field, strings.Trim(id, `"`)))
+		}
+		sort.Strings(values)
+
+		return strings.Join(values, ",")
+	case fieldTypeCheckbox:
+		switch strings.TrimSpace(value) {
+		case "1", "true", "on":
+			return "true"
+		case "", "0", "false", "off":
+			return "false"
+		}
+	case fieldTypeDate:
+		if t, ok := parseFieldDate(value); ok {
+			return t.Format("2006-01-02")
+		}
+	case fieldTypeNumber:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+
+	return normalizeText(value)
+}
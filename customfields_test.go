@@ -0,0 +1,59 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+func TestCustomFieldLabelAvoidsExporterLabels(t *testing.T) {
+	config = Config{FieldLabelPrefix: "cf_", OrganizationFieldLabels: map[int]string{3: "support_tier"}}
+	t.Cleanup(func() { config = Config{} })
+
+	resolveFieldLabels([]*supportpal.CustomField{
+		{ID: 1, Name: "Support Tier"},
+		{ID: 2, Name: "Organization ID"},
+		{ID: 3, Name: "Status"},
+	})
+	for id, want := range map[int]string{1: "cf_support_tier", 2: "cf_organization_id", 3: "cf_status"} {
+		field := &supportpal.GetCustomFieldResponse{Data: supportpal.CustomField{ID: id}}
+		if got := customFieldLabel(field); got != want {
+			t.Errorf("label of custom field %d = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestResolveFieldLabels(t *testing.T) {
+	config = Config{FieldLabelPrefix: "cf_", FieldNames: map[int]string{9: "region"}}
+	t.Cleanup(func() { config = Config{} })
+
+	fields := []*supportpal.CustomField{
+		{ID: 3, Name: "Region"},
+		{ID: 5, Name: "Region"},
+		{ID: 7, Name: "Region 5"},
+		{ID: 9, Name: "Team"},
+	}
+	// the override keeps its name, the fields with its slug get their ID
+	// appended, but not the name of another field
+	want := map[int]string{3: "region_3", 5: "region_5_5", 7: "region_5", 9: "region"}
+
+	reversed := slices.Clone(fields)
+	slices.Reverse(reversed)
+	for _, order := range [][]*supportpal.CustomField{fields, reversed} {
+		resolveFieldLabels(order)
+		got := make(map[int]string)
+		for _, field := range fields {
+			got[field.ID] = customFieldLabel(&supportpal.GetCustomFieldResponse{Data: *field})
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("labels = %v, want %v", got, want)
+		}
+	}
+
+	// a field created since is named against the resolved names
+	if got := customFieldLabel(&supportpal.GetCustomFieldResponse{Data: supportpal.CustomField{ID: 11, Name: "Region"}}); got != "region_11" {
+		t.Errorf("label of a new field = %q, want region_11", got)
+	}
+}
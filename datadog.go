@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// datadogBatchSize is the number of series submitted per request, keeping
+// requests well below the payload limit of the Datadog API
+const datadogBatchSize = 1000
+
+// datadogGauge is the gauge metric type of the Datadog series API
+const datadogGauge = 3
+
+// datadogSink submits all metrics to the Datadog series API after every
+// cycle
+type datadogSink struct {
+	url        string
+	apiKeyFile string
+	host       string
+	client     *http.Client
+	gatherer   prometheus.Gatherer
+}
+
+// datadogSeries is a series of a Datadog v2 series submission
+type datadogSeries struct {
+	Metric    string            `json:"metric"`
+	Type      int               `json:"type"`
+	Points    []datadogPoint    `json:"points"`
+	Tags      []string          `json:"tags,omitempty"`
+	Resources []datadogResource `json:"resources,omitempty"`
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Name implements Sink
+func (datadogSink) Name() string { return "datadog" }
+
+// Publish implements Sink. Runtime metrics of the Go process are left out
+// like in the textfile.
+func (d datadogSink) Publish(s *snapshot) error {
+	families, err := d.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	series := datadogMetrics(withoutRuntimeMetrics(families), d.host, time.Now())
+	for batch := range slices.Chunk(series, datadogBatchSize) {
+		if err := d.submit(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submit sends series gzip compressed to the series API
+func (d datadogSink) submit(series []datadogSeries) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(map[string][]datadogSeries{"series": series}); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// the key file is read for every submission, so a rotated key is
+	// picked up
+	key, err := os.ReadFile(d.apiKeyFile)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("DD-API-KEY", strings.TrimSpace(string(key)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("datadog: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// datadogMetrics returns the series of the families as Datadog gauges named
+// like the Prometheus series, with the labels as name:value tags. Counters
+// are submitted with their cumulative value as gauges as well, Datadog
+// counts are increments per submission. Empty labels and NaN and infinite
+// values, which Datadog rejects, are left out.
+func datadogMetrics(families []*dto.MetricFamily, host string, now time.Time) []datadogSeries {
+	var series []datadogSeries
+	eachSample(families, nil, now, func(sample remoteSample, timestamp int64) {
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			return
+		}
+
+		var tags []string
+		for _, name := range slices.Sorted(maps.Keys(sample.labels)) {
+			if name != "__name__" && sample.labels[name] != "" {
+				tags = append(tags, name+":"+sample.labels[name])
+			}
+		}
+
+		s := datadogSeries{
+			Metric: sample.labels["__name__"],
+			Type:   datadogGauge,
+			Points: []datadogPoint{{Timestamp: timestamp / 1000, Value: sample.value}},
+			Tags:   tags,
+		}
+		if host != "" {
+			s.Resources = []datadogResource{{Name: host, Type: "host"}}
+		}
+		series = append(series, s)
+	})
+
+	return series
+}
+
+// newDatadogSink returns the Datadog sink of the configuration
+func newDatadogSink(gatherer prometheus.Gatherer) datadogSink {
+	host, _ := os.Hostname()
+
+	return datadogSink{
+		url:        "https://api." + config.DatadogSite + "/api/v2/series",
+		apiKeyFile: config.DatadogAPIKeyFile,
+		host:       host,
+		client:     &http.Client{Timeout: pushTimeout},
+		gatherer:   gatherer,
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDatadogSink(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	created := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "supportpal_tickets_created_total", Help: "Created"}, []string{"department", "priority"})
+	created.WithLabelValues("Sales", "").Add(3)
+	open := prometheus.NewGauge(prometheus.GaugeOpts{Name: "supportpal_tickets_open", Help: "Open"})
+	open.Set(5)
+	reg.MustRegister(created, open)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var series []datadogSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DD-API-KEY") != "secret" {
+			t.Errorf("DD-API-KEY = %q", r.Header.Get("DD-API-KEY"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct{ Series []datadogSeries }
+		if err := json.NewDecoder(zr).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		series = append(series, body.Series...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+
+	before := time.Now().Unix()
+	sink := datadogSink{url: srv.URL, apiKeyFile: keyFile, host: "exporter", client: srv.Client(), gatherer: reg}
+	if err := sink.Publish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(series) != 2 {
+		t.Fatalf("series = %+v, want 2", series)
+	}
+	slices.SortFunc(series, func(a, b datadogSeries) int { return len(a.Tags) - len(b.Tags) })
+
+	// counters are gauges of their cumulative value, the empty priority is
+	// left out
+	for i, want := range []struct {
+		metric string
+		tags   []string
+		value  float64
+	}{
+		{"supportpal_tickets_open", nil, 5},
+		{"supportpal_tickets_created_total", []string{"department:Sales"}, 3},
+	} {
+		s := series[i]
+		if s.Metric != want.metric || s.Type != datadogGauge || !slices.Equal(s.Tags, want.tags) {
+			t.Errorf("series %+v, want %s with tags %v", s, want.metric, want.tags)
+		}
+		if len(s.Points) != 1 || s.Points[0].Value != want.value || s.Points[0].Timestamp < before {
+			t.Errorf("%s: points %+v, want %v now", s.Metric, s.Points, want.value)
+		}
+		if len(s.Resources) != 1 || s.Resources[0] != (datadogResource{Name: "exporter", Type: "host"}) {
+			t.Errorf("%s: resources %+v", s.Metric, s.Resources)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	_ "net/http/pprof" // registers the /debug/pprof/ handlers on the default mux
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// pprofGate hides the pprof handlers registered on the default mux unless
+// profiling is enabled. Profiles expose memory contents and can be expensive,
+// so they are never served by default.
+func pprofGate(next http.Handler, enabled bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enabled && strings.HasPrefix(r.URL.Path, "/debug/pprof") {
+			http.NotFound(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerRuntimeMetrics makes sure the Go runtime and process collectors
+// are registered. With detailed set, the Go collector also exports the
+// runtime/metrics of the Go version the exporter was built with, such as GC
+// pause and heap object size histograms.
+func registerRuntimeMetrics(detailed bool) {
+	goCollector := collectors.NewGoCollector()
+	if detailed {
+		prometheus.Unregister(goCollector)
+		goCollector = collectors.NewGoCollector(collectors.WithGoCollections(
+			collectors.GoRuntimeMemStatsCollection | collectors.GoRuntimeMetricsCollection))
+	}
+
+	for _, c := range []prometheus.Collector{goCollector, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})} {
+		var registered prometheus.AlreadyRegisteredError
+		if err := prometheus.Register(c); err != nil && !errors.As(err, &registered) {
+			fatal("failed to register runtime metrics", "err", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// metricRename is a metric exported under a new name. Until the old name is
+// removed, the metric is exported under both names with
+// --metrics.deprecated-names, so dashboards and alerts can be migrated
+// without a flag day.
+type metricRename struct {
+	Old string
+	New string
+	// Since is the version that renamed the metric
+	Since string
+}
+
+// metricRenames lists the renamed metrics whose old names are still
+// exported. An entry is removed together with the old name, usually two
+// releases after Since.
+var metricRenames = []metricRename{
+	{Old: "supportpal_ticket_created", New: "supportpal_ticket_created_timestamp_seconds", Since: "unreleased"},
+	{Old: "supportpal_ticket_updated", New: "supportpal_ticket_updated_timestamp_seconds", Since: "unreleased"},
+	{Old: "supportpal_ticket_deleted", New: "supportpal_ticket_deleted_timestamp_seconds", Since: "unreleased"},
+	{Old: "supportpal_ticket_resolved", New: "supportpal_ticket_resolved_timestamp_seconds", Since: "unreleased"},
+	{Old: "supportpal_users_total", New: "supportpal_users", Since: "unreleased"},
+	{Old: "supportpal_operators_total", New: "supportpal_operators", Since: "unreleased"},
+	{Old: "supportpal_kb_article_views_total", New: "supportpal_kb_article_views", Since: "unreleased"},
+}
+
+var supportPalDeprecatedMetricScraped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_deprecated_metric_scraped_total",
+	Help: "Number of scrapes and exports that received a metric under its deprecated name",
+}, []string{"name"})
+
+// deprecatedName returns the old name of a renamed metric, also of metrics
+// renamed to the prefix of a job
+func deprecatedName(name string) (string, bool) {
+	for _, r := range metricRenames {
+		if name == r.New {
+			return r.Old, true
+		}
+
+		for _, j := range config.Jobs {
+			if name == j.Prefix+strings.TrimPrefix(r.New, "supportpal") {
+				return j.Prefix + strings.TrimPrefix(r.Old, "supportpal"), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// deprecatedNameGatherer adds a copy of every renamed metric family under
+// its old name to the families gathered from the wrapped gatherer
+type deprecatedNameGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (g deprecatedNameGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	var deprecated []*dto.MetricFamily
+	for _, family := range families {
+		old, ok := deprecatedName(family.GetName())
+		if !ok {
+			continue
+		}
+
+		// the metrics are shared, later wrappers see them twice
+		deprecated = append(deprecated, &dto.MetricFamily{
+			Name:   proto.String(old),
+			Help:   proto.String("Deprecated, renamed to " + family.GetName() + ": " + family.GetHelp()),
+			Type:   family.Type,
+			Metric: family.Metric,
+		})
+		supportPalDeprecatedMetricScraped.WithLabelValues(old).Inc()
+	}
+
+	if len(deprecated) == 0 {
+		return families, err
+	}
+
+	families = append(families, deprecated...)
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families, err
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var supportPalDuplicateTickets = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_probable_duplicate_tickets_total",
+	Help: "Number of tickets created by the same requester with a similar subject shortly after another ticket",
+}, []string{"department"})
+
+// replyPrefixes are stripped from subjects before comparing them
+var replyPrefixes = []string{"re", "fw", "fwd", "aw", "wg", "sv", "tr"}
+
+// normalizeSubject returns the lowercase words of a subject without reply
+// and forward prefixes
+func normalizeSubject(subject string) []string {
+	words := strings.FieldsFunc(strings.ToLower(subject), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for len(words) > 0 && slices.Contains(replyPrefixes, words[0]) {
+		words = words[1:]
+	}
+
+	return words
+}
+
+// subjectSimilarity returns the Jaccard similarity of the words of two
+// normalized subjects, 1 for identical ones
+func subjectSimilarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	words := make(map[string]int)
+	for _, w := range a {
+		words[w] |= 1
+	}
+	for _, w := range b {
+		words[w] |= 2
+	}
+
+	shared := 0
+	for _, in := range words {
+		if in == 3 {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(words))
+}
+
+// requester identifies the user who opened a ticket
+func requester(ticket *supportpal.Ticket) string {
+	if ticket.User.ID != 0 {
+		return strconv.Itoa(ticket.User.ID)
+	}
+	return ticket.User.FormattedName
+}
+
+// duplicateCandidate holds the fields of a ticket compared to find
+// duplicates, far smaller than the ticket itself
+type duplicateCandidate struct {
+	ID         int
+	Created    int64
+	Requester  string
+	Department string
+	URL        string
+	// Subject holds the normalized words of the subject
+	Subject []string
+}
+
+// newDuplicateCandidate returns the duplicate candidate of ticket, with the
+// department label of its built series
+func newDuplicateCandidate(ticket *supportpal.Ticket, series *ticketSeries) duplicateCandidate {
+	return duplicateCandidate{
+		ID:         ticket.ID,
+		Created:    ticket.CreatedAt,
+		Requester:  requester(ticket),
+		Department: series.Department,
+		URL:        ticket.OperatorURL,
+		Subject:    normalizeSubject(ticket.Subject),
+	}
+}
+
+// duplicateTracker counts every probable duplicate ticket once across
+// collection cycles. Until it is primed, duplicates are only recorded as
+// seen.
+type duplicateTracker struct {
+	mu     sync.Mutex
+	seen   map[int]int64
+	primed bool
+}
+
+var ticketDuplicates = &duplicateTracker{seen: make(map[int]int64)}
+
+// observe counts the tickets that were created within window after a ticket
+// of the same requester with a subject at least as similar as threshold
+func (t *duplicateTracker) observe(tickets []duplicateCandidate, window time.Duration, threshold float64) {
+	byRequester := make(map[string][]duplicateCandidate)
+	for _, ticket := range tickets {
+		byRequester[ticket.Requester] = append(byRequester[ticket.Requester], ticket)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, group := range byRequester {
+		sort.Slice(group, func(i, j int) bool { return group[i].Created < group[j].Created })
+
+		for j := 1; j < len(group); j++ {
+			if _, ok := t.seen[group[j].ID]; ok {
+				continue
+			}
+
+			for i := j - 1; i >= 0 && group[j].Created-group[i].Created <= int64(window.Seconds()); i-- {
+				if subjectSimilarity(group[i].Subject, group[j].Subject) >= threshold {
+					t.seen[group[j].ID] = group[j].Created
+					if !t.primed {
+						break
+					}
+					incTicketCounter(supportPalDuplicateTickets.WithLabelValues(group[j].Department), group[j].ID, group[j].URL)
+					break
+				}
+			}
+		}
+	}
+}
+
+// prime starts counting the duplicates observed from now on
+func (t *duplicateTracker) prime() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.primed = true
+}
+
+// export returns a copy of the counted duplicates for persistence
+func (t *duplicateTracker) export() map[int]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return maps.Clone(t.seen)
+}
+
+// restore replaces the counted duplicates with persisted ones
+func (t *duplicateTracker) restore(seen map[int]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seen = make(map[int]int64, len(seen))
+	maps.Copy(t.seen, seen)
+	t.primed = true
+}
+
+// prune forgets duplicates created before cutoff, they are no longer collected
+func (t *duplicateTracker) prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, created := range t.seen {
+		if created < cutoff.Unix() {
+			delete(t.seen, id)
+		}
+	}
+}
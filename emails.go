@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var supportPalEmailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_emails_sent_total",
+	Help: "Number of emails SupportPal sent according to the email log",
+}, nil)
+
+var supportPalEmailsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_emails_received_total",
+	Help: "Number of emails piped into SupportPal according to the email log",
+}, nil)
+
+var supportPalEmailFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_email_failures_total",
+	Help: "Number of emails SupportPal failed to send or to process according to the email log",
+}, []string{"direction"})
+
+var supportPalEmailLastSent = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "supportpal_email_last_sent_timestamp_seconds",
+	Help: unitHelp("Creation time of the newest email SupportPal sent since the exporter started", units["timestamp_seconds"]),
+})
+
+// emailTracker counts every email log entry once across collection cycles
+type emailTracker struct {
+	mu sync.Mutex
+	// lastID is the newest counted entry, 0 until the first cycle found
+	// where counting starts
+	lastID int
+	// lastSent is the creation time of the newest sent email
+	lastSent int64
+}
+
+var emailLog = &emailTracker{}
+
+// update counts the email log entries logged since the previous cycle. The
+// first cycle only remembers the newest entry, the counters start at 0 like
+// the other counters instead of counting the whole log. Failures are logged
+// and the entries are counted by a later cycle.
+func (t *emailTracker) update() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lastID == 0 {
+		id, err := apiClient.LastEmailLogID()
+		if err != nil {
+			slog.Error("failed to read the email log", "err", err)
+			collectionError("emails")
+			return
+		}
+
+		t.lastID = id
+		// an empty log counts from its first entry
+		if t.lastID == 0 {
+			t.lastID = -1
+		}
+		return
+	}
+
+	entries, err := apiClient.ListEmailLogs(t.lastID)
+	if err != nil {
+		slog.Error("failed to list the email log", "err", err)
+		collectionError("emails")
+		return
+	}
+
+	for _, entry := range entries {
+		if countEmail(entry) && entry.CreatedAt > t.lastSent {
+			t.lastSent = entry.CreatedAt
+			supportPalEmailLastSent.Set(float64(entry.CreatedAt))
+		}
+		t.lastID = max(t.lastID, entry.ID)
+	}
+}
+
+// countEmail counts a single email log entry and reports whether it is a
+// sent email
+func countEmail(entry *supportpal.EmailLog) bool {
+	direction := "incoming"
+	if entry.Type == supportpal.EmailOutgoing {
+		direction = "outgoing"
+	}
+
+	if entry.Status == supportpal.EmailFailed {
+		supportPalEmailFailures.WithLabelValues(direction).Inc()
+		return false
+	}
+
+	if direction == "incoming" {
+		supportPalEmailsReceived.WithLabelValues().Inc()
+		return false
+	}
+
+	supportPalEmailsSent.WithLabelValues().Inc()
+	return true
+}
+
+// export returns the newest counted entry for persistence
+func (t *emailTracker) export() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.lastID
+}
+
+// restore continues counting after a persisted entry
+func (t *emailTracker) restore(lastID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastID = lastID
+}
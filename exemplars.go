@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ticketExemplar returns the exemplar labels linking a sample to a ticket.
+// The URL is left out when it would exceed the rune limit of exemplars.
+func ticketExemplar(id int, url string) prometheus.Labels {
+	labels := prometheus.Labels{"ticket_id": strconv.Itoa(id)}
+
+	runes := utf8.RuneCountInString("ticket_id") + utf8.RuneCountInString(labels["ticket_id"])
+	if url != "" && runes+utf8.RuneCountInString("ticket_url")+utf8.RuneCountInString(url) <= prometheus.ExemplarMaxRunes {
+		labels["ticket_url"] = url
+	}
+
+	return labels
+}
+
+// incTicketCounter increments counter, with an exemplar linking to the ticket if enabled
+func incTicketCounter(counter prometheus.Counter, id int, url string) {
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && config.Exemplars {
+		adder.AddWithExemplar(1, ticketExemplar(id, url))
+		return
+	}
+
+	counter.Inc()
+}
@@ -1,398 +1,970 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
-	"log"
+	"errors"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
-	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/gosimple/slug"
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/collectors"
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var organizationCache = make(map[int]Organization)
+// apiClient is the SupportPal API client. Tickets are read through source,
+// the client is used directly by the collectors specific to SupportPal.
+var apiClient *supportpal.Client
 
-// requestAPI is a helper function to make an API request that accepts method, url, and body
-func requestAPI(method, url string, body []byte) ([]byte, error) {
-	baseURL := os.Getenv("API_BASE_PATH")
+// Collectors of pkg/collectors, created by initializeMetrics with apiClient
+var (
+	supportPalArticles  *collectors.Articles
+	supportPalInventory *collectors.Inventory
+	supportPalBackend   *collectors.Backend
+	supportPalCatalog   *collectors.Catalog
+)
+
+// CommonLabels is a map of labels that are common to all tickets
+var CommonLabels = []string{"client", "status", "priority", "user", "subject", "ticket_url", "frontend_url", "in_maintenance", "channel"}
+
+var (
+	supportPalTickets = &ticketCollector{}
+
+	// supportPalCollectionDuration depends on the configured duration unit
+	// and is created in initializeMetrics
+	supportPalCollectionDuration prometheus.Gauge
+	globaLabels                  = []string{}
+	// labelLastSeen holds the time every label of globaLabels was last used
+	// by a ticket, to keep removed labels for config.RemovedLabelGrace
+	labelLastSeen = make(map[string]time.Time)
+	// deprecatedLabels holds the labels kept in the schema of the last cycle
+	// although no ticket used them
+	deprecatedLabels map[string]time.Time
+
+	supportPalUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_up",
+		Help: "Whether the last collection from the SupportPal API succeeded",
+	})
+
+	supportPalCircuitBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_circuit_breaker_state",
+		Help: "State of the API circuit breaker (0 closed, 1 open, 2 half-open)",
+	})
+
+	supportPalMaintenance = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_maintenance_active",
+		Help: "Whether a configured maintenance window is currently active",
+	})
+
+	supportPalCollectionSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "supportpal_collection_skipped_total",
+		Help: "Number of collection cycles skipped because the previous cycle was still running",
+	})
+)
 
-	if baseURL[len(baseURL)-1:] == "/" {
-		baseURL = baseURL[:len(baseURL)-1]
+// ticketInSyncBound reports whether the ticket is within the configured sync bounds
+func ticketInSyncBound(ticket *supportpal.Ticket) bool {
+	if ticket.ID < config.SyncMinTicketID {
+		return false
 	}
 
-	url = baseURL + url
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
+	return config.SyncSince.IsZero() || ticket.CreatedAt >= config.SyncSince.Unix()
+}
+
+// ticketSelected reports whether the ticket passes the configured ticket
+// filters. The API applies the status and department filters as well, they
+// are checked again in case it ignores them. The API has no filter for
+// trashed tickets, they are dropped here only.
+func ticketSelected(ticket *supportpal.Ticket) bool {
+	if len(config.StatusIDs) > 0 && !slices.Contains(config.StatusIDs, ticket.Status.ID) {
+		return false
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(os.Getenv("API_TOKEN"), "X")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if len(config.DepartmentIDs) > 0 && !slices.Contains(config.DepartmentIDs, ticket.Department.ID) {
+		return false
 	}
-	defer resp.Body.Close()
 
-	return ioutil.ReadAll(resp.Body)
-}
+	if slices.Contains(config.ExcludeStatusIDs, ticket.Status.ID) || !config.Shard.Contains(ticket.ID) {
+		return false
+	}
 
-// Ticket represents the response from the API
-type Ticket struct {
-	ID      int    `json:"id"`
-	Subject string `json:"subject"`
-	Status  struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	} `json:"status"`
-	Priority struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	} `json:"priority"`
-	User struct {
-		FormattedName  string `json:"formatted_name"`
-		OrganizationID int    `json:"organisation_id"`
-	}
-	CreatedAt    int64  `json:"created_at"`
-	UpdatedAt    int64  `json:"updated_at"`
-	DeletedAt    int64  `json:"deleted_at"`
-	ResolvedTime int64  `json:"resolved_time"`
-	OperatorURL  string `json:"operator_url"`
-	FrontendURL  string `json:"frontend_url"`
-	CustomFields []*struct {
-		ID      int    `json:"id"`
-		FieldID int    `json:"field_id"`
-		Value   string `json:"value"`
-	} `json:"customfields"`
+	return config.Trashed != trashedDrop || ticket.DeletedAt == 0
 }
 
-// respListTickets represents the response body for listing tickets
-type respListTickets struct {
-	Status  string    `json:"status"`
-	Message string    `json:"message"`
-	Count   int       `json:"count"`
-	Data    []*Ticket `json:"data"`
+// ticketFilter returns the query parameters of the configured ticket filters.
+// Lists use the array syntax of the API, e.g. status[]=1&status[]=2. The API
+// cannot exclude statuses, so excluded statuses are left out of the selected
+// statuses or, without selected statuses, out of the given list of all
+// statuses. Without that list they are only filtered by the exporter.
+func ticketFilter(statuses []*supportpal.TicketOption) url.Values {
+	ids := config.StatusIDs
+	if len(ids) == 0 && len(config.ExcludeStatusIDs) > 0 {
+		for _, status := range statuses {
+			ids = append(ids, status.ID)
+		}
+	}
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id int) bool {
+		return slices.Contains(config.ExcludeStatusIDs, id)
+	})
+
+	filter := url.Values{}
+	for _, id := range ids {
+		filter.Add("status[]", strconv.Itoa(id))
+	}
+	for _, id := range config.DepartmentIDs {
+		filter.Add("department[]", strconv.Itoa(id))
+	}
+	return filter
 }
 
-// listTickets is a helper function to list tickets with start and limit
-func listTickets(start, limit int) (*respListTickets, error) {
-	url := "/api/ticket/ticket?order_direction=desc&start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
-	resp, err := requestAPI("GET", url, nil)
+// refreshTicketFilter sends the excluded statuses to the API as the list of
+// the other statuses at the start of a cycle, so statuses added by admins
+// are collected. If the statuses cannot be listed, the filter of the
+// previous cycle is kept.
+func refreshTicketFilter() {
+	if len(config.StatusIDs) > 0 || len(config.ExcludeStatusIDs) == 0 {
+		return
+	}
 
+	statuses, err := apiClient.ListStatuses()
 	if err != nil {
-		return nil, err
+		slog.Warn("failed to list ticket statuses, keeping the ticket filter", "err", err)
+		return
 	}
+	apiClient.SetTicketFilter(ticketFilter(statuses))
+}
 
-	var tickets respListTickets
-	err = json.Unmarshal(resp, &tickets)
-	if err != nil {
-		return nil, err
+// ticketExpired reports whether the ticket is older than 1 year and ignored
+func ticketExpired(ticket *supportpal.Ticket) bool {
+	return time.Unix(ticket.CreatedAt, 0).AddDate(1, 0, 0).Before(time.Now())
+}
+
+// collectionInterval is the time between two collection cycles
+const collectionInterval = 60 * time.Second
+
+func collectMetrics() {
+	breaker := newCircuitBreaker(config.BreakerFailures, config.BreakerCooldown)
+	policy := newFailurePolicy(config.FailOn, config.FailOnThreshold)
+
+	interval := pollInterval()
+
+	// cycles start every interval, a cycle still running when the next is
+	// due skips it instead of running twice concurrently
+	run := func() {
+		// a standby serves the state of the leader
+		if !leading.Load() {
+			return
+		}
+		if !collectionRunning.CompareAndSwap(false, true) {
+			slog.Warn("previous collection cycle still running, skipping collection")
+			supportPalCollectionSkipped.Inc()
+			return
+		}
+
+		go func() {
+			defer collectionRunning.Store(false)
+			tracer.startCycle()
+			collectCycle(breaker, policy)
+			tracer.endCycle(cycleAttributes())
+			supportPalCircuitBreakerState.Set(float64(breaker.state))
+			notifier.cycleDone()
+		}()
 	}
 
-	return &tickets, nil
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	run()
+	for range ticker.C {
+		run()
+	}
 }
 
-// Organization represents an organization
-type Organization struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+// pollInterval returns the time between the starts of collection cycles
+func pollInterval() time.Duration {
+	// with webhooks the poll only reconciles missed events
+	if config.WebhookSecret != "" {
+		return config.WebhookReconcileInterval
+	}
+	return collectionInterval
 }
 
-// respGetOrganization represents the response body for getting an organization
-type respGetOrganization struct {
-	Status  string        `json:"status"`
-	Message string        `json:"message"`
-	Data    *Organization `json:"data"`
+// pacingWindow returns the time the requests of a cycle are spread over,
+// the share of --api.pacing of the poll interval or of the collection
+// timeout if it is shorter, so paced cycles are not aborted
+func pacingWindow() time.Duration {
+	window := pollInterval()
+	if config.CollectionTimeout > 0 && config.CollectionTimeout < window {
+		window = config.CollectionTimeout
+	}
+	return time.Duration(float64(window) * config.APIPacing)
 }
 
-// getOrganization is a helper function to get an organization
-func getOrganization(id int) (*respGetOrganization, error) {
-	if ok := organizationCache[id]; ok != (Organization{}) {
-		return &respGetOrganization{
-			Status:  "success",
-			Message: "",
-			Data:    &ok,
-		}, nil
+// collectionRunning is set while a collection cycle runs
+var collectionRunning atomic.Bool
+
+// collectCycle runs a single collection cycle guarded by the circuit breaker.
+// On failure the metrics of the last successful cycle are kept and the
+// failure policy decides whether the exporter exits.
+func collectCycle(breaker *circuitBreaker, policy *failurePolicy) {
+	if inMaintenance(time.Now()) {
+		supportPalMaintenance.Set(1)
+	} else {
+		supportPalMaintenance.Set(0)
+	}
+
+	if !breaker.allow(time.Now()) {
+		slog.Warn("circuit breaker is open, skipping collection")
+		supportPalDataStale.Set(1)
+		return
 	}
 
-	url := "/api/user/organisation/" + strconv.Itoa(id)
-	resp, err := requestAPI("GET", url, nil)
+	cycleErrors.Store(0)
+	report := newCycleReport()
+	defer report.finish()
 
-	if err != nil {
-		return nil, err
+	// requests still running at the deadline fail the cycle, the metrics of
+	// the last successful cycle are kept
+	if config.CollectionTimeout > 0 {
+		apiClient.SetDeadline(time.Now().Add(config.CollectionTimeout))
+		defer apiClient.SetDeadline(time.Time{})
+	}
+
+	if window := pacingWindow(); window > 0 {
+		apiClient.Pace(window)
+		defer apiClient.Pace(0)
+	}
+
+	if breaker.state == breakerHalfOpen {
+		slog.Info("circuit breaker is half-open, probing API")
+
+		if _, err := source.ListTickets(0, 1); err != nil {
+			slog.Error("circuit breaker probe failed", "err", err)
+			report.Error = err.Error()
+			collectionError("tickets")
+			policy.observe(err)
+			breaker.failure(time.Now())
+			supportPalUp.Set(0)
+			supportPalDataStale.Set(1)
+			return
+		}
+	}
+
+	slog.Info("collecting metrics")
+	start := time.Now()
+
+	refreshFieldLabels()
+	refreshTicketFilter()
+	builder := newSnapshotBuilder()
+	err := source.StreamTickets(ticketInSyncBound, builder.add)
+
+	// the tickets of skipped pages are missing, the others are published
+	// and the data marked stale
+	var pageErr *supportpal.PageError
+	if errors.As(err, &pageErr) {
+		slog.Warn("ticket pages skipped", "pages", len(pageErr.Starts), "err", pageErr.Err)
+		report.Error = err.Error()
+		collectionError("pages")
+		err = nil
 	}
 
-	var organization respGetOrganization
-	err = json.Unmarshal(resp, &organization)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		slog.Error("failed to list tickets", "err", err, "duration", time.Since(start))
+		report.Error = err.Error()
+		collectionError("tickets")
+		policy.observe(err)
+		breaker.failure(time.Now())
+		if breaker.state == breakerOpen {
+			slog.Warn("circuit breaker opened", "failures", breaker.failures)
+		}
+		supportPalUp.Set(0)
+		supportPalDataStale.Set(1)
+		builder.discard()
+		return
+	}
+
+	breaker.success()
+	policy.observe(nil)
+	supportPalUp.Set(1)
+
+	slog.Info("listed tickets", "tickets", builder.listed, "duration", time.Since(start))
+	report.Succeeded = true
+	report.Tickets = builder.listed
+
+	publishStart := time.Now()
+	builder.publish()
+	tracer.span("publish", publishStart, map[string]string{"supportpal.tickets": strconv.Itoa(builder.listed)})
+
+	if config.Inventory {
+		if err := supportPalInventory.UpdateUsers(); err != nil {
+			slog.Error("failed to count users", "err", err)
+			collectionError("users")
+		}
+		if err := supportPalInventory.UpdateOperators(time.Now()); err != nil {
+			slog.Error("failed to list operators", "err", err)
+			collectionError("operators")
+		}
+	}
+
+	if config.Articles {
+		if err := supportPalArticles.Update(); err != nil {
+			slog.Error("failed to list articles", "err", err)
+			collectionError("articles")
+		}
+	}
+
+	if config.Emails {
+		emailLog.update()
+	}
+
+	if config.Backend {
+		if err := supportPalBackend.Update(); err != nil {
+			slog.Error("failed to read the core settings", "err", err)
+			collectionError("settings")
+		}
+	}
+
+	if config.Catalog {
+		if err := supportPalCatalog.Update(); err != nil {
+			slog.Error("failed to list the ticket statuses, priorities and departments", "err", err)
+			collectionError("catalog")
+		}
 	}
 
-	organizationCache[id] = *organization.Data
+	if cycleErrors.Load() > 0 {
+		supportPalDataStale.Set(1)
+	} else {
+		supportPalDataStale.Set(0)
+	}
 
-	return &organization, nil
+	supportPalCollectionDuration.Set(durationValue(time.Since(start)))
+	lastCycleDuration.Store(int64(time.Since(start)))
+	slog.Info("collected metrics", "duration", time.Since(start))
 }
 
-// respGetCustomField represents the response body for getting a custom field
-type respGetCustomField struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		ID      int    `json:"id"`
-		Name    string `json:"name"`
-		Type    int    `json:"type"`
-		Options []struct {
-			ID    int    `json:"id"`
-			Value string `json:"value"`
-		} `json:"options"`
-	} `json:"data"`
+// snapshotBuilder builds the snapshot of a collection cycle from tickets
+// passed one at a time while the ticket pages are fetched, so the tickets of
+// a cycle are never held in memory as a whole. The snapshot is published to
+// the sinks once complete, so scrapes never see a partially built set of
+// metrics.
+type snapshotBuilder struct {
+	entries    []ticketEntry
+	feedback   map[int]*supportpal.Feedback
+	duplicates []duplicateCandidate
+	// jobs holds the entries of every job in the order of config.Jobs
+	jobs [][]ticketEntry
+	// labels is the label schema of the selected tickets
+	labels []string
+	// listed is the number of selected tickets passed to add
+	listed int
 }
 
-var customFieldCache = make(map[int]*respGetCustomField)
+// newSnapshotBuilder returns the builder of a cycle, fetching the feedback
+// of the tickets first when enabled
+func newSnapshotBuilder() *snapshotBuilder {
+	b := &snapshotBuilder{jobs: make([][]ticketEntry, len(config.Jobs)), labels: labelSchema(nil)}
+	if config.Feedback {
+		b.feedback = fetchFeedback()
+	}
+
+	return b
+}
 
-// getCustomField is a helper function to get a custom field
-func getCustomField(id int) (*respGetCustomField, error) {
-	if ok := customFieldCache[id]; ok != nil {
-		return ok, nil
+// add builds a ticket unless it is filtered out or expired
+func (b *snapshotBuilder) add(ticket *supportpal.Ticket) {
+	if !ticketSelected(ticket) {
+		return
 	}
-	url := "/api/ticket/customfield/" + strconv.Itoa(id)
-	resp, err := requestAPI("GET", url, nil)
+	b.listed++
+	b.labels = extendLabelSchema(b.labels, ticket)
 
-	if err != nil {
-		return nil, err
+	if ticketExpired(ticket) {
+		return
 	}
 
-	var customField respGetCustomField
-	err = json.Unmarshal(resp, &customField)
+	labels, series := buildTicket(ticket)
+
+	if config.DuplicateWindow > 0 {
+		b.duplicates = append(b.duplicates, newDuplicateCandidate(ticket, series))
+	}
 
-	if err == nil {
-		customFieldCache[id] = &customField
+	if f, ok := b.feedback[ticket.ID]; ok {
+		series.Rated = true
+		series.Rating = f.Rating
+		series.RatedOperator = operatorName(f.Operator.FormattedName)
 	}
 
-	return &customField, nil
+	b.entries = append(b.entries, ticketEntry{labels, series})
+	for i, j := range config.Jobs {
+		if j.selects(ticket) {
+			b.jobs[i] = append(b.jobs[i], ticketEntry{labels, series})
+		}
+	}
 }
 
-// fetchAllTickets is a helper function to fetch all tickets and return a slice of Ticket
-func fetchAllTickets() ([]*Ticket, error) {
-	var tickets []*Ticket
-	start := 0
-	limit := 100
-	for {
-		ticketsResponse, err := listTickets(start, limit)
+// publish builds the snapshot of the added tickets and publishes it
+func (b *snapshotBuilder) publish() {
+	if config.Feedback && b.feedback == nil {
+		keepRatings(b.entries)
+	}
 
-		if err != nil {
-			return nil, err
+	// the lifecycle events and messages are counted for complete cycles
+	// only, a failed cycle counts them with the next
+	for _, e := range b.entries {
+		ticketLifecycle.observe(e.series, e.labels["priority"])
+		operatorReplies.observe(e.series)
+	}
+
+	var deprecated map[string]time.Time
+	globaLabels, deprecated = mergeSchema(globaLabels, b.labels)
+
+	// the job snapshots are built first, addTickets releases the labels
+	jobSnaps := make([]*snapshot, len(config.Jobs))
+	for i, j := range config.Jobs {
+		jobSnaps[i] = j.build(b.jobs[i], deprecated)
+	}
+
+	snap := newSnapshot(globaLabels)
+	snap.Deprecated = deprecated
+	addTickets(snap, b.entries)
+
+	if last := supportPalTickets.current.Load(); last != nil {
+		countPurged(last, snap)
+		if config.SeriesDiff {
+			logSeriesDiff(last, snap)
 		}
+	}
 
-		tickets = append(tickets, ticketsResponse.Data...)
+	if config.DuplicateWindow > 0 {
+		ticketDuplicates.observe(b.duplicates, config.DuplicateWindow, config.DuplicateSimilarity)
+	}
 
-		if ticketsResponse.Count <= len(tickets) {
-			break
+	if config.Assignments {
+		snap.OperatorGroups = fetchOperatorGroups()
+		if last := supportPalTickets.current.Load(); snap.OperatorGroups == nil && last != nil {
+			snap.OperatorGroups = last.OperatorGroups
 		}
+	}
+
+	operatorReplies.prune(time.Now().AddDate(-1, 0, 0))
+	ticketLifecycle.prune(time.Now().AddDate(-1, 0, 0))
+	ticketDuplicates.prune(time.Now().AddDate(-1, 0, 0))
 
-		start += limit
+	for i, j := range config.Jobs {
+		jobSnaps[i].OperatorGroups = snap.OperatorGroups
+		j.collector.store(jobSnaps[i])
 	}
 
-	return tickets, nil
+	publish(snap)
+
+	// the first cycle without restored state only records what was seen
+	ticketLifecycle.prime()
+	operatorReplies.prime()
+	ticketDuplicates.prime()
 }
 
-// CommonLabels is a map of labels that are common to all tickets
-var CommonLabels = []string{"client", "status", "priority", "user", "subject", "ticket_url", "frontend_url"}
+// discard releases the labels of the added tickets of a failed cycle
+func (b *snapshotBuilder) discard() {
+	for _, e := range b.entries {
+		releaseLabels(e.labels)
+	}
+	b.entries = nil
+	b.jobs = nil
+}
 
-var (
-	supportPalTicketUpdated  = &prometheus.GaugeVec{}
-	supportPalTicketCreated  = &prometheus.GaugeVec{}
-	supportPalTicketDeleted  = &prometheus.GaugeVec{}
-	supportPalTicketResolved = &prometheus.GaugeVec{}
-	globaLabels              = []string{}
-)
+// labelSchema returns the label names of the ticket metrics: the common
+// labels, the optional labels enabled by the configuration and a label for
+// every custom field of the given tickets exported as label
+func labelSchema(tickets []*supportpal.Ticket) []string {
+	// Copy commonLabels to labels
+	labels := make([]string, len(CommonLabels))
+	for k, v := range CommonLabels {
+		labels[k] = v
+	}
 
-func collectMetrics() {
-	for {
-		log.Println("Collecting metrics...")
+	if config.SubjectHash {
+		labels[slices.Index(labels, "subject")] = "subject_hash"
+	}
 
-		log.Println("List all tickets...")
+	if config.TagsLabel {
+		labels = append(labels, "tags")
+	}
 
-		tickets, err := fetchAllTickets()
+	if config.AssignedLabel {
+		labels = append(labels, "assigned")
+	}
 
-		if err != nil {
-			log.Println(err)
-			continue
-		}
+	if config.OrganizationIDLabel {
+		labels = append(labels, "organization_id")
+	}
 
-		log.Println("List all tickets...done")
+	if config.StatusIDLabels {
+		labels = append(labels, "status_id", "priority_id")
+	}
 
-		log.Println("Cleaning old metrics...")
+	for _, id := range slices.Sorted(maps.Keys(config.OrganizationFieldLabels)) {
+		labels = append(labels, config.OrganizationFieldLabels[id])
+	}
 
-		supportPalTicketCreated.Reset()
-		supportPalTicketUpdated.Reset()
-		supportPalTicketDeleted.Reset()
+	labels = append(labels, providerLabelNames()...)
 
-		for _, ticket := range tickets {
-			// ignore tickets oldes than 1 year
-			if time.Unix(ticket.CreatedAt, 0).AddDate(1, 0, 0).Before(time.Now()) {
-				continue
-			}
+	// supportpal_ticket_info carries the ticket ID already
+	if config.TicketIDLabel && !config.TicketInfo {
+		labels = append(labels, "ticket_id")
+	}
 
-			labels := prometheus.Labels{
-				"status":       strings.ToLower(ticket.Status.Name),
-				"priority":     strings.ToLower(ticket.Priority.Name),
-				"user":         strings.ToLower(ticket.User.FormattedName),
-				"subject":      ticket.Subject,
-				"ticket_url":   ticket.OperatorURL,
-				"frontend_url": ticket.FrontendURL,
-			}
+	if config.FetchMessages {
+		labels = append(labels, "last_reply_by")
+	}
 
-			if ticket.User.OrganizationID != 0 {
-				org, err := getOrganization(ticket.User.OrganizationID)
+	for _, ticket := range tickets {
+		labels = extendLabelSchema(labels, ticket)
+	}
 
-				if err != nil {
-					log.Println(err)
-					continue
-				}
+	return labels
+}
 
-				orgName := ""
-				if org.Data != nil {
-					orgName = org.Data.Name
-				}
+// mergeSchema returns the label schema of a cycle from the schema of the
+// previous cycle and the labels of the tickets of the cycle. Labels still in
+// use keep their order and new custom field labels are appended, so custom
+// fields added or removed by admins take effect without a restart. Labels no
+// ticket uses anymore stay in the schema for config.RemovedLabelGrace and are
+// returned as deprecated, so queries joining on them keep working meanwhile.
+func mergeSchema(previous, current []string) (merged []string, deprecated map[string]time.Time) {
+	now := time.Now()
+	for _, name := range current {
+		labelLastSeen[name] = now
+	}
 
-				orgName = strings.Replace(orgName, " ", "", -1)
-				orgName = strings.ToLower(orgName)
+	merged = make([]string, 0, len(current))
+	var removed []string
+	for _, name := range previous {
+		if slices.Contains(current, name) {
+			merged = append(merged, name)
+			continue
+		}
 
-				labels["client"] = orgName
+		lastSeen, ok := labelLastSeen[name]
+		if until := lastSeen.Add(config.RemovedLabelGrace); ok && now.Before(until) {
+			if _, known := deprecatedLabels[name]; !known {
+				slog.Warn("label is no longer used by any ticket, it is exported empty until the grace period ends", "label", name, "until", until)
 			}
-
-			for _, customField := range ticket.CustomFields {
-				cField, err := getCustomField(customField.FieldID)
-
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				name := slug.Make(cField.Data.Name)
-				name = strings.ReplaceAll(name, "-", "_")
-				value := customField.Value
-
-				if cField.Data.Type == 7 {
-					for _, option := range cField.Data.Options {
-						nVal, _ := strconv.Atoi(value)
-						if option.ID == nVal {
-							value = slug.Make(option.Value)
-							break
-						}
-					}
-				}
-
-				labels[name] = value
+			if deprecated == nil {
+				deprecated = make(map[string]time.Time)
 			}
+			merged = append(merged, name)
+			deprecated[name] = lastSeen
+			continue
+		}
 
-			for _, label := range globaLabels {
-				if _, ok := labels[label]; !ok {
-					labels[label] = ""
-				}
-			}
+		delete(labelLastSeen, name)
+		removed = append(removed, name)
+	}
+	deprecatedLabels = deprecated
 
-			if ticket.DeletedAt != 0 {
-				supportPalTicketDeleted.With(labels).Set(float64(ticket.DeletedAt))
-			}
+	var added []string
+	for _, name := range current {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+			added = append(added, name)
+		}
+	}
 
-			if ticket.CreatedAt != 0 {
-				supportPalTicketCreated.With(labels).Set(float64(ticket.CreatedAt))
-			}
+	if len(previous) > 0 && (len(added) > 0 || len(removed) > 0) {
+		slog.Info("label schema changed", "added", added, "removed", removed)
+	}
 
-			if ticket.UpdatedAt != 0 {
-				supportPalTicketUpdated.With(labels).Set(float64(ticket.UpdatedAt))
-			} else {
-				supportPalTicketUpdated.With(labels).Set(float64(ticket.CreatedAt))
-			}
+	return merged, deprecated
+}
+
+// restoreSchema seeds the label schema with the labels of a restored
+// snapshot, so labels removed while the exporter was down are kept for the
+// rest of their grace period as well
+func restoreSchema(s *snapshot) {
+	globaLabels = slices.Clone(s.LabelNames)
+	for _, name := range s.LabelNames {
+		if lastSeen, ok := s.Deprecated[name]; ok {
+			labelLastSeen[name] = lastSeen
+		} else {
+			labelLastSeen[name] = s.CollectedAt
+		}
+	}
+	deprecatedLabels = s.Deprecated
+}
+
+// extendLabelSchema adds the labels of the custom fields of ticket exported
+// as label to the schema labels
+func extendLabelSchema(labels []string, ticket *supportpal.Ticket) []string {
+	for _, customField := range ticket.CustomFields {
+		cField, err := lastKnown.customField(customField.FieldID)
+
+		if errors.Is(err, supportpal.ErrNotFound) {
+			slog.Debug("custom field was deleted", "ticket_id", ticket.ID, "field_id", customField.FieldID)
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to get custom field", "ticket_id", ticket.ID, "field_id", customField.FieldID, "err", err)
+			continue
+		}
+
+		if !isLabelField(cField) {
+			continue
+		}
+
+		name := customFieldLabel(cField)
+		found := false
 
-			if ticket.ResolvedTime != 0 {
-				supportPalTicketResolved.With(labels).Set(float64(ticket.ResolvedTime))
+		for _, v := range labels {
+			if v == name {
+				found = true
+				break
 			}
 		}
 
-		time.Sleep(time.Duration(60) * time.Second)
+		if !found {
+			labels = append(labels, name)
+		}
 	}
+
+	return labels
 }
 
-func initializeMetrics() {
-	log.Println("Initializing metrics...")
-	tickets, err := fetchAllTickets()
+// ticketEntry is a built ticket waiting to be added to a snapshot
+type ticketEntry struct {
+	labels prometheus.Labels
+	series *ticketSeries
+}
 
-	if err != nil {
-		log.Fatal(err)
+// addTickets adds the tickets to s like addEntries and releases the labels of
+// the entries to labelPool afterwards
+func addTickets(s *snapshot, entries []ticketEntry) {
+	addEntries(s, entries)
+	for _, e := range entries {
+		releaseLabels(e.labels)
 	}
+}
 
-	// Copy commonLabels to labels
-	globaLabels = make([]string, len(CommonLabels))
-	for k, v := range CommonLabels {
-		globaLabels[k] = v
+// addEntries adds the tickets to s. The values of custom field labels are
+// truncated to config.MaxFieldValues, and with department label sets the
+// schema of every department is derived from its tickets first.
+func addEntries(s *snapshot, entries []ticketEntry) {
+	s.KeptValues = truncateLabelValues(entries, !s.job)
+
+	if config.LabelSets == labelSetsDepartment {
+		s.Groups = make(map[string][]string)
+		for _, e := range entries {
+			s.Groups[e.series.Department] = departmentSchema(s.LabelNames, s.Groups[e.series.Department], e.labels)
+		}
 	}
 
-	for _, ticket := range tickets {
-		for _, customField := range ticket.CustomFields {
-			cField, err := getCustomField(customField.FieldID)
+	s.Series = slices.Grow(s.Series, len(entries))
+	seen := make(map[string]int, len(entries))
+	for _, e := range entries {
+		s.add(e.labels, e.series, seen)
+	}
+}
 
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+// label set modes of the ticket metrics
+const (
+	// labelSetsPadded gives every ticket all labels, empty where unset
+	labelSetsPadded = "padded"
+	// labelSetsDepartment gives the tickets of a department only the custom
+	// field labels used by that department
+	labelSetsDepartment = "department"
+)
 
-			name := slug.Make(cField.Data.Name)
-			name = strings.ReplaceAll(name, "-", "_")
-			found := false
+// departmentSchema extends the label schema of a department with the custom
+// field labels of a ticket. Labels follow the order of the full schema.
+func departmentSchema(full, schema []string, labels prometheus.Labels) []string {
+	if schema == nil {
+		schema = labelSchema(nil)
+	}
 
-			for _, v := range globaLabels {
-				if v == name {
-					found = true
-					break
-				}
-			}
+	var extended []string
+	for _, name := range full {
+		if _, ok := labels[name]; ok || slices.Contains(schema, name) {
+			extended = append(extended, name)
+		}
+	}
 
-			if !found {
-				globaLabels = append(globaLabels, name)
-			}
+	return extended
+}
+
+func initializeMetrics() {
+	slog.Info("initializing metrics")
+
+	prometheus.MustRegister(supportPalTickets)
+	prometheus.MustRegister(statusDurations)
+	prometheus.MustRegister(supportpal.Collectors()...)
+	if config.Inventory {
+		supportPalInventory = collectors.NewInventory(apiClient)
+		prometheus.MustRegister(supportPalInventory)
+	}
+	if config.Articles {
+		supportPalArticles = collectors.NewArticles(apiClient)
+		prometheus.MustRegister(supportPalArticles)
+	}
+	if config.Backend {
+		supportPalBackend = collectors.NewBackend(apiClient)
+		prometheus.MustRegister(supportPalBackend)
+	}
+	if config.SeriesDiff {
+		prometheus.MustRegister(supportPalSeriesAdded, supportPalSeriesRemoved)
+	}
+	if config.Catalog {
+		supportPalCatalog = collectors.NewCatalog(apiClient)
+		supportPalCatalog.Name = catalogName
+		prometheus.MustRegister(supportPalCatalog)
+	}
+	if config.Emails {
+		supportPalEmailsSent.WithLabelValues()
+		supportPalEmailsReceived.WithLabelValues()
+		supportPalEmailFailures.WithLabelValues("incoming")
+		supportPalEmailFailures.WithLabelValues("outgoing")
+	}
+	if len(config.StaticMetrics) > 0 {
+		// static metrics may collide with the names of exporter metrics
+		if err := prometheus.Register(newStaticCollector(config.StaticMetrics)); err != nil {
+			fatal("invalid static metrics", "err", err)
 		}
 	}
 
-	// Create metrics
-	supportPalTicketCreated = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "supportpal_ticket_created",
-		Help: "Last time a ticket was created",
-	}, globaLabels)
+	supportPalCollectionDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: durationName("supportpal_collection_duration"),
+		Help: durationHelp("Duration of the last successful collection cycle"),
+	})
+	initializeDurationHistograms()
+}
 
-	supportPalTicketUpdated = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "supportpal_ticket_updated",
-		Help: "Last time a ticket was updated",
-	}, globaLabels)
+// reloadTokenOnHangup reads the API token file again on every SIGHUP
+func reloadTokenOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
 
-	supportPalTicketDeleted = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "supportpal_ticket_deleted",
-		Help: "Last time a ticket was deleted",
-	}, globaLabels)
+	for range hangup {
+		if changed, err := apiClient.ReloadToken(); err != nil {
+			slog.Error("failed to reload API token", "err", err)
+		} else {
+			slog.Info("reloaded API token", "changed", changed)
+		}
+	}
+}
 
-	supportPalTicketResolved = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "supportpal_ticket_resolved",
-		Help: "Last time a ticket was resolved",
-	}, globaLabels)
+// exporterGatherer returns the gatherer of all exported metrics
+func exporterGatherer() prometheus.Gatherer {
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if len(config.Jobs) > 0 {
+		gatherers := prometheus.Gatherers{gatherer}
+		for _, j := range config.Jobs {
+			gatherers = append(gatherers, j)
+		}
+		gatherer = gatherers
+	}
+	if config.DeprecatedNames && len(metricRenames) > 0 {
+		gatherer = deprecatedNameGatherer{gatherer: gatherer}
+	}
+	if config.MetricNamespace != defaultNamespace {
+		gatherer = namespaceGatherer{gatherer: gatherer, namespace: config.MetricNamespace}
+	}
+	if len(config.ExternalLabels) > 0 {
+		gatherer = externalLabelGatherer{gatherer: gatherer, labels: config.ExternalLabels}
+	}
 
-	log.Println("Metrics initialized.")
+	return gatherer
 }
 
 func main() {
+	parseConfig()
+	if config.ServiceCommand != "" {
+		if err := runServiceCommand(config.ServiceCommand, config.ServiceArgs); err != nil {
+			fatal("failed to "+config.ServiceCommand+" the Windows service", "name", config.ServiceName, "err", err)
+		}
+		os.Exit(0)
+	}
+	startService()
+	if config.OTLPTracesEndpoint != "" {
+		tracer = newOTLPTracer()
+	}
+
+	apiClient = supportpal.New(supportpal.Config{
+		BaseURL:               config.BaseURL,
+		Token:                 config.Token,
+		TokenFile:             config.TokenFile,
+		ListTimeout:           config.ListTimeout,
+		EnrichmentTimeout:     config.EnrichmentTimeout,
+		CacheTTL:              config.CacheTTL,
+		CacheMaxEntries:       config.CacheMaxEntries,
+		SharedCache:           connectSharedCache(),
+		MessageCacheSize:      config.MessageCacheSize,
+		ConditionalCacheSize:  config.ConditionalCacheSize,
+		DisableCompression:    !config.APICompression,
+		PageSize:              config.PageSize,
+		PageRetries:           config.PageRetries,
+		EnrichmentConcurrency: config.EnrichmentConcurrency,
+		TicketFilter:          ticketFilter(nil),
+		Shard:                 config.Shard,
+		OrderColumn:           config.TicketOrder,
+		MaxTickets:            config.MaxTickets,
+		SelectFields:          config.SelectFields,
+		Strict:                config.StrictDecode,
+		ErrorLogSize:          config.ErrorLogSize,
+		AvailabilityWindow:    config.APIAvailabilityWindow,
+		TLSConfig:             config.APITLS,
+		ProxyURL:              config.APIProxyURL,
+		APIVersion:            config.APIVersion,
+		Tracer:                clientTracer(),
+	})
+	source = apiClient
+	ticketHistory = newEventHistory(config.HistorySize, config.HistoryRetention)
+
+	if config.SystemdNotify {
+		notifier = newSystemdNotifier()
+		if notifier.socket == "" {
+			slog.Warn("--systemd.notify is set but NOTIFY_SOCKET is not, systemd is not notified")
+		}
+		if notifier.watchdog > 0 && notifier.watchdog <= pollInterval() {
+			slog.Warn("systemd watchdog is shorter than the collection interval, the exporter will be restarted", "watchdog", notifier.watchdog, "interval", pollInterval())
+		}
+	}
+
+	if config.TokenFile != "" {
+		if _, err := apiClient.ReloadToken(); err != nil {
+			fatal("failed to read API token file", "err", err)
+		}
+		go reloadTokenOnHangup()
+	}
+
+	if config.Prune {
+		if err := runPrune(os.Stdout); err != nil {
+			fatal("failed to prune Pushgateway groupings", "err", err)
+		}
+		os.Exit(0)
+	}
+
+	if config.Schema {
+		if err := runSchema(os.Stdout); err != nil {
+			fatal("failed to list custom fields", "err", err)
+		}
+		os.Exit(0)
+	}
+
+	if config.Check {
+		if problems := runCheck(os.Stdout); problems > 0 {
+			fatal("configuration check failed", "problems", problems)
+		}
+		os.Exit(0)
+	}
+
+	if err := apiClient.Check(); err != nil {
+		slog.Error("SupportPal API is not reachable, collections fail until it is", "base_url", apiClient.BaseURL(), "err", err)
+	} else {
+		slog.Info("connected to SupportPal API", "base_url", apiClient.BaseURL(), "version", apiClient.APIVersion())
+	}
+	slog.Info("enabled collectors", "collectors", enabledCollectors())
+	if config.Shard.Count > 1 {
+		slog.Info("collecting a shard of the tickets, every shard lists all ticket pages", "shard", config.Shard.String())
+	}
+
+	warm := false
+	if config.StatePath != "" {
+		if state, err := loadState(config.StatePath); err == nil {
+			restoreState(state)
+			warm = true
+			slog.Info("loaded persisted state", "path", config.StatePath, "series", len(state.Snapshot.Series))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("ignoring persisted state", "path", config.StatePath, "err", err)
+		}
+	}
+
+	registerRuntimeMetrics(config.GoRuntimeMetrics)
+	sinks = configureSinks()
+	startElection()
 
 	initializeMetrics()
-	go collectMetrics()
-	http.Handle("/metrics", promhttp.Handler())
-	http.ListenAndServe(":20000", nil)
+	if warm {
+		supportPalUp.Set(1)
+		slog.Info("serving persisted snapshot until the first collection completes")
+		// the persisted snapshot is served like /-/ready reports, so systemd
+		// does not time out the start while the API is unreachable
+		notifier.notifyReady()
+	}
+
+	// the first cycle discovers the label schema in the background, so the
+	// self-metrics and the readiness are served right away while big
+	// installations are crawled
+	go func() {
+		awaitLeadership()
+		collectMetrics()
+	}()
+	if config.TelemetryEndpoint != "" {
+		go sendTelemetry()
+	}
+	if config.TextfileOnly {
+		slog.Info("writing metrics to the textfile directory only", "dir", config.TextfileDir)
+		select {}
+	}
+
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		measureExposition(metricsHandler(seriesCountingGatherer{gatherer: exporterGatherer()}))))
+	http.HandleFunc("/summary.json", summaryHandler)
+	http.HandleFunc("/organizations.json", organizationsHandler)
+	http.HandleFunc("/schema", schemaHandler)
+	http.HandleFunc("/annotations", annotationsHandler)
+	if config.TicketsAPI {
+		http.HandleFunc("/api/tickets", ticketsHandler)
+	}
+	http.HandleFunc("/-/status", statusHandler)
+	http.HandleFunc("/-/ready", readyHandler)
+	http.HandleFunc("/-/prime", primeHandler)
+	http.HandleFunc("/", rootHandler)
+	if config.WebhookSecret != "" {
+		http.HandleFunc("/webhook", webhookHandler)
+		go processWebhooks()
+	}
+
+	web := &webConfig{}
+	if config.WebConfigFile != "" {
+		var err error
+		if web, err = loadWebConfig(config.WebConfigFile); err != nil {
+			fatal("failed to load web configuration", "err", err)
+		}
+	}
+
+	tlsConfig, err := web.tlsConfig()
+	if err != nil {
+		fatal("invalid TLS configuration", "err", err)
+	}
+
+	server := &http.Server{
+		Addr:      config.ListenAddress,
+		Handler:   web.authenticate(pprofGate(http.DefaultServeMux, config.EnablePprof), "/webhook"),
+		TLSConfig: tlsConfig,
+	}
+
+	if config.EnablePprof && len(web.BasicAuthUsers) == 0 {
+		slog.Warn("pprof is enabled without basic authentication, profiles are served to anyone reaching the exporter")
+	}
+
+	slog.Info("listening", "address", config.ListenAddress, "tls", tlsConfig != nil)
+	serviceServer.Store(server)
+	if tlsConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		// stopped by the Windows service manager, the service exits once
+		// the stop was reported
+		select {}
+	}
+	fatal("HTTP server failed", "err", err)
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Size of the /metrics responses, set after every scrape, so a scrape sees
+// the size of the previous one
+var (
+	supportPalExpositionBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "supportpal_exposition_bytes",
+		Help: "Size of the body of the last /metrics response by content encoding, gzip if it was compressed",
+	}, []string{"encoding"})
+
+	supportPalExpositionSeries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "supportpal_exposition_series",
+		Help: "Number of series of every metric family in the last /metrics response",
+	}, []string{"family"})
+)
+
+// seriesCountingGatherer sets supportpal_exposition_series to the series of
+// the families gathered from the wrapped gatherer
+type seriesCountingGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (g seriesCountingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	// families no longer served are dropped
+	supportPalExpositionSeries.Reset()
+	for _, family := range families {
+		supportPalExpositionSeries.WithLabelValues(family.GetName()).Set(float64(len(family.Metric)))
+	}
+
+	return families, err
+}
+
+// byteCountingResponseWriter counts the bytes of a response body
+type byteCountingResponseWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+// Write implements http.ResponseWriter
+func (w *byteCountingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += n
+	return n, err
+}
+
+// measureExposition sets supportpal_exposition_bytes to the size of every
+// response of next, which compresses it on its own
+func measureExposition(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counting := &byteCountingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(counting, r)
+
+		encoding := w.Header().Get("Content-Encoding")
+		if encoding == "" {
+			encoding = "identity"
+		}
+		supportPalExpositionBytes.Reset()
+		supportPalExpositionBytes.WithLabelValues(encoding).Set(float64(counting.written))
+	})
+}
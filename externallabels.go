@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// externalLabelGatherer adds constant labels to every metric gathered from
+// the wrapped gatherer. Metrics already carrying one of the labels keep their
+// own value.
+type externalLabelGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   map[string]string
+}
+
+// Gather implements prometheus.Gatherer
+func (g externalLabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			present := make(map[string]bool, len(metric.Label))
+			for _, pair := range metric.Label {
+				present[pair.GetName()] = true
+			}
+
+			for name, value := range g.labels {
+				if !present[name] {
+					metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+				}
+			}
+
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+
+	return families, err
+}
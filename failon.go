@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// failureClasses are the classes of collection failures --fail-on accepts
+var failureClasses = []string{"auth", "decode", "server", "network"}
+
+// failureClass classifies a failed ticket collection: rejected credentials,
+// undecodable responses, server errors and everything else as network error
+func failureClass(err error) string {
+	var status *supportpal.StatusError
+	if errors.As(err, &status) {
+		if status.Code == http.StatusUnauthorized || status.Code == http.StatusForbidden {
+			return "auth"
+		}
+		return "server"
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "decode"
+	}
+
+	return "network"
+}
+
+// failurePolicy terminates the exporter after a configured class of failure
+// occurred in threshold consecutive collections, so orchestrators restart it
+// and alert instead of it serving stale data
+type failurePolicy struct {
+	classes     []string
+	threshold   int
+	consecutive map[string]int
+}
+
+// parseFailOn parses a comma separated list of failure classes
+func parseFailOn(list string) ([]string, error) {
+	classes := splitList(list)
+	for _, class := range classes {
+		if !slices.Contains(failureClasses, class) {
+			return nil, fmt.Errorf("unknown failure class %q, expected one of %v", class, failureClasses)
+		}
+	}
+
+	return classes, nil
+}
+
+// newFailurePolicy returns a policy exiting after threshold consecutive
+// failures of one of classes
+func newFailurePolicy(classes []string, threshold int) *failurePolicy {
+	return &failurePolicy{classes: classes, threshold: max(threshold, 1), consecutive: make(map[string]int)}
+}
+
+// observe records the result of a collection and exits the process once a
+// configured failure class was seen threshold times in a row
+func (p *failurePolicy) observe(err error) {
+	if err == nil {
+		clear(p.consecutive)
+		return
+	}
+
+	class := failureClass(err)
+	for c := range p.consecutive {
+		if c != class {
+			delete(p.consecutive, c)
+		}
+	}
+	p.consecutive[class]++
+
+	if slices.Contains(p.classes, class) && p.consecutive[class] >= p.threshold {
+		fatal("exiting after repeated collection failures", "class", class, "failures", p.consecutive[class], "err", err)
+	}
+}
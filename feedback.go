@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalFeedbackScoreSumDesc = prometheus.NewDesc("supportpal_feedback_score_sum",
+	"Sum of the feedback ratings of collected tickets, it decreases when rated tickets leave the collection window or are rated again", []string{"operator", "department"}, nil)
+
+var supportPalFeedbackScoreCountDesc = prometheus.NewDesc("supportpal_feedback_score_count",
+	"Number of feedback ratings of collected tickets, it decreases when rated tickets leave the collection window", []string{"operator", "department"}, nil)
+
+// fetchFeedback returns the latest feedback of every ticket rated within the
+// last year by ticket ID. It returns nil if the feedback cannot be listed.
+func fetchFeedback() map[int]*supportpal.Feedback {
+	feedback, err := source.ListFeedback(time.Now().AddDate(-1, 0, 0).Unix())
+	if err != nil {
+		slog.Error("failed to list feedback", "err", err)
+		collectionError("feedback")
+		return nil
+	}
+
+	byTicket := make(map[int]*supportpal.Feedback, len(feedback))
+	for _, f := range feedback {
+		if _, ok := byTicket[f.TicketID]; !ok {
+			byTicket[f.TicketID] = f
+		}
+	}
+
+	return byTicket
+}
+
+// collectFeedback sends the per ticket ratings of s and their sums and
+// counts by rated operator and department
+func collectFeedback(ch chan<- prometheus.Metric, s *snapshot) {
+	ratingDesc := newSeriesDesc("supportpal_ticket_rating",
+		"Latest feedback rating of a ticket")
+
+	sum := make(labelCounts)
+	count := make(labelCounts)
+
+	for _, series := range s.Series {
+		if !series.Rated {
+			continue
+		}
+
+		if !series.Hidden {
+			ch <- ratingDesc.metric(s, series, prometheus.GaugeValue, series.Rating)
+		}
+
+		if series.Deleted == 0 {
+			sum.add(series.Rating, series.RatedOperator, series.Department)
+			count.add(1, series.RatedOperator, series.Department)
+		}
+	}
+
+	sum.emit(ch, supportPalFeedbackScoreSumDesc, prometheus.GaugeValue)
+	count.emit(ch, supportPalFeedbackScoreCountDesc, prometheus.GaugeValue)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fixtureFilters are the query parameters of list requests applied to the
+// items of a recorded list by the field they compare, id fields of nested
+// objects with a dot
+var fixtureFilters = map[string]string{
+	"ticket_id":    "ticket_id",
+	"status[]":     "status.id",
+	"department[]": "department.id",
+}
+
+// fixtureHandler serves recorded API responses from dir for
+// --api.fixture-dir. The response of a request is the JSON file of its path,
+// e.g. api/ticket/ticket.json for /api/ticket/ticket. The data array of a
+// recorded list is filtered and paginated like the API does. An item missing
+// its own file, e.g. api/ticket/ticket/12.json, is looked up by ID in the
+// list of its parent path. Other requests are answered with 404 Not Found.
+func fixtureHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(path.Clean(r.URL.Path), "/")
+
+		body, err := readFixture(dir, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			body, err = fixtureItem(dir, name)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":"error","message":"not found"}`))
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if data, ok := body["data"].([]any); ok {
+			paginateFixture(body, data, r)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			slog.Error("failed to serve fixture", "path", name, "err", err)
+		}
+	})
+}
+
+// readFixture decodes the recorded response of a request path
+func readFixture(dir, name string) (map[string]any, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// fixtureItem returns the item of a path ending in its ID from the recorded
+// list of the parent path, wrapped like a response of the API
+func fixtureItem(dir, name string) (map[string]any, error) {
+	parent, id := path.Split(name)
+	if _, err := strconv.Atoi(id); err != nil || parent == "" {
+		return nil, fs.ErrNotExist
+	}
+
+	list, err := readFixture(dir, strings.TrimSuffix(parent, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := list["data"].([]any)
+	for _, item := range data {
+		if fixtureField(item, "id") == id {
+			return map[string]any{"status": "success", "message": "", "data": item}, nil
+		}
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+// fixtureField returns a field of a recorded item as string, a dot selects
+// the field of a nested object. Numbers are formatted like IDs in queries.
+func fixtureField(item any, field string) string {
+	for _, key := range strings.Split(field, ".") {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return ""
+		}
+		item = object[key]
+	}
+
+	switch v := item.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// paginateFixture replaces the data of a recorded list with the page of the
+// items matching the filters of the request and sets the total to the
+// number of matching items
+func paginateFixture(body map[string]any, data []any, r *http.Request) {
+	query := r.URL.Query()
+
+	matches := []any{}
+	for _, item := range data {
+		matched := true
+		for param, field := range fixtureFilters {
+			if values, ok := query[param]; ok && !slices.Contains(values, fixtureField(item, field)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, item)
+		}
+	}
+
+	start, _ := strconv.Atoi(query.Get("start"))
+	start = min(max(start, 0), len(matches))
+	end := len(matches)
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		end = min(start+limit, end)
+	}
+
+	body["data"] = matches[start:end]
+	if meta, ok := body["meta"].(map[string]any); ok {
+		meta["total"] = len(matches)
+	} else {
+		body["count"] = len(matches)
+	}
+}
+
+// serveFixtures serves the recorded API responses of dir on a local port and
+// returns its base URL
+func serveFixtures(dir string) (string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go http.Serve(listener, fixtureHandler(dir))
+
+	return "http://" + listener.Addr().String(), nil
+}
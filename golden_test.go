@@ -0,0 +1,784 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/text/language"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// timeDependent lists the metric families whose values depend on the time of
+// the scrape and are left out of the golden files
+var timeDependent = map[string]bool{
+	"supportpal_ticket_age_seconds":             true,
+	"supportpal_ticket_waiting_seconds":         true,
+	"supportpal_oldest_open_ticket_age_seconds": true,
+	"supportpal_backlog_burndown_days":          true,
+	"supportpal_client_resolution_ratio":        true,
+}
+
+// fixtureSource serves the tickets, organizations and custom fields in
+// testdata/golden/fixtures.json as a TicketSource
+type fixtureSource struct {
+	tickets       []*supportpal.Ticket
+	organizations map[int]*supportpal.Organization
+	customFields  map[int]*supportpal.GetCustomFieldResponse
+}
+
+// newFixtureSource reads testdata/golden/fixtures.json
+func newFixtureSource(t testing.TB) *fixtureSource {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "golden", "fixtures.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f struct {
+		Organizations map[int]*supportpal.Organization `json:"organizations"`
+		CustomFields  map[int]json.RawMessage          `json:"customfields"`
+		Tickets       []*supportpal.Ticket             `json:"tickets"`
+	}
+	if err := json.Unmarshal(raw, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &fixtureSource{
+		tickets:       f.Tickets,
+		organizations: f.Organizations,
+		customFields:  make(map[int]*supportpal.GetCustomFieldResponse),
+	}
+
+	for id, data := range f.CustomFields {
+		field := &supportpal.GetCustomFieldResponse{Status: "success"}
+		if err := json.Unmarshal([]byte(`{"data":`+string(data)+`}`), field); err != nil {
+			t.Fatal(err)
+		}
+		src.customFields[id] = field
+	}
+
+	return src
+}
+
+func (f *fixtureSource) ListTickets(start, limit int) (*supportpal.ListTicketsResponse, error) {
+	end := min(start+limit, len(f.tickets))
+	start = min(start, end)
+	return &supportpal.ListTicketsResponse{Status: "success", Count: len(f.tickets), Data: f.tickets[start:end]}, nil
+}
+
+func (f *fixtureSource) FetchTickets(inBound func(*supportpal.Ticket) bool) ([]*supportpal.Ticket, error) {
+	var tickets []*supportpal.Ticket
+	for _, ticket := range f.tickets {
+		if inBound == nil || inBound(ticket) {
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets, nil
+}
+
+func (f *fixtureSource) StreamTickets(inBound func(*supportpal.Ticket) bool, fn func(*supportpal.Ticket)) error {
+	tickets, _ := f.FetchTickets(inBound)
+	for _, ticket := range tickets {
+		fn(ticket)
+	}
+	return nil
+}
+
+func (f *fixtureSource) GetTicket(id int) (*supportpal.Ticket, error) {
+	for _, ticket := range f.tickets {
+		if ticket.ID == id {
+			return ticket, nil
+		}
+	}
+	return nil, fmt.Errorf("ticket %d: %w", id, supportpal.ErrNotFound)
+}
+
+func (f *fixtureSource) GetOrganization(id int) (*supportpal.GetOrganizationResponse, error) {
+	if org, ok := f.organizations[id]; ok {
+		return &supportpal.GetOrganizationResponse{Status: "success", Data: org}, nil
+	}
+	return nil, fmt.Errorf("organization %d: %w", id, supportpal.ErrNotFound)
+}
+
+func (f *fixtureSource) GetCustomField(id int) (*supportpal.GetCustomFieldResponse, error) {
+	if field, ok := f.customFields[id]; ok {
+		return field, nil
+	}
+	return nil, fmt.Errorf("custom field %d: %w", id, supportpal.ErrNotFound)
+}
+
+func (f *fixtureSource) ListCustomFields() ([]*supportpal.CustomField, error) {
+	fields := make([]*supportpal.CustomField, 0, len(f.customFields))
+	for _, field := range f.customFields {
+		fields = append(fields, &field.Data)
+	}
+	return fields, nil
+}
+
+func (f *fixtureSource) ListMessages(ticketID int) ([]*supportpal.Message, error) {
+	return nil, nil
+}
+
+func (f *fixtureSource) TicketMessages(ticket *supportpal.Ticket) ([]*supportpal.Message, error) {
+	return f.ListMessages(ticket.ID)
+}
+
+func (f *fixtureSource) TicketLogs(ticket *supportpal.Ticket) ([]*supportpal.TicketLog, error) {
+	return nil, nil
+}
+
+func (f *fixtureSource) ListFeedback(since int64) ([]*supportpal.Feedback, error) {
+	return nil, nil
+}
+
+// buildSnapshot builds a snapshot of the given tickets
+func buildSnapshot(t testing.TB, tickets []*supportpal.Ticket) *snapshot {
+	t.Helper()
+
+	refreshFieldLabels()
+	entries := make([]ticketEntry, 0, len(tickets))
+	for _, ticket := range tickets {
+		labels, series := buildTicket(ticket)
+		entries = append(entries, ticketEntry{labels, series})
+	}
+
+	snap := newSnapshot(labelSchema(tickets))
+	addTickets(snap, entries)
+
+	return snap
+}
+
+// exposition builds a snapshot of the fixture tickets and returns the
+// exposition text of the time independent ticket metrics
+func exposition(t *testing.T) []byte {
+	t.Helper()
+
+	source = newFixtureSource(t)
+
+	tickets, err := source.FetchTickets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := buildSnapshot(t, tickets)
+
+	collector := &ticketCollector{}
+	collector.store(snap)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, family := range families {
+		if timeDependent[family.GetName()] {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestGoldenLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{
+			name:   "default",
+			config: Config{},
+		},
+		{
+			name: "tags_and_names",
+			config: Config{
+				TagsLabel:     true,
+				AssignedLabel: true,
+				SlugLanguage:  "de",
+				FieldNames:    map[int]string{9: "area"},
+			},
+		},
+		{
+			name: "numeric_and_excluded",
+			config: Config{
+				NumericFields: []string{"affected_users"},
+				ExcludeFields: []string{"notes", "10"},
+			},
+		},
+		{
+			name: "missing_sentinel",
+			config: Config{
+				MissingFields:        missingSentinel,
+				MissingFieldSentinel: "none",
+			},
+		},
+		{
+			name: "department_label_sets",
+			config: Config{
+				LabelSets: labelSetsDepartment,
+			},
+		},
+		{
+			name: "ticket_info",
+			config: Config{
+				TicketInfo: true,
+			},
+		},
+		{
+			name: "ticket_id_label",
+			config: Config{
+				TicketIDLabel: true,
+			},
+		},
+		{
+			name: "subject_hash",
+			config: Config{
+				TicketInfo:  true,
+				SubjectHash: true,
+				PrivacySalt: "golden",
+			},
+		},
+		{
+			name: "label_rules",
+			config: Config{
+				LabelRules: []*labelRule{
+					{Label: "priority", Regex: "high|urgent", Replacement: "critical"},
+					{Label: "client", Regex: "(.*)gmbh", Replacement: "$1"},
+					{Label: "subject", Regex: "(?i)(website) down", Replacement: "${1} outage"},
+				},
+			},
+		},
+		{
+			name: "organization_names",
+			config: Config{
+				OrganizationNames:   organizationNamesSlug,
+				OrganizationIDLabel: true,
+			},
+		},
+		{
+			name: "organization_fields",
+			config: Config{
+				OrganizationFieldLabels: map[int]string{1: "support_tier", 2: "region"},
+			},
+		},
+		{
+			name: "ticket_timestamps",
+			config: Config{
+				TicketTimestamps: true,
+			},
+		},
+		{
+			name: "max_field_values",
+			config: Config{
+				MaxFieldValues: 1,
+			},
+		},
+		{
+			name: "status_ids",
+			config: Config{
+				StatusIDLabels: true,
+				StatusNames:    map[int]string{1: "new"},
+			},
+		},
+		{
+			name: "transliterate",
+			config: Config{
+				LabelValues:       labelValuesTransliterate,
+				OrganizationNames: organizationNamesTransliterate,
+			},
+		},
+		{
+			name: "resolved_by",
+			config: Config{
+				ResolvedBy: resolvedByAssigned,
+			},
+		},
+		{
+			name: "privacy",
+			config: Config{
+				PrivacyLabels: []string{"user", "subject", "client", "operator"},
+				PrivacyMode:   privacyHash,
+				PrivacySalt:   "golden",
+				ResolvedBy:    resolvedByAssigned,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = tt.config
+			for _, r := range config.LabelRules {
+				if err := r.compile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			config.DurationUnit = "seconds"
+			config.DurationPrecision = 3
+			config.DeletedOrganizationLabel = "deleted"
+			config.FieldLabelPrefix = "cf_"
+			config.OrganizationMetrics = true
+			t.Cleanup(func() { config = Config{} })
+
+			got := exposition(t)
+			path := filepath.Join("testdata", "golden", tt.name+".prom")
+
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("exposition differs from %s, rerun with -update after checking the change:\n%s", path, got)
+			}
+		})
+	}
+}
+
+// labelValues returns the values of a label of the series of every family
+// gathered from the collector by family name
+func labelValues(t *testing.T, c prometheus.Collector, label string) map[string][]string {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string][]string)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if pair.GetName() == label {
+					values[family.GetName()] = append(values[family.GetName()], pair.GetValue())
+				}
+			}
+		}
+	}
+
+	return values
+}
+
+func TestOrganizationMetricsClientLabel(t *testing.T) {
+	defer func(c Config, s TicketSource) { config, source = c, s }(config, source)
+	config = Config{
+		FieldLabelPrefix:    "cf_",
+		OrganizationMetrics: true,
+		OrganizationNames:   organizationNamesSlug,
+		LabelRules:          []*labelRule{{Label: "client", Regex: "(.*)-gmbh", Replacement: "$1"}},
+		PrivacyLabels:       []string{"client"},
+		PrivacyMode:         privacyHash,
+		PrivacySalt:         "golden",
+	}
+	if err := config.LabelRules[0].compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures := newFixtureSource(t)
+	source = fixtures
+	var tickets []*supportpal.Ticket
+	for _, ticket := range fixtures.tickets {
+		recent := *ticket
+		recent.CreatedAt = time.Now().Add(-time.Hour).Unix()
+		tickets = append(tickets, &recent)
+	}
+	collector := &ticketCollector{}
+	collector.store(buildSnapshot(t, tickets))
+
+	// the organization metrics join the rewritten and hashed client label
+	// of the ticket metrics
+	clients := labelValues(t, collector, "client")
+	organizations := labelValues(t, collector, "organization")["supportpal_organization_open_tickets"]
+	ratios := clients["supportpal_client_resolution_ratio"]
+	if len(ratios) != 2 || len(organizations) != 2 {
+		t.Fatalf("ratios of clients %v and open tickets of organizations %v, want 2", ratios, organizations)
+	}
+	for _, client := range slices.Concat(ratios, organizations) {
+		if !slices.Contains(clients["supportpal_ticket_created_timestamp_seconds"], client) {
+			t.Errorf("organization metric of client %q, which no ticket has", client)
+		}
+	}
+}
+
+func TestDeprecatedMetricNames(t *testing.T) {
+	renames := metricRenames
+	metricRenames = []metricRename{{Old: "supportpal_old_tickets", New: "supportpal_tickets_open", Since: "v1.0.0"}}
+	config = Config{Jobs: []*job{{Name: "billing", Prefix: "supportpal_billing"}}}
+	t.Cleanup(func() {
+		metricRenames = renames
+		config = Config{}
+	})
+
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"supportpal_tickets_open", "supportpal_billing_tickets_open", "supportpal_up"} {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "Open tickets"})
+		gauge.Set(3)
+		reg.MustRegister(gauge)
+	}
+
+	scraped := testutil.ToFloat64(supportPalDeprecatedMetricScraped.WithLabelValues("supportpal_old_tickets"))
+	families, err := deprecatedNameGatherer{gatherer: reg}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+		if family.GetName() == "supportpal_old_tickets" && family.GetHelp() != "Deprecated, renamed to supportpal_tickets_open: Open tickets" {
+			t.Errorf("help of the deprecated family = %q", family.GetHelp())
+		}
+	}
+	want := []string{"supportpal_billing_old_tickets", "supportpal_billing_tickets_open", "supportpal_old_tickets", "supportpal_tickets_open", "supportpal_up"}
+	if !slices.Equal(names, want) {
+		t.Errorf("gathered %v, want %v", names, want)
+	}
+
+	if v := testutil.ToFloat64(supportPalDeprecatedMetricScraped.WithLabelValues("supportpal_old_tickets")) - scraped; v != 1 {
+		t.Errorf("counted %v scrapes of the deprecated name, want 1", v)
+	}
+}
+
+func TestOpenMetricsExposition(t *testing.T) {
+	tracker := counterCreated
+	counterCreated = &createdTracker{created: map[string]float64{"supportpal_tickets_created{department=\"Support\"}": 1717200000}, start: 1717300000.5}
+	t.Cleanup(func() { counterCreated = tracker })
+
+	reg := prometheus.NewRegistry()
+	created := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "supportpal_tickets_created_total", Help: "Created tickets"}, []string{"department"})
+	created.WithLabelValues("Support").Add(3)
+	created.WithLabelValues("Billing").Inc()
+	age := prometheus.NewGauge(prometheus.GaugeOpts{Name: "supportpal_oldest_open_ticket_age_seconds", Help: "Oldest ticket"})
+	age.Set(60)
+	reg.MustRegister(created, age)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, families); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP supportpal_oldest_open_ticket_age_seconds Oldest ticket
+# TYPE supportpal_oldest_open_ticket_age_seconds gauge
+# UNIT supportpal_oldest_open_ticket_age_seconds seconds
+supportpal_oldest_open_ticket_age_seconds 60.0
+# HELP supportpal_tickets_created Created tickets
+# TYPE supportpal_tickets_created counter
+supportpal_tickets_created_total{department="Billing"} 1.0
+supportpal_tickets_created_created{department="Billing"} 1717300000.5
+supportpal_tickets_created_total{department="Support"} 3.0
+supportpal_tickets_created_created{department="Support"} 1717200000
+# EOF
+`
+	if buf.String() != want {
+		t.Errorf("exposition = \n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// series seen before keep their creation time
+	buf.Reset()
+	writeOpenMetrics(&buf, families)
+	if !bytes.Contains(buf.Bytes(), []byte(`supportpal_tickets_created_created{department="Billing"} 1717300000.5`)) {
+		t.Errorf("creation time changed:\n%s", buf.String())
+	}
+
+	// restored series without persisted creation time get none, series
+	// appearing later count since the start of the process
+	counterCreated.restore(map[string]float64{"supportpal_tickets_created{department=\"Support\"}": 1717200000}, []string{
+		counterKey("supportpal_tickets_created_total", map[string]string{"department": "Support"}),
+		counterKey("supportpal_tickets_created_total", map[string]string{"department": "Billing"}),
+	})
+	created.WithLabelValues("Sales").Inc()
+	if families, err = reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	writeOpenMetrics(&buf, families)
+	for _, line := range []string{
+		`supportpal_tickets_created_created{department="Support"} 1717200000` + "\n",
+		`supportpal_tickets_created_created{department="Sales"} 1717300000.5` + "\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("exposition lacks %q:\n%s", line, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `_created{department="Billing"}`) {
+		t.Errorf("restored series without creation time has one:\n%s", buf.String())
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	t.Cleanup(func() { config, labelLanguage = Config{}, language.Und })
+
+	tests := []struct {
+		strategy, language, name, want string
+	}{
+		// composed and decomposed ü yield the same value
+		{labelValuesLowercase, "", "Jürgen Müller", "jürgen müller"},
+		{labelValuesLowercase, "", "Ju\u0308rgen Mu\u0308ller", "jürgen müller"},
+		{labelValuesLowercase, "tr", "IŞIK", "ışık"},
+		{labelValuesSlug, "de", "Jürgen Müller", "juergen-mueller"},
+		{labelValuesSlug, "", "Jürgen Müller", "jurgen-muller"},
+		{labelValuesTransliterate, "", "Jürgen Müller", "jurgen muller"},
+		{labelValuesKeep, "", "Ju\u0308rgen", "Jürgen"},
+	}
+	for _, tt := range tests {
+		config = Config{LabelValues: tt.strategy, LabelLanguage: tt.language}
+		labelLanguage = language.Make(tt.language)
+		if got := normalizeName(tt.name); got != tt.want {
+			t.Errorf("normalizeName(%q) with %s %s = %q, want %q", tt.name, tt.strategy, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestFixtureReplay(t *testing.T) {
+	srv := httptest.NewServer(fixtureHandler(filepath.Join("testdata", "fixtures")))
+	t.Cleanup(srv.Close)
+
+	c := supportpal.New(supportpal.Config{
+		BaseURL:           srv.URL,
+		ListTimeout:       time.Second,
+		EnrichmentTimeout: time.Second,
+		PageSize:          2,
+		TicketFilter:      url.Values{"department[]": {"1"}},
+	})
+	tickets, err := c.FetchAllTickets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, ticket := range tickets {
+		ids = append(ids, ticket.ID)
+	}
+	if !slices.Equal(ids, []int{2, 1}) {
+		t.Errorf("listed tickets %v of department 1, want [2 1]", ids)
+	}
+
+	// items without a file of their own are taken from the list
+	if ticket, err := c.GetTicket(3); err != nil || ticket.Subject != "Invoice missing" {
+		t.Errorf("GetTicket(3) = %v, %v", ticket, err)
+	}
+	if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "Example Corp" {
+		t.Errorf("GetOrganization(1) = %v, %v", org, err)
+	}
+	if _, err := c.GetOrganization(2); !errors.Is(err, supportpal.ErrNotFound) {
+		t.Errorf("GetOrganization(2) = %v, want not found", err)
+	}
+}
+
+func TestExcludedStatusFilter(t *testing.T) {
+	srv := httptest.NewServer(fixtureHandler(filepath.Join("testdata", "fixtures")))
+	t.Cleanup(srv.Close)
+
+	defer func(c Config, client *supportpal.Client) { config, apiClient = c, client }(config, apiClient)
+	config.ExcludeStatusIDs = []int{2}
+	apiClient = supportpal.New(supportpal.Config{
+		BaseURL:           srv.URL,
+		ListTimeout:       time.Second,
+		EnrichmentTimeout: time.Second,
+		TicketFilter:      ticketFilter(nil),
+	})
+
+	// the API cannot exclude statuses, the other statuses are selected
+	refreshTicketFilter()
+	tickets, err := apiClient.FetchAllTickets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, ticket := range tickets {
+		ids = append(ids, ticket.ID)
+	}
+	if !slices.Equal(ids, []int{3, 1}) {
+		t.Errorf("listed tickets %v without the closed ones, want [3 1]", ids)
+	}
+
+	// selected statuses lose the excluded ones without listing the statuses
+	config.StatusIDs = []int{1, 2}
+	if got := ticketFilter(nil).Encode(); got != "status%5B%5D=1" {
+		t.Errorf("filter = %s, want status[]=1", got)
+	}
+}
+
+func TestSeriesDiff(t *testing.T) {
+	previous := newSnapshot([]string{"ticket_url", "status"})
+	previous.Series = []*ticketSeries{
+		{ID: 1, LabelValues: []string{"/1", "open"}, Updated: 100},
+		{ID: 2, LabelValues: []string{"/2", "open"}, Updated: 100},
+		{ID: 3, LabelValues: []string{"/3", "open"}, Updated: 100},
+		{ID: 4, LabelValues: []string{"/4", "open"}, Updated: 100},
+	}
+	current := newSnapshot([]string{"ticket_url", "status"})
+	current.Series = []*ticketSeries{
+		// unchanged, updated, status changed, 4 dropped and 5 new
+		{ID: 1, LabelValues: []string{"/1", "open"}, Updated: 100},
+		{ID: 2, LabelValues: []string{"/2", "open"}, Updated: 200},
+		{ID: 3, LabelValues: []string{"/3", "closed"}, Updated: 200},
+		{ID: 5, LabelValues: []string{"/5", "open"}, Updated: 300},
+		{ID: 6, LabelValues: []string{"/6", "open"}, Updated: 300, Hidden: true},
+	}
+
+	diff := diffSeries(previous, current)
+	if !slices.Equal(diff.added, []int{3, 5}) || !slices.Equal(diff.updated, []int{2}) || !slices.Equal(diff.removed, []int{3, 4}) {
+		t.Errorf("diffSeries() = added %v, updated %v, removed %v, want added [3 5], updated [2], removed [3 4]", diff.added, diff.updated, diff.removed)
+	}
+}
+
+func TestHookLabelProvider(t *testing.T) {
+	failing := false
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case failing:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case r.URL.Query().Get("kind") == "organization" && r.URL.Query().Get("id") == "3":
+			fmt.Fprint(w, `{"service_tier":"gold","unknown":"ignored"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	hook, err := newHookProvider(srv.URL, []string{"service_tier"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := newCachedLabelProvider(hook, time.Nanosecond, 10)
+	labelProviders = []LabelProvider{provider}
+	t.Cleanup(func() { labelProviders = nil })
+
+	lookup := func(id int) prometheus.Labels {
+		labels := prometheus.Labels{}
+		addProviderLabels(labels, LabelKey{Kind: labelKeyOrganization, ID: id})
+		return labels
+	}
+
+	if labels := lookup(3); !maps.Equal(labels, prometheus.Labels{"service_tier": "gold"}) {
+		t.Errorf("labels of organization 3 = %v, want service_tier gold only", labels)
+	}
+	if labels := lookup(4); !maps.Equal(labels, prometheus.Labels{"service_tier": ""}) {
+		t.Errorf("labels of unknown organization 4 = %v, want empty service_tier", labels)
+	}
+
+	// a failed lookup keeps the labels last looked up
+	failing = true
+	time.Sleep(time.Millisecond)
+	if labels := lookup(3); labels["service_tier"] != "gold" {
+		t.Errorf("labels of organization 3 after a failed lookup = %v, want the last looked up", labels)
+	}
+
+	// the failure is cached
+	sent := requests
+	if labels := lookup(3); labels["service_tier"] != "gold" || requests != sent {
+		t.Errorf("labels of organization 3 = %v after %d more requests, want the last looked up without a request", labels, requests-sent)
+	}
+}
+
+func TestExecLabelProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+
+	command := filepath.Join(t.TempDir(), "labels.sh")
+	script := "#!/bin/sh\n[ \"$1\" = -- ] || exit 1\nprintf '{\"product\":\"%s\"}' \"$4\"\n"
+	if err := os.WriteFile(command, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	hook, err := newHookProvider("exec:"+command, []string{"product"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, err := hook.Lookup(LabelKey{Kind: labelKeyField, ID: 5, Value: "-rf"})
+	if err != nil || labels["product"] != "-rf" {
+		t.Errorf("Lookup() = %v, %v, want the value passed after --", labels, err)
+	}
+}
+
+func TestServiceArgs(t *testing.T) {
+	tests := []struct {
+		args, want []string
+	}{
+		{nil, []string{"--log.format=eventlog"}},
+		{[]string{"--web.listen-address=:9100"}, []string{"--web.listen-address=:9100", "--log.format=eventlog"}},
+		{[]string{"-log.format", "json"}, []string{"-log.format", "json"}},
+		{[]string{"--log.format=logfmt"}, []string{"--log.format=logfmt"}},
+	}
+	for _, tt := range tests {
+		if got := serviceArgs(tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("serviceArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkSnapshot measures building and scraping a snapshot of 10000
+// copies of the fixture tickets, run with -benchmem to see the allocations
+// of a cycle
+func BenchmarkSnapshot(b *testing.B) {
+	config = Config{DurationUnit: "seconds", DurationPrecision: 3, DeletedOrganizationLabel: "deleted", FieldLabelPrefix: "cf_"}
+	b.Cleanup(func() { config = Config{} })
+
+	fixtures := newFixtureSource(b)
+	source = fixtures
+
+	var tickets []*supportpal.Ticket
+	for i := 0; len(tickets) < 10000; i++ {
+		for _, fixture := range fixtures.tickets {
+			ticket := *fixture
+			ticket.ID = len(tickets) + 1
+			ticket.Subject = fmt.Sprintf("%s %d", fixture.Subject, i)
+			tickets = append(tickets, &ticket)
+		}
+	}
+
+	collector := &ticketCollector{}
+	ch := make(chan prometheus.Metric, 1024)
+	go func() {
+		for range ch {
+		}
+	}()
+	defer close(ch)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		collector.store(buildSnapshot(b, tickets))
+		collector.Collect(ch)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ticketEvent is a lifecycle event of a ticket
+type ticketEvent struct {
+	Time       int64  `json:"time"`
+	TicketID   int    `json:"ticket_id"`
+	Type       string `json:"type"`
+	Department string `json:"department"`
+	Priority   string `json:"priority"`
+	Status     string `json:"status"`
+}
+
+// eventHistory is a ring buffer of the most recent ticket events. Events
+// older than the retention are neither added nor returned. The zero value
+// keeps no history.
+type eventHistory struct {
+	mu        sync.Mutex
+	events    []ticketEvent
+	next      int
+	size      int
+	retention time.Duration
+}
+
+// ticketHistory holds the recent ticket events, it is sized in main
+var ticketHistory = &eventHistory{}
+
+var (
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "supportpal_history_events",
+		Help: "Number of ticket events kept in the in-memory history",
+	}, func() float64 { return float64(ticketHistory.len()) })
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "supportpal_history_memory_bytes",
+		Help: "Estimated memory used by the in-memory ticket event history",
+	}, func() float64 { return float64(ticketHistory.memoryBytes()) })
+)
+
+// newEventHistory returns a history keeping at most size events of the last retention
+func newEventHistory(size int, retention time.Duration) *eventHistory {
+	return &eventHistory{events: make([]ticketEvent, 0, size), size: size, retention: retention}
+}
+
+// add records an event, replacing the oldest recorded event once full
+func (h *eventHistory) add(event ticketEvent, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.size == 0 || event.Time < now.Add(-h.retention).Unix() {
+		return
+	}
+
+	if len(h.events) < h.size {
+		h.events = append(h.events, event)
+		return
+	}
+
+	h.events[h.next] = event
+	h.next = (h.next + 1) % h.size
+}
+
+// between returns the retained events of the given types between from and
+// to at now, ordered by time
+func (h *eventHistory) between(from, to, now time.Time, types map[string]bool) []ticketEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cutoff := now.Add(-h.retention); from.Before(cutoff) {
+		from = cutoff
+	}
+
+	var events []ticketEvent
+	for _, event := range h.events {
+		if types[event.Type] && event.Time >= from.Unix() && event.Time <= to.Unix() {
+			events = append(events, event)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+
+	return events
+}
+
+// export returns the recorded events oldest first for persistence
+func (h *eventHistory) export() []ticketEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make([]ticketEvent, 0, len(h.events))
+	events = append(events, h.events[h.next:]...)
+	return append(events, h.events[:h.next]...)
+}
+
+// restore replaces the recorded events with persisted ones
+func (h *eventHistory) restore(events []ticketEvent, now time.Time) {
+	h.mu.Lock()
+	h.events = h.events[:0]
+	h.next = 0
+	h.mu.Unlock()
+
+	for _, event := range events {
+		h.add(event, now)
+	}
+}
+
+// len returns the number of recorded events
+func (h *eventHistory) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.events)
+}
+
+// memoryBytes estimates the memory used by the recorded events
+func (h *eventHistory) memoryBytes() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bytes := cap(h.events) * int(unsafe.Sizeof(ticketEvent{}))
+	for _, event := range h.events {
+		bytes += len(event.Type) + len(event.Department) + len(event.Priority) + len(event.Status)
+	}
+
+	return bytes
+}
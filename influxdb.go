@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// influxBatchSize is the number of lines written per request, as recommended
+// by InfluxDB
+const influxBatchSize = 5000
+
+// influxSink writes all metrics to an InfluxDB bucket in the line protocol
+// after every cycle
+type influxSink struct {
+	url       string
+	org       string
+	bucket    string
+	tokenFile string
+	host      string
+	client    *http.Client
+	gatherer  prometheus.Gatherer
+}
+
+// Name implements Sink
+func (influxSink) Name() string { return "influxdb" }
+
+// Publish implements Sink. Runtime metrics of the Go process are left out
+// like in the textfile.
+func (i influxSink) Publish(s *snapshot) error {
+	families, err := i.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	lines := influxLines(withoutRuntimeMetrics(families), map[string]string{"host": i.host}, time.Now())
+	for batch := range slices.Chunk(lines, influxBatchSize) {
+		if err := i.write(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write sends lines gzip compressed to the write endpoint of InfluxDB 2,
+// which InfluxDB 1.8 serves as well
+func (i influxSink) write(lines []string) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	for _, line := range lines {
+		io.WriteString(zw, line+"\n")
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{"bucket": {i.bucket}, "precision": {"ms"}}
+	if i.org != "" {
+		query.Set("org", i.org)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(i.url, "/")+"/api/v2/write?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	// the token file is read for every write, so a rotated token is picked up
+	if i.tokenFile != "" {
+		token, err := os.ReadFile(i.tokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Token "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	influxTagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+// influxLines encodes the series of the families as lines of the InfluxDB
+// line protocol: the metric name is the measurement, the labels are tags and
+// the value is the field value. Empty labels are left out, as InfluxDB has
+// no empty tags, and so are NaN and infinite values, which it cannot store.
+func influxLines(families []*dto.MetricFamily, extra map[string]string, now time.Time) []string {
+	var lines []string
+	eachSample(families, extra, now, func(sample remoteSample, timestamp int64) {
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			return
+		}
+
+		var line strings.Builder
+		line.WriteString(influxMeasurementEscaper.Replace(sample.labels["__name__"]))
+		for _, name := range slices.Sorted(maps.Keys(sample.labels)) {
+			if name == "__name__" || sample.labels[name] == "" {
+				continue
+			}
+			line.WriteString("," + influxTagEscaper.Replace(name) + "=" + influxTagEscaper.Replace(sample.labels[name]))
+		}
+		line.WriteString(" value=" + strconv.FormatFloat(sample.value, 'g', -1, 64) + " " + strconv.FormatInt(timestamp, 10))
+		lines = append(lines, line.String())
+	})
+
+	return lines
+}
+
+// newInfluxSink returns the InfluxDB sink of the configuration
+func newInfluxSink(gatherer prometheus.Gatherer) influxSink {
+	host, _ := os.Hostname()
+
+	return influxSink{
+		url:       config.InfluxURL,
+		org:       config.InfluxOrg,
+		bucket:    config.InfluxBucket,
+		tokenFile: config.InfluxTokenFile,
+		host:      host,
+		client:    &http.Client{Timeout: pushTimeout},
+		gatherer:  gatherer,
+	}
+}
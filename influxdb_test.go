@@ -0,0 +1,72 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestInfluxSink(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	created := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "supportpal_tickets_created_total", Help: "Created"}, []string{"department", "priority"})
+	created.WithLabelValues("Sales, EU", "").Add(3)
+	open := prometheus.NewGauge(prometheus.GaugeOpts{Name: "supportpal_tickets_open", Help: "Open"})
+	open.Set(math.NaN())
+	age := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "supportpal_ticket_age_seconds", Help: "Age", Buckets: []float64{60}})
+	age.Observe(30)
+	reg.MustRegister(created, open, age)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("bucket") != "tickets" || r.URL.Query().Get("org") != "acme" || r.URL.Query().Get("precision") != "ms" {
+			t.Errorf("request to %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(zr)
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	sink := influxSink{url: srv.URL + "/", org: "acme", bucket: "tickets", tokenFile: tokenFile, host: "exporter", client: srv.Client(), gatherer: reg}
+	if err := sink.Publish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the timestamps differ by run
+	for i, line := range lines {
+		lines[i] = line[:strings.LastIndexByte(line, ' ')]
+	}
+	slices.Sort(lines)
+	// the empty priority and the NaN gauge are left out
+	want := []string{
+		`supportpal_ticket_age_seconds_bucket,host=exporter,le=+Inf value=1`,
+		`supportpal_ticket_age_seconds_bucket,host=exporter,le=60 value=1`,
+		`supportpal_ticket_age_seconds_count,host=exporter value=1`,
+		`supportpal_ticket_age_seconds_sum,host=exporter value=30`,
+		`supportpal_tickets_created_total,department=Sales\,\ EU,host=exporter value=3`,
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
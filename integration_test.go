@@ -0,0 +1,151 @@
+//go:build integration
+
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// TestIntegration runs a collection cycle against the SupportPal instance at
+// SUPPORTPAL_TEST_URL with the token in SUPPORTPAL_TEST_TOKEN and checks the
+// exported families and labels. SUPPORTPAL_TEST_SINCE optionally limits the
+// tickets to those created since a date. Run it with make integration-test.
+func TestIntegration(t *testing.T) {
+	baseURL, token := os.Getenv("SUPPORTPAL_TEST_URL"), os.Getenv("SUPPORTPAL_TEST_TOKEN")
+	if baseURL == "" || token == "" {
+		t.Skip("SUPPORTPAL_TEST_URL and SUPPORTPAL_TEST_TOKEN are not set")
+	}
+
+	config = Config{
+		DurationUnit:             "seconds",
+		DurationPrecision:        3,
+		DeletedOrganizationLabel: "deleted",
+		FetchMessages:            true,
+	}
+	t.Cleanup(func() { config = Config{} })
+
+	if since := os.Getenv("SUPPORTPAL_TEST_SINCE"); since != "" {
+		var err error
+		if config.SyncSince, err = parseTime(since); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apiClient = supportpal.New(supportpal.Config{
+		BaseURL:           baseURL,
+		Token:             token,
+		ListTimeout:       time.Minute,
+		EnrichmentTimeout: 10 * time.Second,
+		CacheTTL:          time.Hour,
+		SelectFields:      true,
+		Strict:            true,
+	})
+	source = apiClient
+
+	if err := apiClient.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &ticketCollector{}
+	sinks = []Sink{registrySink{collector: collector}}
+	t.Cleanup(func() { sinks = nil })
+
+	builder := newSnapshotBuilder()
+	if err := source.StreamTickets(ticketInSyncBound, builder.add); err != nil {
+		t.Fatal(err)
+	}
+	if builder.listed == 0 {
+		t.Skip("the instance has no tickets in sync")
+	}
+	builder.publish()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector)
+	gathered, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	families := make(map[string]*dto.MetricFamily)
+	for _, family := range gathered {
+		families[family.GetName()] = family
+	}
+
+	for _, name := range []string{
+		"supportpal_ticket_created_timestamp_seconds",
+		"supportpal_ticket_updated_timestamp_seconds",
+		"supportpal_ticket_replies_total",
+		"supportpal_tickets_by_channel",
+		"supportpal_label_cardinality",
+	} {
+		if families[name] == nil {
+			t.Errorf("family %s is missing", name)
+		}
+	}
+
+	created := families["supportpal_ticket_created_timestamp_seconds"]
+	if created == nil {
+		return
+	}
+	for _, metric := range created.Metric {
+		labels := make(map[string]string)
+		for _, pair := range metric.Label {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+
+		for _, name := range globaLabels {
+			if _, ok := labels[name]; !ok {
+				t.Errorf("ticket metric %v lacks label %s", labels, name)
+			}
+		}
+		for _, name := range []string{"status", "priority", "user", "ticket_url"} {
+			if labels[name] == "" {
+				t.Errorf("ticket metric %v has an empty %s label", labels, name)
+			}
+		}
+	}
+
+	drift, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range drift {
+		if family.GetName() != "supportpal_api_schema_drift" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			labels := make(map[string]string)
+			for _, pair := range metric.Label {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["change"] == "missing" {
+				t.Errorf("the API returned no %s field of %s objects", labels["field"], labels["object"])
+			} else {
+				t.Logf("the API returned an unknown %s field of %s objects", labels["field"], labels["object"])
+			}
+		}
+	}
+
+	fields, err := apiClient.ListCustomFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fieldLabels []string
+	for _, field := range fields {
+		fieldLabels = append(fieldLabels, customFieldLabel(&supportpal.GetCustomFieldResponse{Data: *field}))
+	}
+	fixed := fixedLabels()
+	for _, name := range globaLabels {
+		if !fixed[name] && !slices.Contains(fieldLabels, name) {
+			t.Errorf("label %s is not one of the listed custom fields %s", name, strings.Join(fieldLabels, ","))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// job is a named subset of the collected tickets exported with its own label
+// schema under its own metric prefix, e.g. the tickets of one department.
+// All jobs are built from the tickets of the same collection cycle.
+type job struct {
+	Name string `yaml:"name"`
+	// Prefix replaces the supportpal prefix of the metric names, defaults
+	// to supportpal_<name>
+	Prefix           string `yaml:"prefix"`
+	StatusIDs        []int  `yaml:"status_ids"`
+	DepartmentIDs    []int  `yaml:"department_ids"`
+	ExcludeStatusIDs []int  `yaml:"exclude_status_ids"`
+	// IncludeFields are the label names of the only custom field labels of
+	// the job, empty keeps all
+	IncludeFields []string `yaml:"include_fields"`
+	// ExcludeFields are the label names of custom field labels left out
+	ExcludeFields []string `yaml:"exclude_fields"`
+
+	collector *ticketCollector
+	registry  *prometheus.Registry
+}
+
+// jobsFile is the file format of --jobs.file
+type jobsFile struct {
+	Jobs []*job `yaml:"jobs"`
+}
+
+// loadJobs reads and validates a jobs file
+func loadJobs(path string) ([]*job, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f jobsFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	prefixes := make(map[string]bool)
+	for i, j := range f.Jobs {
+		if j.Name == "" {
+			return nil, fmt.Errorf("%s: job %d has no name", path, i+1)
+		}
+		if names[j.Name] {
+			return nil, fmt.Errorf("%s: job %s is declared twice", path, j.Name)
+		}
+		names[j.Name] = true
+
+		if j.Prefix == "" {
+			j.Prefix = "supportpal_" + j.Name
+		}
+		if !model.IsValidMetricName(model.LabelValue(j.Prefix)) || j.Prefix == "supportpal" {
+			return nil, fmt.Errorf("%s: job %s: invalid metric prefix %q", path, j.Name, j.Prefix)
+		}
+		if prefixes[j.Prefix] {
+			return nil, fmt.Errorf("%s: job %s: metric prefix %s is used by another job", path, j.Name, j.Prefix)
+		}
+		prefixes[j.Prefix] = true
+
+		j.collector = &ticketCollector{}
+		j.registry = prometheus.NewRegistry()
+		j.registry.MustRegister(j.collector)
+	}
+
+	return f.Jobs, nil
+}
+
+// selects reports whether the ticket belongs to the job
+func (j *job) selects(ticket *supportpal.Ticket) bool {
+	if len(j.StatusIDs) > 0 && !slices.Contains(j.StatusIDs, ticket.Status.ID) {
+		return false
+	}
+
+	if len(j.DepartmentIDs) > 0 && !slices.Contains(j.DepartmentIDs, ticket.Department.ID) {
+		return false
+	}
+
+	return !slices.Contains(j.ExcludeStatusIDs, ticket.Status.ID)
+}
+
+// schema returns the label schema of the job, the labels of the full schema
+// without the custom field labels the job leaves out
+func (j *job) schema(full []string) []string {
+	fixed := fixedLabels()
+	return slices.DeleteFunc(slices.Clone(full), func(name string) bool {
+		if fixed[name] {
+			return false
+		}
+		if len(j.IncludeFields) > 0 && !slices.Contains(j.IncludeFields, name) {
+			return true
+		}
+		return slices.Contains(j.ExcludeFields, name)
+	})
+}
+
+// build returns the snapshot of the job from the entries of its tickets with
+// the deprecated labels of the cycle. The entries are shared with the full
+// snapshot, so the job works on copies of their labels and series.
+func (j *job) build(entries []ticketEntry, deprecated map[string]time.Time) *snapshot {
+	snap := newSnapshot(j.schema(globaLabels))
+	snap.Deprecated = deprecated
+	snap.job = true
+
+	copies := make([]ticketEntry, len(entries))
+	for i, e := range entries {
+		series := *e.series
+		copies[i] = ticketEntry{copyLabels(e.labels), &series}
+	}
+	addTickets(snap, copies)
+
+	return snap
+}
+
+// copyLabels returns a copy of labels from labelPool
+func copyLabels(labels prometheus.Labels) prometheus.Labels {
+	copied := newTicketLabels()
+	maps.Copy(copied, labels)
+	return copied
+}
+
+// replaceTicket applies a webhook update of a ticket to the job snapshot,
+// removing the ticket if series is nil or the ticket left the job
+func (j *job) replaceTicket(ticket *supportpal.Ticket, id int, labels prometheus.Labels, series *ticketSeries) {
+	j.collector.update(func(s *snapshot) *snapshot {
+		if series == nil || !j.selects(ticket) {
+			return s.replaceTicket(id, nil, nil)
+		}
+
+		copied, copiedLabels := *series, copyLabels(labels)
+		defer releaseLabels(copiedLabels)
+		return s.replaceTicket(id, copiedLabels, &copied)
+	})
+}
+
+// Gather implements prometheus.Gatherer, gathering the metrics of the job
+// with the supportpal prefix of their names replaced by the job prefix
+func (j *job) Gather() ([]*dto.MetricFamily, error) {
+	families, err := j.registry.Gather()
+
+	for _, family := range families {
+		if name, ok := strings.CutPrefix(family.GetName(), "supportpal_"); ok {
+			family.Name = proto.String(j.Prefix + "_" + name)
+		}
+	}
+
+	return families, err
+}
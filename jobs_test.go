@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJobBuildKeepsSnapshotLabels(t *testing.T) {
+	defer func(limit int, schema []string) { config.MaxFieldValues, globaLabels = limit, schema }(config.MaxFieldValues, globaLabels)
+	config.MaxFieldValues = 1
+	globaLabels = append(labelSchema(nil), "product")
+
+	// the full snapshot keeps "b", the job with the first three tickets
+	// keeps "a"
+	var entries []ticketEntry
+	for i, product := range []string{"a", "a", "b", "b", "b"} {
+		labels := newTicketLabels()
+		labels["ticket_url"] = fmt.Sprintf("/%d", i+1)
+		labels["product"] = product
+		entries = append(entries, ticketEntry{labels, &ticketSeries{ID: i + 1, Department: "Sales"}})
+	}
+
+	truncated := testutil.ToFloat64(supportPalLabelTruncations.WithLabelValues("product"))
+	j := &job{Name: "first"}
+	jobSnap := j.build(entries[:3], nil)
+	snap := newSnapshot(globaLabels)
+	addTickets(snap, entries)
+
+	product := func(s *snapshot, id int) string {
+		return s.labelValue(s.ticket(id), "product")
+	}
+
+	if got := product(jobSnap, 3); got != overflowLabelValue {
+		t.Errorf("job product of ticket 3 = %q, want %q", got, overflowLabelValue)
+	}
+	for id, want := range map[int]string{1: overflowLabelValue, 2: overflowLabelValue, 3: "b", 4: "b", 5: "b"} {
+		if got := product(snap, id); got != want {
+			t.Errorf("product of ticket %d = %q, want %q", id, got, want)
+		}
+	}
+	if got := testutil.ToFloat64(supportPalLabelTruncations.WithLabelValues("product")) - truncated; got != 2 {
+		t.Errorf("truncations = %v, want 2 of the full snapshot only", got)
+	}
+}
+
+func TestJobReplaceTicketKeepsLabels(t *testing.T) {
+	j := &job{Name: "all", collector: &ticketCollector{}}
+	snap := newSnapshot([]string{"ticket_url", "product"})
+	snap.job = true
+	snap.KeptValues = map[string]map[string]struct{}{"product": {"a": {}}}
+	j.collector.store(snap)
+
+	labels := prometheus.Labels{"ticket_url": "/1", "product": "b"}
+	j.replaceTicket(&supportpal.Ticket{ID: 1}, 1, labels, &ticketSeries{ID: 1})
+
+	if labels["product"] != "b" {
+		t.Errorf("labels of the update = %v, the job changed them", labels)
+	}
+	if s := j.collector.current.Load(); s.labelValue(s.ticket(1), "product") != overflowLabelValue {
+		t.Errorf("job product = %q, want %q", s.labelValue(s.ticket(1), "product"), overflowLabelValue)
+	}
+}
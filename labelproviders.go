@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// LabelProvider adds labels to tickets from a source outside SupportPal,
+// e.g. the service tier of an organization in a CMDB. New sources are added
+// by implementing LabelProvider without touching the collection pipeline.
+type LabelProvider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+	// Labels returns the names of the labels the provider sets, they are
+	// part of the label schema and empty for tickets it has no value for
+	Labels() []string
+	// Lookup returns the labels of the tickets with the given key. Labels
+	// not returned by Labels are ignored.
+	Lookup(key LabelKey) (map[string]string, error)
+}
+
+// LabelKey is the ticket property a LabelProvider looks labels up by
+type LabelKey struct {
+	// Kind is labelKeyOrganization or labelKeyField
+	Kind string
+	// ID is the organization ID or the custom field ID
+	ID int
+	// Value is the label value of the custom field, empty for organizations
+	Value string
+}
+
+// Kinds of LabelKey
+const (
+	labelKeyOrganization = "organization"
+	labelKeyField        = "field"
+)
+
+var supportPalLabelProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_label_provider_errors_total",
+	Help: "Number of label lookups a label provider failed, the labels last looked up for the key are used meanwhile",
+}, []string{"provider"})
+
+// labelProviders are the label providers of the ticket metrics, set up in
+// parseConfig
+var labelProviders []LabelProvider
+
+// labelProviderRetry is how long a failed lookup is not repeated for the
+// same key
+const labelProviderRetry = time.Minute
+
+// configureLabelProviders returns the label providers enabled by the
+// configuration, each caching its lookups for config.CacheTTL
+func configureLabelProviders() ([]LabelProvider, error) {
+	var enabled []LabelProvider
+
+	if config.LabelProvider != "" {
+		provider, err := newHookProvider(config.LabelProvider, config.LabelProviderLabels, config.EnrichmentTimeout)
+		if err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, provider)
+	}
+
+	for i, provider := range enabled {
+		enabled[i] = newCachedLabelProvider(provider, config.CacheTTL, config.CacheMaxEntries)
+	}
+	return enabled, nil
+}
+
+// parseLabelKey parses the key of --labels.provider-key: organization or
+// field:<id>
+func parseLabelKey(s string) (LabelKey, error) {
+	kind, id, _ := strings.Cut(s, ":")
+	switch kind {
+	case labelKeyOrganization:
+		if id == "" {
+			return LabelKey{Kind: kind}, nil
+		}
+	case labelKeyField:
+		if n, err := strconv.Atoi(id); err == nil && n > 0 {
+			return LabelKey{Kind: kind, ID: n}, nil
+		}
+	}
+
+	return LabelKey{}, fmt.Errorf("invalid label provider key %q, expected organization or field:<id>", s)
+}
+
+// providerLabelNames returns the label names of all label providers
+func providerLabelNames() []string {
+	var names []string
+	for _, provider := range labelProviders {
+		names = append(names, provider.Labels()...)
+	}
+	return names
+}
+
+// addProviderLabels sets the labels of every provider for key, empty for
+// labels a provider has no value for. A zero key, e.g. of a ticket without
+// organization, looks nothing up.
+func addProviderLabels(labels prometheus.Labels, key LabelKey) {
+	for _, provider := range labelProviders {
+		for _, name := range provider.Labels() {
+			labels[name] = ""
+		}
+		if key.ID == 0 {
+			continue
+		}
+
+		values, err := provider.Lookup(key)
+		if err != nil {
+			slog.Error("failed to look up labels", "provider", provider.Name(), "kind", key.Kind, "id", key.ID, "err", err)
+			collectionError("labels")
+		}
+		for _, name := range provider.Labels() {
+			if value, ok := values[name]; ok {
+				labels[name] = value
+			}
+		}
+	}
+}
+
+// cachedLabels are the labels a provider returned for a key, with the error
+// of the last lookup if it failed
+type cachedLabels struct {
+	labels  map[string]string
+	fetched time.Time
+	err     error
+	failed  time.Time
+}
+
+// cachedLabelProvider caches the lookups of a provider for ttl, 0 caches
+// them forever, in a cache of at most maxEntries keys. When a lookup fails
+// the labels last returned for the key are used and the error is returned
+// with them; the failure is cached for labelProviderRetry, so a provider
+// that is down is not asked again for every ticket.
+type cachedLabelProvider struct {
+	LabelProvider
+	ttl time.Duration
+	// entries never expire, the labels of an outdated entry are used when
+	// the lookup fails
+	entries *supportpal.Cache[LabelKey, cachedLabels]
+}
+
+// newCachedLabelProvider returns provider caching its lookups of at most
+// maxEntries keys for ttl
+func newCachedLabelProvider(provider LabelProvider, ttl time.Duration, maxEntries int) *cachedLabelProvider {
+	return &cachedLabelProvider{
+		LabelProvider: provider,
+		ttl:           ttl,
+		entries:       supportpal.NewCache[LabelKey, cachedLabels]("labels_"+provider.Name(), 0, maxEntries),
+	}
+}
+
+// Lookup implements LabelProvider
+func (p *cachedLabelProvider) Lookup(key LabelKey) (map[string]string, error) {
+	entry, ok := p.entries.Get(key)
+	switch {
+	case ok && entry.err != nil && time.Since(entry.failed) < labelProviderRetry:
+		return entry.labels, entry.err
+	case ok && entry.err == nil && (p.ttl == 0 || time.Since(entry.fetched) < p.ttl):
+		return entry.labels, nil
+	}
+
+	labels, err := p.LabelProvider.Lookup(key)
+	if err != nil {
+		supportPalLabelProviderErrors.WithLabelValues(p.Name()).Inc()
+		entry.err, entry.failed = err, time.Now()
+		p.entries.Set(key, entry)
+		return entry.labels, err
+	}
+
+	p.entries.Set(key, cachedLabels{labels: labels, fetched: time.Now()})
+	return labels, nil
+}
+
+// hookProvider is a LabelProvider asking an HTTP endpoint or running a
+// command for the labels of a key. Both answer with a JSON object of label
+// values.
+type hookProvider struct {
+	// url is the HTTP endpoint, nil for a command
+	url     *url.URL
+	command string
+	labels  []string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// newHookProvider returns the provider of hook, an http:// or https:// URL
+// or exec: followed by the path of a command
+func newHookProvider(hook string, labels []string, timeout time.Duration) (*hookProvider, error) {
+	p := &hookProvider{labels: labels, timeout: timeout, client: &http.Client{Timeout: timeout}}
+
+	if command, ok := strings.CutPrefix(hook, "exec:"); ok {
+		if command == "" {
+			return nil, errors.New("label provider: missing command after exec:")
+		}
+		p.command = command
+		return p, nil
+	}
+
+	u, err := url.Parse(hook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("label provider: expected an http:// or https:// URL or exec:<command>, got %q", hook)
+	}
+	p.url = u
+	return p, nil
+}
+
+// Name implements LabelProvider
+func (p *hookProvider) Name() string {
+	if p.url != nil {
+		return "http"
+	}
+	return "exec"
+}
+
+// Labels implements LabelProvider
+func (p *hookProvider) Labels() []string {
+	return p.labels
+}
+
+// Lookup implements LabelProvider. The endpoint is requested with the kind,
+// id and value of the key as query parameters, 404 Not Found means no
+// labels. The command is run with them as arguments.
+func (p *hookProvider) Lookup(key LabelKey) (map[string]string, error) {
+	var body []byte
+	var err error
+	if p.url != nil {
+		body, err = p.request(key)
+	} else {
+		body, err = p.run(key)
+	}
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil, err
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(body, &labels); err != nil {
+		return nil, fmt.Errorf("label provider %s: expected a JSON object of label values: %w", p.Name(), err)
+	}
+	return labels, nil
+}
+
+// request asks the HTTP endpoint for the labels of key
+func (p *hookProvider) request(key LabelKey) ([]byte, error) {
+	u := *p.url
+	query := u.Query()
+	query.Set("kind", key.Kind)
+	query.Set("id", strconv.Itoa(key.ID))
+	if key.Value != "" {
+		query.Set("value", key.Value)
+	}
+	u.RawQuery = query.Encode()
+
+	resp, err := p.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("label provider http: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// run runs the command with the kind, id and value of key as arguments and
+// returns its output. The arguments follow "--", so a value starting with a
+// dash is not taken for an option.
+func (p *hookProvider) run(key LabelKey) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	args := []string{"--", key.Kind, strconv.Itoa(key.ID)}
+	if key.Kind == labelKeyField {
+		args = append(args, key.Value)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		return nil, fmt.Errorf("label provider exec: %w: %s", err, msg[:min(len(msg), 512)])
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// labelRule rewrites the values of a label that match a regular expression,
+// e.g. to map several priorities to one or to strip subject prefixes
+type labelRule struct {
+	// Label is the name of the rewritten label
+	Label string `yaml:"label"`
+	// Regex must match the whole value, like in Prometheus relabeling
+	Regex string `yaml:"regex"`
+	// Replacement is the new value, $1 and ${name} refer to capture groups
+	Replacement string `yaml:"replacement"`
+
+	regex *regexp.Regexp
+}
+
+// labelRulesFile is the file format of --metrics.label-rules-file
+type labelRulesFile struct {
+	Rules []*labelRule `yaml:"rules"`
+}
+
+// loadLabelRules reads and validates a label rules file
+func loadLabelRules(path string) ([]*labelRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f labelRulesFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, r := range f.Rules {
+		if !model.LabelName(r.Label).IsValid() {
+			return nil, fmt.Errorf("%s: rule %d: invalid label name %q", path, i+1, r.Label)
+		}
+
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d of %s: %w", path, i+1, r.Label, err)
+		}
+	}
+
+	return f.Rules, nil
+}
+
+// compile compiles the regular expression of the rule anchored at both ends
+func (r *labelRule) compile() (err error) {
+	r.regex, err = regexp.Compile("^(?:" + r.Regex + ")$")
+	return err
+}
+
+// rewriteLabel returns value rewritten by the rules of the label in order,
+// every rule sees the value returned by the previous one
+func rewriteLabel(name, value string) string {
+	for _, r := range config.LabelRules {
+		if r.Label != name {
+			continue
+		}
+
+		if match := r.regex.FindStringSubmatchIndex(value); match != nil {
+			value = string(r.regex.ExpandString(nil, r.Replacement, value, match))
+		}
+	}
+
+	return value
+}
+
+// rewriteTicket applies the label rules to the labels of a ticket and to the
+// fields of its series that are exported as labels of the aggregated metrics,
+// so both agree. The organization metrics take the rewritten client label.
+func rewriteTicket(labels prometheus.Labels, series *ticketSeries) {
+	if len(config.LabelRules) == 0 {
+		return
+	}
+
+	for name, value := range labels {
+		labels[name] = rewriteLabel(name, value)
+	}
+
+	series.Status = rewriteLabel("status", series.Status)
+	series.Priority = rewriteLabel("priority", series.Priority)
+	series.Channel = rewriteLabel("channel", series.Channel)
+	series.Department = rewriteLabel("department", series.Department)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/gosimple/unidecode"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizations of name label values, see config.LabelValues
+const (
+	// labelValuesLowercase lowercases names by the rules of
+	// config.LabelLanguage, e.g. jürgen müller for Jürgen Müller
+	labelValuesLowercase = "lowercase"
+	// labelValuesKeep keeps names as shown in SupportPal
+	labelValuesKeep = "keep"
+	// labelValuesSlug slugifies names, transliterated by the rules of
+	// config.LabelLanguage, e.g. juergen-mueller for Jürgen Müller in German
+	labelValuesSlug = "slug"
+	// labelValuesTransliterate transliterates names to lowercase ASCII and
+	// keeps their spaces and punctuation, e.g. jurgen muller
+	labelValuesTransliterate = "transliterate"
+)
+
+// labelLanguage is the parsed config.LabelLanguage, und if it is empty
+var labelLanguage language.Tag
+
+// lowercase lowercases s by the rules of labelLanguage, e.g. I to ı in Turkish
+func lowercase(s string) string {
+	// a Caser keeps state and is not shared between the tickets built concurrently
+	return cases.Lower(labelLanguage).String(s)
+}
+
+// slugLanguage returns the language slugs are transliterated by
+func slugLanguage() string {
+	if config.LabelLanguage == "" {
+		return "en"
+	}
+	base, _ := labelLanguage.Base()
+	return base.String()
+}
+
+// makeSlug returns the slug of s, transliterated by the rules of labelLanguage
+func makeSlug(s string) string {
+	return slug.MakeLang(s, slugLanguage())
+}
+
+// normalizeName returns the label value of a name such as a user, status or
+// channel by config.LabelValues. Names are composed to Unicode NFC first, so
+// the same name entered with combining characters yields the same value.
+func normalizeName(s string) string {
+	s = norm.NFC.String(s)
+
+	switch config.LabelValues {
+	case labelValuesKeep:
+		return s
+	case labelValuesSlug:
+		return makeSlug(s)
+	case labelValuesTransliterate:
+		return strings.ToLower(unidecode.Unidecode(s))
+	default:
+		return lowercase(s)
+	}
+}
+
+// normalizeText returns the label value of free text, such as the value of
+// a text custom field. It is kept as entered unless config.LabelValues
+// restricts values to ASCII.
+func normalizeText(s string) string {
+	switch config.LabelValues {
+	case labelValuesSlug, labelValuesTransliterate:
+		return normalizeName(s)
+	default:
+		return norm.NFC.String(s)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var supportPalExporterLeader = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "supportpal_exporter_leader",
+	Help: "1 while this replica is the leader polling the API, 0 while it is on standby serving the state persisted by the leader",
+})
+
+// leaderLock is the lock replicas elect their leader with, see --ha.lock
+type leaderLock interface {
+	// acquire takes the lock for owner or renews it if owner holds it and
+	// reports whether owner holds it for ttl from now
+	acquire(owner string, ttl time.Duration) (bool, error)
+	// release frees the lock if owner holds it
+	release(owner string) error
+}
+
+// parseLeaderLock returns the lock of --ha.lock: a redis:// or rediss:// URL
+// or the path of a lock file on a volume shared by the replicas
+func parseLeaderLock(s string) (leaderLock, error) {
+	if strings.HasPrefix(s, "redis://") || strings.HasPrefix(s, "rediss://") {
+		store, err := supportpal.NewRedisStore(s)
+		if err != nil {
+			return nil, err
+		}
+		return &redisLock{store: store}, nil
+	}
+
+	path := strings.TrimPrefix(s, "file://")
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("lock file directory: %w", err)
+	}
+	return &fileLock{path: path}, nil
+}
+
+// redisLock is a leaderLock held as a Redis key with expiry
+type redisLock struct {
+	store *supportpal.RedisStore
+}
+
+// acquire implements leaderLock
+func (l *redisLock) acquire(owner string, ttl time.Duration) (bool, error) {
+	return l.store.Lease("leader", owner, ttl)
+}
+
+// release implements leaderLock
+func (l *redisLock) release(owner string) error {
+	return l.store.Release("leader", owner)
+}
+
+// fileLock is a leaderLock held as a file naming the owner and the expiry
+// of its lease. Replicas taking an expired lock at the same time may both
+// lead until the next renewal, the file system offers no atomic comparison.
+type fileLock struct {
+	path string
+}
+
+// acquire implements leaderLock
+func (l *fileLock) acquire(owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	holder, expires, err := l.read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if holder != "" && holder != owner && now.Before(expires) {
+		return false, nil
+	}
+
+	tmp := l.path + ".tmp." + owner
+	data := owner + " " + strconv.FormatInt(now.Add(ttl).UnixNano(), 10) + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+
+	// a replica that took the lock at the same time and renamed last wins
+	holder, _, err = l.read()
+	return holder == owner, err
+}
+
+// release implements leaderLock
+func (l *fileLock) release(owner string) error {
+	holder, _, err := l.read()
+	if err != nil || holder != owner {
+		return err
+	}
+	return os.Remove(l.path)
+}
+
+// read returns the owner of the lock file and the expiry of its lease
+func (l *fileLock) read() (owner string, expires time.Time, err error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	owner, nanos, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("lock file %s: invalid expiry %q", l.path, nanos)
+	}
+	return owner, time.Unix(0, n), nil
+}
+
+// leading is set while this replica is the leader, always without --ha.lock
+var leading atomic.Bool
+
+// leadership is closed when this replica becomes the leader for the first time
+var (
+	leadership     = make(chan struct{})
+	leadershipOnce sync.Once
+)
+
+// leaderIdentity returns the owner name of this replica in the lock
+func leaderIdentity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+// startElection makes this replica the leader without --ha.lock, otherwise
+// it campaigns for the lock in the background
+func startElection() {
+	if config.LeaderLock == nil {
+		becomeLeader()
+		return
+	}
+
+	supportPalExporterLeader.Set(0)
+	leaderElection = &election{lock: config.LeaderLock, owner: leaderIdentity()}
+	go leaderElection.run()
+	go releaseOnShutdown()
+}
+
+// becomeLeader marks this replica as leader
+func becomeLeader() {
+	leading.Store(true)
+	supportPalExporterLeader.Set(1)
+	leadershipOnce.Do(func() { close(leadership) })
+}
+
+// leaderRole returns leader or standby with --ha.lock, empty without
+func leaderRole() string {
+	switch {
+	case config.LeaderLock == nil:
+		return ""
+	case leading.Load():
+		return "leader"
+	default:
+		return "standby"
+	}
+}
+
+// awaitLeadership blocks until this replica became the leader
+func awaitLeadership() {
+	if config.LeaderLock != nil && !leading.Load() {
+		slog.Info("on standby, waiting for leadership")
+	}
+	<-leadership
+}
+
+// leaderElection is the campaign of this replica with --ha.lock
+var leaderElection *election
+
+// election is the campaign of owner for the lock
+type election struct {
+	lock  leaderLock
+	owner string
+
+	// mu orders the rounds and the release at shutdown, no round runs once
+	// the lock was released
+	mu            sync.Mutex
+	released      bool
+	renewed       time.Time
+	stateModified time.Time
+}
+
+// run takes or renews the lock three times per lease until it was released
+func (e *election) run() {
+	for e.round(time.Now()) {
+		notifier.notifyAlive()
+		time.Sleep(config.LeaseDuration / 3)
+	}
+}
+
+// round takes or renews the lock once and reports whether the campaign
+// continues. The leader steps down when it could not renew the lock within
+// a lease, a standby reloads the state the leader persisted in the
+// meantime, so it serves the latest metrics and continues the counters when
+// it takes over.
+func (e *election) round(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.released {
+		return false
+	}
+
+	held, err := e.lock.acquire(e.owner, config.LeaseDuration)
+	switch {
+	case err != nil:
+		slog.Error("failed to renew the leader lock", "err", err)
+		held = leading.Load() && now.Sub(e.renewed) < config.LeaseDuration
+	case held:
+		e.renewed = now
+	}
+
+	switch {
+	case held && !leading.Load():
+		// the state of the former leader is continued
+		e.stateModified = reloadLeaderState(e.stateModified)
+		slog.Info("became the leader, polling the API", "owner", e.owner)
+		becomeLeader()
+	case !held && leading.Load():
+		slog.Warn("lost the leader lock, on standby", "owner", e.owner)
+		leading.Store(false)
+		supportPalExporterLeader.Set(0)
+	case !held:
+		e.stateModified = reloadLeaderState(e.stateModified)
+	}
+
+	return true
+}
+
+// release ends the campaign and frees the lock if this replica holds it, so
+// a standby takes over without waiting for the lease to expire
+func (e *election) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.released {
+		return
+	}
+
+	e.released = true
+	if err := e.lock.release(e.owner); err != nil {
+		slog.Error("failed to release the leader lock", "err", err)
+	} else if leading.Load() {
+		slog.Info("released the leader lock", "owner", e.owner)
+	}
+	leading.Store(false)
+	supportPalExporterLeader.Set(0)
+}
+
+// stepDown releases the leader lock at shutdown, without --ha.lock it does
+// nothing
+func stepDown() {
+	if leaderElection != nil {
+		leaderElection.release()
+	}
+}
+
+// releaseOnShutdown releases the leader lock and exits on SIGINT or SIGTERM
+func releaseOnShutdown() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	sig := <-stop
+	slog.Info("shutting down", "signal", sig.String())
+	stepDown()
+	os.Exit(0)
+}
+
+// reloadLeaderState restores the state the leader persisted to --state.path
+// if it changed since modified and returns its modification time. The
+// persisted counters are reset first, they are restored by adding to them.
+func reloadLeaderState(modified time.Time) time.Time {
+	if config.StatePath == "" {
+		return modified
+	}
+
+	info, err := os.Stat(config.StatePath)
+	if err != nil || !info.ModTime().After(modified) {
+		return modified
+	}
+
+	state, err := loadState(config.StatePath)
+	if err != nil {
+		slog.Warn("failed to load the state of the leader", "path", config.StatePath, "err", err)
+		return modified
+	}
+
+	for _, vec := range persistedCounters {
+		vec.Reset()
+	}
+	restoreState(state)
+	notifier.notifyReady()
+	slog.Debug("loaded the state of the leader", "path", config.StatePath, "series", len(state.Snapshot.Series))
+
+	return info.ModTime()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLeaderLock(t *testing.T) {
+	lock, err := parseLeaderLock(filepath.Join(t.TempDir(), "leader.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if held, err := lock.acquire("a", time.Minute); err != nil || !held {
+		t.Fatalf("first acquire = %v, %v, want held", held, err)
+	}
+	if held, err := lock.acquire("b", time.Minute); err != nil || held {
+		t.Fatalf("acquire of a held lock = %v, %v, want not held", held, err)
+	}
+	if held, err := lock.acquire("a", -time.Second); err != nil || !held {
+		t.Fatalf("renewal = %v, %v, want held", held, err)
+	}
+	// the lease of a expired
+	if held, err := lock.acquire("b", time.Minute); err != nil || !held {
+		t.Fatalf("acquire of an expired lock = %v, %v, want held", held, err)
+	}
+
+	// only the owner releases the lock
+	if err := lock.release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := lock.acquire("a", time.Minute); err != nil || held {
+		t.Fatalf("acquire after a release by another owner = %v, %v, want not held", held, err)
+	}
+	if err := lock.release("b"); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := lock.acquire("a", time.Minute); err != nil || !held {
+		t.Fatalf("acquire of a released lock = %v, %v, want held", held, err)
+	}
+}
+
+func TestFileLeaderLockRace(t *testing.T) {
+	lock := &fileLock{path: filepath.Join(t.TempDir(), "leader.lock")}
+	owners := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	round := func() []bool {
+		held := make([]bool, len(owners))
+		var wg sync.WaitGroup
+		for i, owner := range owners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var err error
+				if held[i], err = lock.acquire(owner, time.Minute); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		return held
+	}
+
+	count := func(held []bool) (n int) {
+		for _, h := range held {
+			if h {
+				n++
+			}
+		}
+		return n
+	}
+
+	// replicas taking the free lock at the same time may all believe to
+	// hold it, the first renewal leaves one leader
+	if n := count(round()); n == 0 {
+		t.Fatal("no replica took the free lock")
+	}
+	if n := count(round()); n != 1 {
+		t.Errorf("%d replicas hold the lock after the renewal, want 1", n)
+	}
+}
+
+// scriptedLock is a leaderLock answering the acquisitions with results in
+// order
+type scriptedLock struct {
+	results  []scriptedResult
+	acquired int
+	released string
+}
+
+type scriptedResult struct {
+	held bool
+	err  error
+}
+
+func (l *scriptedLock) acquire(owner string, ttl time.Duration) (bool, error) {
+	r := l.results[l.acquired]
+	l.acquired++
+	return r.held, r.err
+}
+
+func (l *scriptedLock) release(owner string) error {
+	l.released = owner
+	return nil
+}
+
+func TestElection(t *testing.T) {
+	defer func(c Config, labels []string, s *snapshot) {
+		config, globaLabels = c, labels
+		supportPalTickets.store(s)
+		leading.Store(false)
+		supportPalExporterLeader.Set(0)
+	}(config, globaLabels, supportPalTickets.current.Load())
+
+	config.LeaseDuration = 30 * time.Second
+	config.StatePath = filepath.Join(t.TempDir(), "state")
+	leading.Store(false)
+
+	// persist writes the state of a leader serving ticket id
+	modified := time.Now()
+	persist := func(id int) {
+		t.Helper()
+		s := newSnapshot([]string{"status"})
+		s.Series = []*ticketSeries{{ID: id, LabelValues: []string{"Open"}}}
+		if err := saveState(config.StatePath, &persistedState{Version: stateVersion, Snapshot: s}); err != nil {
+			t.Fatal(err)
+		}
+		modified = modified.Add(time.Second)
+		if err := os.Chtimes(config.StatePath, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	served := func() int {
+		return supportPalTickets.current.Load().Series[0].ID
+	}
+
+	failed := errors.New("unreachable")
+	lock := &scriptedLock{results: []scriptedResult{
+		{held: false},
+		{held: true},
+		{err: failed},
+		{err: failed},
+		{held: true},
+	}}
+	e := &election{lock: lock, owner: "replica"}
+	start := time.Now()
+
+	persist(1)
+	e.round(start)
+	if leading.Load() || served() != 1 {
+		t.Fatalf("standby: leading %v, serving ticket %d, want the state of the leader", leading.Load(), served())
+	}
+
+	// the state persisted by the former leader is continued
+	persist(2)
+	e.round(start.Add(10 * time.Second))
+	if !leading.Load() || served() != 2 {
+		t.Fatalf("takeover: leading %v, serving ticket %d, want the latest state", leading.Load(), served())
+	}
+
+	// a failed renewal is tolerated within the lease
+	e.round(start.Add(20 * time.Second))
+	if !leading.Load() {
+		t.Fatal("stepped down on a failed renewal within the lease")
+	}
+	e.round(start.Add(45 * time.Second))
+	if leading.Load() {
+		t.Fatal("still leading after the lease could not be renewed")
+	}
+
+	e.round(start.Add(50 * time.Second))
+	if !leading.Load() {
+		t.Fatal("did not take the lock again")
+	}
+
+	e.release()
+	if lock.released != "replica" || leading.Load() {
+		t.Errorf("release: released by %q, leading %v", lock.released, leading.Load())
+	}
+	if e.round(start.Add(60*time.Second)) || lock.acquired != len(lock.results) {
+		t.Error("campaign continued after the release")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// leaderboardQuantiles are the quantiles of the operator leaderboard
+var leaderboardQuantiles = []float64{0.5, 0.9}
+
+// quantileValues returns the nearest-rank quantiles of durations in seconds
+// in the configured duration unit, sorting durations
+func quantileValues(durations []int64) map[float64]float64 {
+	slices.Sort(durations)
+
+	values := make(map[float64]float64, len(leaderboardQuantiles))
+	for _, q := range leaderboardQuantiles {
+		i := int(q*float64(len(durations))+0.5) - 1
+		values[q] = durationValue(time.Duration(durations[max(i, 0)]) * time.Second)
+	}
+
+	return values
+}
+
+// collectLeaderboard sends the first response times of every operator for
+// the tickets they first responded to and the resolution times of every
+// operator for the tickets assigned to them, both within the leaderboard
+// window, as summaries
+func collectLeaderboard(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if !config.Leaderboard {
+		return
+	}
+
+	responseDesc := prometheus.NewDesc(durationName("supportpal_operator_first_response"),
+		durationHelp("First response times of the tickets an operator responded to first within the leaderboard window"), []string{"operator"}, nil)
+	resolutionDesc := prometheus.NewDesc(durationName("supportpal_operator_resolution"),
+		durationHelp("Resolution times of the tickets assigned to an operator resolved within the leaderboard window"), []string{"operator"}, nil)
+
+	responses := make(map[string][]int64)
+	resolutions := make(map[string][]int64)
+	since := now.Add(-config.LeaderboardWindow).Unix()
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		if series.FirstResponder != "" && series.FirstResponse >= since {
+			responses[series.FirstResponder] = append(responses[series.FirstResponder], series.FirstResponse-series.Created)
+		}
+
+		if series.Resolved != 0 && series.Resolved >= since {
+			for _, operator := range series.Assigned {
+				resolutions[operator] = append(resolutions[operator], series.Resolved-series.Created)
+			}
+		}
+	}
+
+	sendDurationSummaries(ch, responseDesc, responses)
+	sendDurationSummaries(ch, resolutionDesc, resolutions)
+}
+
+// sendDurationSummaries sends a summary of the durations in seconds of every
+// operator
+func sendDurationSummaries(ch chan<- prometheus.Metric, desc *prometheus.Desc, durations map[string][]int64) {
+	for operator, d := range durations {
+		var sum int64
+		for _, v := range d {
+			sum += v
+		}
+
+		ch <- prometheus.MustNewConstSummary(desc, uint64(len(d)), durationValue(time.Duration(sum)*time.Second),
+			quantileValues(d), operator)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	supportPalTicketsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_tickets_created_total",
+		Help: "Number of tickets created, counted once per ticket across collection cycles",
+	}, []string{"department", "priority"})
+
+	supportPalTicketsResolved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_tickets_resolved_total",
+		Help: "Number of times tickets were resolved, counted once per resolution across collection cycles",
+	}, []string{"department", "priority"})
+
+	supportPalTicketsDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_tickets_deleted_total",
+		Help: "Number of tickets deleted, counted once per deletion across collection cycles",
+	}, []string{"department", "priority"})
+
+	supportPalTicketsReopened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_ticket_reopened_total",
+		Help: "Number of times resolved tickets were opened again, counted across collection cycles",
+	}, []string{"department", "priority"})
+
+	supportPalTicketPriorityChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_ticket_priority_changes_total",
+		Help: "Number of ticket priority changes observed between collection cycles",
+	}, []string{"from", "to"})
+)
+
+// lifecycleState is the last observed lifecycle of a ticket
+type lifecycleState struct {
+	Created  int64 `json:"created"`
+	Resolved int64 `json:"resolved,omitempty"`
+	Deleted  int64 `json:"deleted,omitempty"`
+	// Escalated is the due time the ticket passed unresolved
+	Escalated int64 `json:"escalated,omitempty"`
+	// Priority is empty in state persisted by earlier versions
+	Priority string `json:"priority,omitempty"`
+	// Status is the last observed status, set since StatusSince
+	Status      string `json:"status,omitempty"`
+	StatusSince int64  `json:"status_since,omitempty"`
+	// Responded is set once the first response was observed
+	Responded bool `json:"responded,omitempty"`
+}
+
+// lifecycleTracker counts ticket lifecycle events once across collection
+// cycles. A ticket resolved or deleted again after being reopened or
+// restored counts again. Until the tracker is primed, by a successful cycle
+// or restored state, tickets are only recorded as seen, so a restart without
+// state does not count the tickets of the whole window again.
+type lifecycleTracker struct {
+	mu     sync.Mutex
+	seen   map[int]lifecycleState
+	primed bool
+}
+
+var ticketLifecycle = &lifecycleTracker{seen: make(map[int]lifecycleState)}
+
+// lifecycleCounters are the counters of the event types that are counted
+var lifecycleCounters = map[string]*prometheus.CounterVec{
+	"created":  supportPalTicketsCreated,
+	"resolved": supportPalTicketsResolved,
+	"deleted":  supportPalTicketsDeleted,
+	"reopened": supportPalTicketsReopened,
+}
+
+// observe records the lifecycle events of the ticket not recorded before in
+// the history and counts them. A ticket is escalated when it passes its due
+// time unresolved. Reopenings and priority changes are only seen between
+// cycles, the API has no history of them, so they are recorded at the
+// latest update of the ticket and a ticket resolved again within one cycle
+// is not counted as reopened. The time in a status is measured from the
+// latest update when the status was first seen, when the status was set at
+// the latest. Resolution and first response times are observed once per
+// resolution and ticket.
+func (t *lifecycleTracker) observe(series *ticketSeries, priority string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !t.primed {
+		t.seen[series.ID] = baselineLifecycle(series, priority, now)
+		return
+	}
+
+	last, ok := t.seen[series.ID]
+	state := lifecycleState{Created: series.Created, Resolved: series.Resolved, Deleted: series.Deleted, Escalated: last.Escalated, Priority: priority}
+
+	record := func(event string, ts int64) {
+		if counter, ok := lifecycleCounters[event]; ok {
+			incTicketCounter(counter.WithLabelValues(series.Department, priority), series.ID, series.URL)
+		}
+		if event == "created" && config.OrganizationMetrics && series.Organization != "" {
+			incTicketCounter(supportPalOrganizationTicketsCreated.WithLabelValues(organizationLabelValues(series)...), series.ID, series.URL)
+		}
+
+		ticketHistory.add(ticketEvent{
+			Time:       ts,
+			TicketID:   series.ID,
+			Type:       event,
+			Department: series.Department,
+			Priority:   priority,
+			Status:     series.Status,
+		}, now)
+	}
+
+	// excluded trashed tickets only count as deleted
+	if trashedExcluded(series) {
+		if series.Deleted != last.Deleted {
+			record("deleted", series.Deleted)
+		}
+		last.Created, last.Deleted = series.Created, series.Deleted
+		t.seen[series.ID] = last
+		return
+	}
+
+	if !ok {
+		record("created", series.Created)
+	}
+
+	if series.Resolved != 0 && series.Resolved != last.Resolved {
+		record("resolved", series.Resolved)
+		if series.ResolvedBy != "" {
+			incTicketCounter(supportPalTicketsResolvedByOperator.WithLabelValues(series.Department, series.ResolvedBy), series.ID, series.URL)
+		}
+		observeDuration(supportPalTicketResolutionDuration, series.Created, series.Resolved, series.Department, priority)
+	}
+
+	state.Responded = last.Responded || series.FirstResponse != 0
+	if series.FirstResponse != 0 && !last.Responded {
+		observeDuration(supportPalTicketFirstResponseDuration, series.Created, series.FirstResponse, series.Department, priority)
+	}
+
+	if series.Deleted != 0 && series.Deleted != last.Deleted {
+		record("deleted", series.Deleted)
+	}
+
+	if ok && last.Resolved != 0 && series.Resolved == 0 && series.Deleted == 0 {
+		record("reopened", series.Updated)
+	}
+
+	if ok && last.Priority != "" && last.Priority != priority {
+		incTicketCounter(supportPalTicketPriorityChanges.WithLabelValues(last.Priority, priority), series.ID, series.URL)
+	}
+
+	state.Status, state.StatusSince = series.Status, last.StatusSince
+	if last.Status != series.Status {
+		if last.Status != "" && series.Updated > last.StatusSince {
+			statusDurations.observe(last.Status, series.Department, time.Duration(series.Updated-last.StatusSince)*time.Second)
+		}
+		state.StatusSince = series.Updated
+	}
+
+	if series.Due != 0 && series.Due <= now.Unix() && series.Due != last.Escalated &&
+		(series.Resolved == 0 || series.Resolved > series.Due) {
+		record("escalated", series.Due)
+		state.Escalated = series.Due
+	}
+
+	t.seen[series.ID] = state
+}
+
+// baselineLifecycle returns the lifecycle of a ticket seen before the tracker
+// was primed, its past events are taken as counted already
+func baselineLifecycle(series *ticketSeries, priority string, now time.Time) lifecycleState {
+	state := lifecycleState{
+		Created:     series.Created,
+		Resolved:    series.Resolved,
+		Deleted:     series.Deleted,
+		Priority:    priority,
+		Status:      series.Status,
+		StatusSince: series.Updated,
+		Responded:   series.FirstResponse != 0,
+	}
+
+	if series.Due != 0 && series.Due <= now.Unix() && (series.Resolved == 0 || series.Resolved > series.Due) {
+		state.Escalated = series.Due
+	}
+
+	return state
+}
+
+// prime starts counting the events of the tickets observed from now on
+func (t *lifecycleTracker) prime() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.primed = true
+}
+
+// export returns a copy of the observed lifecycles for persistence
+func (t *lifecycleTracker) export() map[int]lifecycleState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return maps.Clone(t.seen)
+}
+
+// restore replaces the observed lifecycles with persisted ones
+func (t *lifecycleTracker) restore(seen map[int]lifecycleState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seen = make(map[int]lifecycleState, len(seen))
+	maps.Copy(t.seen, seen)
+	t.primed = true
+}
+
+// prune forgets tickets created before cutoff, they are no longer collected
+func (t *lifecycleTracker) prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, state := range t.seen {
+		if state.Created < cutoff.Unix() {
+			delete(t.seen, id)
+		}
+	}
+}
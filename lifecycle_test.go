@@ -0,0 +1,200 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// lifecycleCount returns the value of a lifecycle counter of department
+func lifecycleCount(event, department string) float64 {
+	return testutil.ToFloat64(lifecycleCounters[event].WithLabelValues(department, "Low"))
+}
+
+func TestLifecycleFirstCycle(t *testing.T) {
+	tracker := &lifecycleTracker{seen: make(map[int]lifecycleState)}
+	now := time.Now().Unix()
+	department := "lifecycle-first"
+
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Open", Created: now - 3600, Updated: now - 60}, "Low")
+	tracker.observe(&ticketSeries{ID: 2, Department: department, Status: "Closed", Created: now - 7200, Updated: now - 600, Resolved: now - 600}, "Low")
+	tracker.observe(&ticketSeries{ID: 3, Department: department, Status: "Open", Created: now - 7200, Updated: now - 600, Deleted: now - 600}, "Low")
+	for _, event := range []string{"created", "resolved", "deleted"} {
+		if got := lifecycleCount(event, department); got != 0 {
+			t.Errorf("%s counted %v times in the first cycle, want 0", event, got)
+		}
+	}
+
+	tracker.prime()
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Closed", Created: now - 3600, Updated: now, Resolved: now}, "Low")
+	tracker.observe(&ticketSeries{ID: 2, Department: department, Status: "Closed", Created: now - 7200, Updated: now - 600, Resolved: now - 600}, "Low")
+	tracker.observe(&ticketSeries{ID: 4, Department: department, Status: "Open", Created: now, Updated: now}, "Low")
+
+	if got := lifecycleCount("created", department); got != 1 {
+		t.Errorf("created = %v, want 1 for the ticket created after the first cycle", got)
+	}
+	if got := lifecycleCount("resolved", department); got != 1 {
+		t.Errorf("resolved = %v, want 1 for the ticket resolved after the first cycle", got)
+	}
+}
+
+func TestLifecycleRestart(t *testing.T) {
+	now := time.Now().Unix()
+	department := "lifecycle-restart"
+	series := &ticketSeries{ID: 1, Department: department, Status: "Closed", Created: now - 3600, Updated: now, Resolved: now}
+
+	tracker := &lifecycleTracker{seen: make(map[int]lifecycleState), primed: true}
+	tracker.observe(series, "Low")
+	if got := lifecycleCount("created", department); got != 1 {
+		t.Fatalf("created = %v, want 1", got)
+	}
+
+	restarted := &lifecycleTracker{seen: make(map[int]lifecycleState)}
+	restarted.restore(tracker.export())
+	restarted.observe(series, "Low")
+	restarted.observe(&ticketSeries{ID: 2, Department: department, Status: "Open", Created: now, Updated: now}, "Low")
+
+	if got := lifecycleCount("created", department); got != 2 {
+		t.Errorf("created = %v after the restart, want 2: only the new ticket counts", got)
+	}
+	if got := lifecycleCount("resolved", department); got != 1 {
+		t.Errorf("resolved = %v after the restart, want 1", got)
+	}
+}
+
+func TestLifecycleReopen(t *testing.T) {
+	now := time.Now().Unix()
+	department := "lifecycle-reopen"
+	tracker := &lifecycleTracker{seen: make(map[int]lifecycleState), primed: true}
+
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Closed", Created: now - 3600, Updated: now - 600, Resolved: now - 600}, "Low")
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Open", Created: now - 3600, Updated: now - 300}, "Low")
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Open", Created: now - 3600, Updated: now - 300}, "Low")
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Status: "Closed", Created: now - 3600, Updated: now, Resolved: now}, "Low")
+
+	if got := lifecycleCount("reopened", department); got != 1 {
+		t.Errorf("reopened = %v, want 1", got)
+	}
+	if got := lifecycleCount("resolved", department); got != 2 {
+		t.Errorf("resolved = %v, want 2: the resolution after the reopening counts again", got)
+	}
+	if got := lifecycleCount("created", department); got != 1 {
+		t.Errorf("created = %v, want 1", got)
+	}
+}
+
+func TestLifecycleOrganizationCreated(t *testing.T) {
+	defer func(c Config) { config = c }(config)
+	config = Config{OrganizationMetrics: true, OrganizationIDLabel: true}
+
+	now := time.Now().Unix()
+	department := "lifecycle-organization"
+	tracker := &lifecycleTracker{seen: make(map[int]lifecycleState), primed: true}
+
+	// two organizations sharing a name are told apart by their ID
+	tracker.observe(&ticketSeries{ID: 1, Department: department, Organization: "Lifecycle Org", OrganizationID: 1, Client: "lifecycleorg", Created: now}, "Low")
+	tracker.observe(&ticketSeries{ID: 2, Department: department, Organization: "Lifecycle Org", OrganizationID: 2, Client: "lifecycleorg", Created: now}, "Low")
+	tracker.observe(&ticketSeries{ID: 2, Department: department, Organization: "Lifecycle Org", OrganizationID: 2, Client: "lifecycleorg", Created: now, Resolved: now}, "Low")
+	tracker.observe(&ticketSeries{ID: 3, Department: department, Created: now}, "Low")
+
+	for id, want := range map[string]float64{"1": 1, "2": 1} {
+		if got := testutil.ToFloat64(supportPalOrganizationTicketsCreated.WithLabelValues("lifecycleorg", id)); got != want {
+			t.Errorf("created of organization %s = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestLifecycleCountedOnPublish(t *testing.T) {
+	defer func(lifecycle *lifecycleTracker, replies *replyTracker, duplicates *duplicateTracker, labels []string, s *snapshot) {
+		ticketLifecycle, operatorReplies, ticketDuplicates, globaLabels = lifecycle, replies, duplicates, labels
+		supportPalTickets.store(s)
+	}(ticketLifecycle, operatorReplies, ticketDuplicates, globaLabels, supportPalTickets.current.Load())
+	ticketLifecycle = &lifecycleTracker{seen: make(map[int]lifecycleState), primed: true}
+	operatorReplies = &replyTracker{seen: make(map[int]int64)}
+	ticketDuplicates = &duplicateTracker{seen: make(map[int]int64)}
+
+	fixtures := newFixtureSource(t)
+	source = fixtures
+	ticket := *fixtures.tickets[0]
+	// the fixtures are older than the collection window
+	ticket.CreatedAt = time.Now().Unix()
+
+	labels, series := buildTicket(&ticket)
+	created := func() float64 {
+		return testutil.ToFloat64(lifecycleCounters["created"].WithLabelValues(series.Department, labels["priority"]))
+	}
+	before := created()
+
+	// a cycle failing after the page of the ticket counts nothing
+	failed := newSnapshotBuilder()
+	failed.add(&ticket)
+	failed.discard()
+	if got := created() - before; got != 0 {
+		t.Fatalf("failed cycle counted %v created tickets, want 0", got)
+	}
+
+	b := newSnapshotBuilder()
+	b.add(&ticket)
+	if got := created() - before; got != 0 {
+		t.Fatalf("counted %v created tickets before the cycle completed, want 0", got)
+	}
+	b.publish()
+	if got := created() - before; got != 1 {
+		t.Errorf("counted %v created tickets after the cycle completed, want 1", got)
+	}
+}
+
+// messageSource serves the fixtures with the same messages for every ticket
+type messageSource struct {
+	*fixtureSource
+	messages []*supportpal.Message
+}
+
+func (s messageSource) TicketMessages(ticket *supportpal.Ticket) ([]*supportpal.Message, error) {
+	return s.messages, nil
+}
+
+func TestMessagesCountedOnPublish(t *testing.T) {
+	defer func(c Config, src TicketSource, lifecycle *lifecycleTracker, replies *replyTracker, labels []string, s *snapshot) {
+		config, source, ticketLifecycle, operatorReplies, globaLabels = c, src, lifecycle, replies, labels
+		supportPalTickets.store(s)
+	}(config, source, ticketLifecycle, operatorReplies, globaLabels, supportPalTickets.current.Load())
+	config = Config{
+		FetchMessages: true,
+		LabelRules:    []*labelRule{{Label: "department", Regex: ".*", Replacement: "messages-rewritten"}},
+	}
+	if err := config.LabelRules[0].compile(); err != nil {
+		t.Fatal(err)
+	}
+	ticketLifecycle = &lifecycleTracker{seen: make(map[int]lifecycleState), primed: true}
+	operatorReplies = &replyTracker{seen: make(map[int]int64), primed: true}
+
+	fixtures := newFixtureSource(t)
+	source = messageSource{fixtures, []*supportpal.Message{
+		{ID: 1, CreatedAt: time.Now().Unix(), Attachments: []*supportpal.Attachment{{ID: 1}, {ID: 2}}},
+		{ID: 2, CreatedAt: time.Now().Unix()},
+	}}
+	ticket := *fixtures.tickets[0]
+	ticket.CreatedAt = time.Now().Unix()
+	counts := func() (float64, float64) {
+		return testutil.ToFloat64(supportPalTicketMessages.WithLabelValues("messages-rewritten")),
+			testutil.ToFloat64(supportPalTicketAttachments.WithLabelValues("messages-rewritten"))
+	}
+
+	// a failed cycle counts nothing, the messages count with the next
+	failed := newSnapshotBuilder()
+	failed.add(&ticket)
+	failed.discard()
+	if messages, attachments := counts(); messages != 0 || attachments != 0 {
+		t.Fatalf("failed cycle counted %v messages and %v attachments, want none", messages, attachments)
+	}
+
+	b := newSnapshotBuilder()
+	b.add(&ticket)
+	b.publish()
+	if messages, attachments := counts(); messages != 2 || attachments != 2 {
+		t.Errorf("counted %v messages and %v attachments by the rewritten department, want 2 and 2", messages, attachments)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// newLogger returns a logger writing records of at least level to w in the given format
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "logfmt":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "journald":
+		return slog.New(newJournaldHandler(w, opts)), nil
+	case "eventlog":
+		h, err := newEventLogHandler(opts)
+		if err != nil {
+			return nil, err
+		}
+		return slog.New(h), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json, logfmt, journald or eventlog", format)
+	}
+}
+
+// journaldHandler writes logfmt records without the time, which journald
+// records itself, prefixed with the syslog priority of their level like
+// <3>, so journalctl -p filters and highlights them
+type journaldHandler struct {
+	mu   *sync.Mutex
+	buf  *bytes.Buffer
+	w    io.Writer
+	text slog.Handler
+}
+
+// newJournaldHandler returns a handler writing records in the journald format to w
+func newJournaldHandler(w io.Writer, opts *slog.HandlerOptions) *journaldHandler {
+	buf := &bytes.Buffer{}
+	return &journaldHandler{mu: &sync.Mutex{}, buf: buf, w: w, text: slog.NewTextHandler(buf, withoutTime(opts))}
+}
+
+// withoutTime returns opts dropping the time of records, for log sinks
+// recording it themselves
+func withoutTime(opts *slog.HandlerOptions) *slog.HandlerOptions {
+	textOpts := *opts
+	textOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return &textOpts
+}
+
+// journaldPriority returns the syslog priority of a log level
+func journaldPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// Enabled implements slog.Handler
+func (h *journaldHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.text.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *journaldHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.text.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(h.w, "<%d>%s", journaldPriority(r.Level), h.buf.Bytes())
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &journaldHandler{mu: h.mu, buf: h.buf, w: h.w, text: h.text.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	return &journaldHandler{mu: h.mu, buf: h.buf, w: h.w, text: h.text.WithGroup(name)}
+}
+
+// fatal logs msg at error level and exits the process
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a planned maintenance period
+type maintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// parseMaintenanceWindows parses a comma separated list of RFC 3339
+// intervals in the form start/end
+func parseMaintenanceWindows(s string) ([]maintenanceWindow, error) {
+	var windows []maintenanceWindow
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "/", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("maintenance window %q: expected start/end", part)
+		}
+
+		start, err := time.Parse(time.RFC3339, bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %w", part, err)
+		}
+
+		end, err := time.Parse(time.RFC3339, bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %w", part, err)
+		}
+
+		if !end.After(start) {
+			return nil, fmt.Errorf("maintenance window %q: end must be after start", part)
+		}
+
+		windows = append(windows, maintenanceWindow{Start: start, End: end})
+	}
+
+	return windows, nil
+}
+
+// inMaintenance reports whether t falls into one of the configured maintenance windows
+func inMaintenance(t time.Time) bool {
+	for _, w := range config.MaintenanceWindows {
+		if !t.Before(w.Start) && t.Before(w.End) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// countsAsResponse reports whether an operator message counts as a response
+// the customer experienced. Internal notes only count when configured.
+func countsAsResponse(m *supportpal.Message) bool {
+	if m.By != supportpal.ByOperator {
+		return false
+	}
+
+	return m.Type != supportpal.MessageInternalNote || config.ResponseIncludeNotes
+}
+
+// lastResponse returns the time of the latest operator response to the
+// ticket or 0 if the operator has not responded yet
+func lastResponse(messages []*supportpal.Message) int64 {
+	var last int64
+	for _, m := range messages {
+		if countsAsResponse(m) && m.CreatedAt > last {
+			last = m.CreatedAt
+		}
+	}
+
+	return last
+}
+
+// replyCount returns the number of public replies by users and operators
+func replyCount(messages []*supportpal.Message) int {
+	n := 0
+	for _, m := range messages {
+		if m.Type != supportpal.MessageInternalNote {
+			n++
+		}
+	}
+	return n
+}
+
+// lastReplier returns operator or user depending on who sent the latest
+// public reply, or an empty string if there is none
+func lastReplier(messages []*supportpal.Message) string {
+	var last *supportpal.Message
+	for _, m := range messages {
+		if m.Type != supportpal.MessageInternalNote && (last == nil || m.CreatedAt >= last.CreatedAt) {
+			last = m
+		}
+	}
+
+	switch {
+	case last == nil:
+		return ""
+	case last.By == supportpal.ByOperator:
+		return "operator"
+	default:
+		return "user"
+	}
+}
+
+// waitingOnCustomer reports whether an open ticket waits on the customer
+// rather than on an operator: its status is one of config.CustomerStatusIDs
+// or an operator sent the latest public reply
+func waitingOnCustomer(ticket *supportpal.Ticket, lastReplyBy string) bool {
+	return slices.Contains(config.CustomerStatusIDs, ticket.Status.ID) || lastReplyBy == "operator"
+}
+
+// customerWaits returns the periods the ticket waited on the customer, from
+// a public operator reply to the next public reply of the user, and the
+// start of the wait still going on, 0 if the user replied last
+func customerWaits(messages []*supportpal.Message) (waits [][2]int64, since int64) {
+	public := make([]*supportpal.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Type != supportpal.MessageInternalNote {
+			public = append(public, m)
+		}
+	}
+	slices.SortStableFunc(public, func(a, b *supportpal.Message) int { return int(a.CreatedAt - b.CreatedAt) })
+
+	for _, m := range public {
+		switch {
+		case m.By == supportpal.ByOperator && since == 0:
+			since = m.CreatedAt
+		case m.By != supportpal.ByOperator && since != 0:
+			waits = append(waits, [2]int64{since, m.CreatedAt})
+			since = 0
+		}
+	}
+
+	return waits, since
+}
+
+// firstResponse returns the first operator response to the ticket or nil if
+// the operator has not responded yet
+func firstResponse(ticket *supportpal.Ticket, messages []*supportpal.Message) *supportpal.Message {
+	var first *supportpal.Message
+	for _, m := range messages {
+		if m.CreatedAt < ticket.CreatedAt || !countsAsResponse(m) {
+			continue
+		}
+
+		if first == nil || m.CreatedAt < first.CreatedAt {
+			first = m
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultNamespace is the prefix of the metric names the metrics are
+// registered with
+const defaultNamespace = "supportpal"
+
+// namespaceGatherer moves the metrics gathered from the wrapped gatherer from
+// the supportpal namespace to another one, e.g. supportpal_tickets to
+// staging_supportpal_tickets. Metrics of other namespaces, such as the Go
+// runtime metrics, keep their names.
+type namespaceGatherer struct {
+	gatherer  prometheus.Gatherer
+	namespace string
+}
+
+// Gather implements prometheus.Gatherer
+func (g namespaceGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	for _, family := range families {
+		if name, ok := strings.CutPrefix(family.GetName(), defaultNamespace+"_"); ok {
+			family.Name = proto.String(g.namespace + "_" + name)
+		}
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families, err
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// openMetricsUnits are the units announced in # UNIT lines of the OpenMetrics
+// format for families whose name ends with them
+var openMetricsUnits = []string{"seconds", "milliseconds", "bytes", "ratio"}
+
+// createdTracker remembers when every counter series served in the
+// OpenMetrics format was created, exported as its _created sample
+type createdTracker struct {
+	mu sync.Mutex
+	// created holds the creation time in unix seconds by family name and
+	// labels, 0 if it is unknown
+	created map[string]float64
+	// start is the start time of the process. A counter series not restored
+	// counts from 0 since then, whenever it is first incremented.
+	start float64
+}
+
+var counterCreated = &createdTracker{created: make(map[string]float64), start: unixSeconds(time.Now())}
+
+// unixSeconds returns t as unix time in seconds with millisecond precision
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// counterKey returns the key of a counter series by the name of its family
+// and its labels
+func counterKey(name string, labels map[string]string) string {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for _, label := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(label), Value: proto.String(labels[label])})
+	}
+	return strings.TrimSuffix(name, "_total") + openMetricsLabels(pairs)
+}
+
+// observe returns the creation times of the given series keys and remembers
+// the keys not seen before as created at the start of the process
+func (t *createdTracker) observe(keys []string) []float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	created := make([]float64, len(keys))
+	for i, key := range keys {
+		c, ok := t.created[key]
+		if !ok {
+			c = t.start
+			t.created[key] = c
+		}
+		created[i] = c
+	}
+
+	return created
+}
+
+// export returns a copy of the creation times for persistence
+func (t *createdTracker) export() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return maps.Clone(t.created)
+}
+
+// restore replaces the creation times with persisted ones. The creation time
+// of the restored counter series missing from them is unknown, they get
+// none.
+func (t *createdTracker) restore(created map[string]float64, restored []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.created = make(map[string]float64, len(created))
+	maps.Copy(t.created, created)
+	for _, key := range restored {
+		if _, ok := t.created[key]; !ok {
+			t.created[key] = 0
+		}
+	}
+}
+
+var openMetricsLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// openMetricsLabels returns the labels of a sample as written in the
+// OpenMetrics format, empty without labels
+func openMetricsLabels(pairs []*dto.LabelPair) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(pairs))
+	for i, pair := range pairs {
+		parts[i] = pair.GetName() + `="` + openMetricsLabelEscaper.Replace(pair.GetValue()) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// writeOpenMetrics writes families in the OpenMetrics format with a # UNIT
+// line for families named after their unit and a _created sample for every
+// counter whose creation time is known
+func writeOpenMetrics(w io.Writer, families []*dto.MetricFamily) error {
+	var keys, labels []string
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_COUNTER || !strings.HasSuffix(family.GetName(), "_total") {
+			continue
+		}
+		for _, m := range family.Metric {
+			l := openMetricsLabels(m.Label)
+			keys = append(keys, strings.TrimSuffix(family.GetName(), "_total")+l)
+			labels = append(labels, l)
+		}
+	}
+	created := counterCreated.observe(keys)
+
+	sample := 0
+	for _, family := range families {
+		var buf bytes.Buffer
+		if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, family); err != nil {
+			return err
+		}
+
+		name := family.GetName()
+		counter := family.GetType() == dto.MetricType_COUNTER && strings.HasSuffix(name, "_total")
+		name = strings.TrimSuffix(name, "_total")
+
+		for _, line := range strings.SplitAfter(buf.String(), "\n") {
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+
+			switch {
+			case line == "" || strings.HasPrefix(line, "# HELP "):
+			case strings.HasPrefix(line, "# TYPE "):
+				for _, unit := range openMetricsUnits {
+					if strings.HasSuffix(name, "_"+unit) {
+						fmt.Fprintf(w, "# UNIT %s %s\n", name, unit)
+						break
+					}
+				}
+			case counter:
+				if sample < len(created) && created[sample] != 0 {
+					fmt.Fprintf(w, "%s_created%s %s\n", name, labels[sample], strconv.FormatFloat(created[sample], 'f', -1, 64))
+				}
+				sample++
+			}
+		}
+	}
+
+	_, err := expfmt.FinalizeOpenMetrics(w)
+	return err
+}
+
+// metricsHandler serves the metrics of gatherer. Scrapers negotiating the
+// OpenMetrics format get units and counter creation times, promhttp serves
+// the other formats.
+func metricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	fallback := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format != expfmt.FmtOpenMetrics {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := writeOpenMetrics(&buf, families); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write(buf.Bytes())
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write(buf.Bytes())
+		zw.Close()
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var supportPalOperatorReplies = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_operator_replies_total",
+	Help: "Number of replies sent by an operator, use increase() over 1d for replies per day",
+}, []string{"operator"})
+
+var supportPalTicketMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_ticket_messages_total",
+	Help: "Number of ticket messages, replies and internal notes, counted once per message across collection cycles",
+}, []string{"department"})
+
+var supportPalTicketAttachments = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_ticket_attachments_total",
+	Help: "Number of files attached to ticket messages, counted once per message across collection cycles",
+}, []string{"department"})
+
+// replyTracker counts every ticket message with its attachments and every
+// operator reply once across collection cycles. Until it is primed, messages
+// are only recorded as seen.
+type replyTracker struct {
+	mu     sync.Mutex
+	seen   map[int]int64
+	primed bool
+}
+
+var operatorReplies = &replyTracker{seen: make(map[int]int64)}
+
+// observe counts the messages of the ticket of series and the operator
+// replies among them that were not seen before, by the department of series.
+// The messages are released afterwards.
+func (t *replyTracker) observe(series *ticketSeries) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	messages := series.messages
+	series.messages = nil
+	for _, m := range messages {
+		if _, ok := t.seen[m.ID]; ok {
+			continue
+		}
+
+		t.seen[m.ID] = m.CreatedAt
+		if !t.primed {
+			continue
+		}
+
+		supportPalTicketMessages.WithLabelValues(series.Department).Inc()
+		supportPalTicketAttachments.WithLabelValues(series.Department).Add(float64(len(m.Attachments)))
+		if countsAsResponse(m) {
+			supportPalOperatorReplies.WithLabelValues(operatorName(m.User.FormattedName)).Inc()
+		}
+	}
+}
+
+// prime starts counting the messages observed from now on
+func (t *replyTracker) prime() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.primed = true
+}
+
+// export returns a copy of the counted replies for persistence
+func (t *replyTracker) export() map[int]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return maps.Clone(t.seen)
+}
+
+// restore replaces the counted replies with persisted ones
+func (t *replyTracker) restore(seen map[int]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seen = make(map[int]int64, len(seen))
+	maps.Copy(t.seen, seen)
+	t.primed = true
+}
+
+// prune forgets replies created before cutoff, they belong to tickets no
+// longer collected and will not be seen again
+func (t *replyTracker) prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, created := range t.seen {
+		if created < cutoff.Unix() {
+			delete(t.seen, id)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/unidecode"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizations of organization names in the client label
+const (
+	// organizationNamesCompact lowercases names and removes spaces, e.g.
+	// acmegmbh for ACME GmbH
+	organizationNamesCompact = "compact"
+	// organizationNamesSlug slugifies names, e.g. acme-gmbh
+	organizationNamesSlug = "slug"
+	// organizationNamesLowercase lowercases names, e.g. acme gmbh
+	organizationNamesLowercase = "lowercase"
+	// organizationNamesKeep keeps names as shown in SupportPal
+	organizationNamesKeep = "keep"
+	// organizationNamesTransliterate transliterates names to lowercase
+	// ASCII, e.g. muller & sohne for Müller & Söhne
+	organizationNamesTransliterate = "transliterate"
+)
+
+// organizationLabel returns the client label of an organization name
+// normalized according to config.OrganizationNames. Names are lowercased and
+// transliterated by the rules of config.LabelLanguage.
+func organizationLabel(name string) string {
+	name = norm.NFC.String(name)
+
+	switch config.OrganizationNames {
+	case organizationNamesSlug:
+		return makeSlug(name)
+	case organizationNamesLowercase:
+		return lowercase(name)
+	case organizationNamesKeep:
+		return name
+	case organizationNamesTransliterate:
+		return strings.ToLower(unidecode.Unidecode(name))
+	default:
+		return lowercase(strings.ReplaceAll(name, " ", ""))
+	}
+}
+
+var supportPalOrganizationOpenTicketsDesc = prometheus.NewDesc("supportpal_organization_open_tickets",
+	"Number of open tickets of users of an organization", []string{"organization", "organization_id"}, nil)
+
+var supportPalOrganizationTicketsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_organization_tickets_created_total",
+	Help: "Number of tickets created by users of an organization, counted once per ticket across collection cycles",
+}, []string{"organization", "organization_id"})
+
+// organizationLabelValues returns the organization and organization_id
+// label values of the organization metrics of a ticket, the organization is
+// its exported client label. The ID is empty without
+// config.OrganizationIDLabel, organizations sharing a name are counted
+// together then.
+func organizationLabelValues(series *ticketSeries) []string {
+	id := ""
+	if config.OrganizationIDLabel {
+		id = strconv.Itoa(series.OrganizationID)
+	}
+
+	return []string{series.Client, id}
+}
+
+// resolutionRatioWindow is the trailing window of the client resolution ratio
+const resolutionRatioWindow = 30 * 24 * time.Hour
+
+var supportPalClientResolutionRatioDesc = prometheus.NewDesc("supportpal_client_resolution_ratio",
+	"Collected tickets of an organization resolved within the last 30 days divided by those created within them, +Inf if none were created",
+	[]string{"client"}, nil)
+
+// collectOrganizations sends the open ticket counts and resolution ratios of
+// every organization in s. Tickets of users without organization or whose
+// organization could not be looked up are not counted.
+func collectOrganizations(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if !config.OrganizationMetrics {
+		return
+	}
+
+	open := make(labelCounts)
+
+	for _, series := range s.Series {
+		if series.Organization == "" || series.Deleted != 0 {
+			continue
+		}
+
+		var v float64
+		if series.open() {
+			v = 1
+		}
+		open.add(v, organizationLabelValues(series)...)
+	}
+
+	open.emit(ch, supportPalOrganizationOpenTicketsDesc, prometheus.GaugeValue)
+
+	collectResolutionRatios(ch, s, now)
+}
+
+// collectResolutionRatios sends the resolution ratio of every organization
+// with tickets created or resolved within the window, a ratio below 1 means
+// its backlog grows
+func collectResolutionRatios(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	created := make(map[string]int)
+	resolved := make(map[string]int)
+	since := now.Add(-resolutionRatioWindow).Unix()
+
+	for _, series := range s.Series {
+		if series.Organization == "" || series.Deleted != 0 {
+			continue
+		}
+
+		if series.Created >= since {
+			created[series.Client]++
+		}
+		if series.Resolved != 0 && series.Resolved >= since {
+			resolved[series.Client]++
+		}
+	}
+
+	for org, n := range created {
+		ch <- prometheus.MustNewConstMetric(supportPalClientResolutionRatioDesc, prometheus.GaugeValue, float64(resolved[org])/float64(n), org)
+	}
+
+	for org := range resolved {
+		if _, ok := created[org]; !ok {
+			ch <- prometheus.MustNewConstMetric(supportPalClientResolutionRatioDesc, prometheus.GaugeValue, math.Inf(1), org)
+		}
+	}
+}
+
+// organizationSummary holds the ticket counts of an organization
+type organizationSummary struct {
+	Organization         string         `json:"organization"`
+	Total                int            `json:"total"`
+	Open                 int            `json:"open"`
+	ByStatus             map[string]int `json:"by_status"`
+	OldestWaitingSeconds int64          `json:"oldest_waiting_seconds"`
+}
+
+// summarizeOrganizations returns the ticket counts of every organization in
+// s at now, ordered by name
+func summarizeOrganizations(s *snapshot, now time.Time) []*organizationSummary {
+	byName := make(map[string]*organizationSummary)
+
+	for _, series := range s.Series {
+		if series.Organization == "" || series.Deleted != 0 {
+			continue
+		}
+
+		org, ok := byName[series.Organization]
+		if !ok {
+			org = &organizationSummary{Organization: series.Organization, ByStatus: make(map[string]int)}
+			byName[series.Organization] = org
+		}
+
+		org.Total++
+		org.ByStatus[series.Status]++
+
+		if series.open() {
+			org.Open++
+
+			if waiting := now.Unix() - series.Created; waiting > org.OldestWaitingSeconds {
+				org.OldestWaitingSeconds = waiting
+			}
+		}
+	}
+
+	orgs := make([]*organizationSummary, 0, len(byName))
+	for _, org := range byName {
+		orgs = append(orgs, org)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Organization < orgs[j].Organization })
+
+	return orgs
+}
+
+// organizationsHandler serves the organization summaries of the current snapshot as JSON
+func organizationsHandler(w http.ResponseWriter, r *http.Request) {
+	s := supportPalTickets.current.Load()
+	if s == nil {
+		http.Error(w, "no data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(collectionInterval.Seconds())))
+	w.Header().Set("Last-Modified", s.CollectedAt.UTC().Format(http.TimeFormat))
+
+	if err := json.NewEncoder(w).Encode(summarizeOrganizations(s, time.Now())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpAggregationCumulative is the cumulative AggregationTemporality of OTLP
+const otlpAggregationCumulative = 2
+
+// otlpSink exports all metrics to an OpenTelemetry collector with OTLP over
+// HTTP after every cycle, alongside the Prometheus endpoint
+type otlpSink struct {
+	endpoint string
+	headers  map[string]string
+	resource map[string]string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+}
+
+// Name implements Sink
+func (otlpSink) Name() string { return "otlp" }
+
+// Publish implements Sink. Runtime metrics of the Go process are left out
+// like in the textfile.
+func (o otlpSink) Publish(s *snapshot) error {
+	families, err := o.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	body := otlpRequest(withoutRuntimeMetrics(families), o.resource, startedAt, time.Now())
+	return otlpPost(o.client, o.endpoint, o.headers, body)
+}
+
+// otlpPost sends an OTLP/HTTP request in the protobuf encoding to endpoint
+func otlpPost(client *http.Client, endpoint string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// newOTLPSink returns the OTLP sink of the configuration
+func newOTLPSink(gatherer prometheus.Gatherer) otlpSink {
+	return otlpSink{
+		endpoint: config.OTLPEndpoint,
+		headers:  config.OTLPHeaders,
+		resource: otlpResource(),
+		client:   &http.Client{Timeout: pushTimeout},
+		gatherer: gatherer,
+	}
+}
+
+// otlpResource returns the resource attributes of the exported metrics and traces
+func otlpResource() map[string]string {
+	host, _ := os.Hostname()
+
+	return map[string]string{
+		"service.name":        "supportpal-prom-exporter",
+		"service.version":     exporterVersion(),
+		"service.instance.id": host,
+	}
+}
+
+// parseOTLPHeaders parses a comma separated list of name=value headers
+func parseOTLPHeaders(list string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, item := range splitList(list) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected name=value", item)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return headers, nil
+}
+
+// otlpRequest encodes the families as OTLP ExportMetricsServiceRequest
+// protobuf. Counters become cumulative monotonic sums starting at start,
+// gauges and untyped metrics gauges.
+func otlpRequest(families []*dto.MetricFamily, resource map[string]string, start, now time.Time) []byte {
+	var scopeMetrics []byte
+	scopeMetrics = appendMessage(scopeMetrics, 1, otlpScope())
+	for _, family := range families {
+		if metric := otlpMetric(family, uint64(start.UnixNano()), uint64(now.UnixNano())); metric != nil {
+			scopeMetrics = appendMessage(scopeMetrics, 2, metric)
+		}
+	}
+
+	var resourceMetrics []byte
+	resourceMetrics = appendMessage(resourceMetrics, 1, otlpResourceMessage(resource))
+	resourceMetrics = appendMessage(resourceMetrics, 2, scopeMetrics)
+
+	return appendMessage(nil, 1, resourceMetrics)
+}
+
+// otlpResourceMessage encodes a Resource message with the given attributes
+func otlpResourceMessage(resource map[string]string) []byte {
+	var res []byte
+	for _, name := range sortedKeys(resource) {
+		res = appendMessage(res, 1, otlpAttribute(name, resource[name]))
+	}
+
+	return res
+}
+
+// otlpScope encodes the InstrumentationScope message of the exporter
+func otlpScope() []byte {
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, "supportpal-prom-exporter")
+	scope = protowire.AppendTag(scope, 2, protowire.BytesType)
+	return protowire.AppendString(scope, exporterVersion())
+}
+
+// otlpMetric encodes a Metric message, nil for empty families and
+// unsupported types
+func otlpMetric(family *dto.MetricFamily, start, now uint64) []byte {
+	if len(family.Metric) == 0 {
+		return nil
+	}
+
+	var points []byte
+	var field protowire.Number
+	cumulative := false
+
+	for _, metric := range family.Metric {
+		var point []byte
+		attributes := 7
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			field, cumulative = 7, true
+			point = otlpNumberPoint(start, now, metric.Counter.GetValue())
+		case dto.MetricType_GAUGE:
+			field = 5
+			point = otlpNumberPoint(0, now, metric.Gauge.GetValue())
+		case dto.MetricType_UNTYPED:
+			field = 5
+			point = otlpNumberPoint(0, now, metric.Untyped.GetValue())
+		case dto.MetricType_SUMMARY:
+			field = 11
+			point = otlpSummaryPoint(start, now, metric.Summary)
+		case dto.MetricType_HISTOGRAM:
+			field, cumulative, attributes = 9, true, 9
+			point = otlpHistogramPoint(start, now, metric.Histogram)
+		default:
+			return nil
+		}
+
+		for _, pair := range metric.Label {
+			point = appendMessage(point, protowire.Number(attributes), otlpAttribute(pair.GetName(), pair.GetValue()))
+		}
+		points = appendMessage(points, 1, point)
+	}
+
+	if cumulative {
+		points = protowire.AppendTag(points, 2, protowire.VarintType)
+		points = protowire.AppendVarint(points, otlpAggregationCumulative)
+	}
+	if family.GetType() == dto.MetricType_COUNTER {
+		points = protowire.AppendTag(points, 3, protowire.VarintType)
+		points = protowire.AppendVarint(points, 1)
+	}
+
+	var metric []byte
+	metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+	metric = protowire.AppendString(metric, family.GetName())
+	metric = protowire.AppendTag(metric, 2, protowire.BytesType)
+	metric = protowire.AppendString(metric, family.GetHelp())
+
+	return appendMessage(metric, field, points)
+}
+
+// otlpNumberPoint encodes a NumberDataPoint without attributes, a start of 0
+// is left out
+func otlpNumberPoint(start, now uint64, value float64) []byte {
+	var point []byte
+	if start != 0 {
+		point = appendFixed64(point, 2, start)
+	}
+	point = appendFixed64(point, 3, now)
+	return appendFixed64(point, 4, math.Float64bits(value))
+}
+
+// otlpSummaryPoint encodes a SummaryDataPoint without attributes
+func otlpSummaryPoint(start, now uint64, summary *dto.Summary) []byte {
+	point := appendFixed64(nil, 2, start)
+	point = appendFixed64(point, 3, now)
+	point = appendFixed64(point, 4, summary.GetSampleCount())
+	point = appendFixed64(point, 5, math.Float64bits(summary.GetSampleSum()))
+
+	for _, q := range summary.Quantile {
+		quantile := appendFixed64(nil, 1, math.Float64bits(q.GetQuantile()))
+		quantile = appendFixed64(quantile, 2, math.Float64bits(q.GetValue()))
+		point = appendMessage(point, 6, quantile)
+	}
+
+	return point
+}
+
+// otlpHistogramPoint encodes a HistogramDataPoint without attributes. The
+// cumulative Prometheus buckets become the per bucket counts of OTLP.
+func otlpHistogramPoint(start, now uint64, histogram *dto.Histogram) []byte {
+	point := appendFixed64(nil, 2, start)
+	point = appendFixed64(point, 3, now)
+	point = appendFixed64(point, 4, histogram.GetSampleCount())
+	point = appendFixed64(point, 5, math.Float64bits(histogram.GetSampleSum()))
+
+	var counts, bounds []byte
+	var previous uint64
+	for _, bucket := range histogram.Bucket {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, bucket.GetCumulativeCount()-previous)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(bucket.GetUpperBound()))
+		previous = bucket.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, histogram.GetSampleCount()-previous)
+
+	point = appendMessage(point, 6, counts)
+	return appendMessage(point, 7, bounds)
+}
+
+// otlpAttribute encodes a KeyValue message with a string value
+func otlpAttribute(key, value string) []byte {
+	var anyValue []byte
+	anyValue = protowire.AppendTag(anyValue, 1, protowire.BytesType)
+	anyValue = protowire.AppendString(anyValue, value)
+
+	var kv []byte
+	kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+	kv = protowire.AppendString(kv, key)
+	return appendMessage(kv, 2, anyValue)
+}
+
+// appendMessage appends the embedded message msg as field num
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendFixed64 appends v as fixed64 or double field num
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
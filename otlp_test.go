@@ -0,0 +1,98 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPRequest(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	created := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "supportpal_tickets_created_total", Help: "Created"}, []string{"department"})
+	created.WithLabelValues("Sales").Add(3)
+	open := prometheus.NewGauge(prometheus.GaugeOpts{Name: "supportpal_tickets_open", Help: "Open"})
+	open.Set(5)
+	age := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "supportpal_ticket_age_seconds", Help: "Age", Buckets: []float64{60, 120}}, []string{"priority"})
+	age.WithLabelValues("High").Observe(30)
+	age.WithLabelValues("High").Observe(90)
+	age.WithLabelValues("High").Observe(300)
+	reply := prometheus.NewSummary(prometheus.SummaryOpts{Name: "supportpal_reply_seconds", Help: "Reply", Objectives: map[float64]float64{0.5: 0.05}})
+	reply.Observe(10)
+	reg.MustRegister(created, open, age, reply)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, now := time.Unix(1767225600, 0), time.Unix(1767229200, 0)
+	body := otlpRequest(families, map[string]string{"service.name": "supportpal-prom-exporter"}, start, now)
+
+	// an ExportMetricsServiceRequest shares the encoding of MetricsData
+	var req metricspb.MetricsData
+	if err := proto.Unmarshal(body, &req); err != nil {
+		t.Fatalf("body is no ExportMetricsServiceRequest: %v", err)
+	}
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("request = %v, want one resource and scope", &req)
+	}
+	resource := req.ResourceMetrics[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || resource[0].Value.GetStringValue() != "supportpal-prom-exporter" {
+		t.Errorf("resource attributes = %v", resource)
+	}
+	scope := req.ResourceMetrics[0].ScopeMetrics[0]
+	if scope.Scope.Name != "supportpal-prom-exporter" {
+		t.Errorf("scope = %v", scope.Scope)
+	}
+
+	metrics := make(map[string]*metricspb.Metric)
+	for _, m := range scope.Metrics {
+		metrics[m.Name] = m
+	}
+	if len(metrics) != 4 {
+		t.Fatalf("%d metrics, want 4", len(metrics))
+	}
+	startNano, nowNano := uint64(start.UnixNano()), uint64(now.UnixNano())
+
+	sum := metrics["supportpal_tickets_created_total"].GetSum()
+	if sum == nil || !sum.IsMonotonic || sum.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE || len(sum.DataPoints) != 1 {
+		t.Fatalf("counter = %v, want a cumulative monotonic sum", metrics["supportpal_tickets_created_total"])
+	}
+	if p := sum.DataPoints[0]; p.GetAsDouble() != 3 || p.StartTimeUnixNano != startNano || p.TimeUnixNano != nowNano ||
+		len(p.Attributes) != 1 || p.Attributes[0].Key != "department" || p.Attributes[0].Value.GetStringValue() != "Sales" {
+		t.Errorf("counter point = %v", p)
+	}
+	if metrics["supportpal_tickets_created_total"].Description != "Created" {
+		t.Errorf("counter description = %q", metrics["supportpal_tickets_created_total"].Description)
+	}
+
+	gauge := metrics["supportpal_tickets_open"].GetGauge()
+	if gauge == nil || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].GetAsDouble() != 5 || gauge.DataPoints[0].StartTimeUnixNano != 0 {
+		t.Errorf("gauge = %v", metrics["supportpal_tickets_open"])
+	}
+
+	histogram := metrics["supportpal_ticket_age_seconds"].GetHistogram()
+	if histogram == nil || histogram.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE || len(histogram.DataPoints) != 1 {
+		t.Fatalf("histogram = %v", metrics["supportpal_ticket_age_seconds"])
+	}
+	p := histogram.DataPoints[0]
+	// the cumulative buckets become per bucket counts, the last one above
+	// the highest bound
+	if p.Count != 3 || p.GetSum() != 420 || !slices.Equal(p.BucketCounts, []uint64{1, 1, 1}) || !slices.Equal(p.ExplicitBounds, []float64{60, 120}) {
+		t.Errorf("histogram point = %v", p)
+	}
+	if len(p.Attributes) != 1 || p.Attributes[0].Key != "priority" || p.Attributes[0].Value.GetStringValue() != "High" {
+		t.Errorf("histogram attributes = %v", p.Attributes)
+	}
+
+	summary := metrics["supportpal_reply_seconds"].GetSummary()
+	if summary == nil || len(summary.DataPoints) != 1 {
+		t.Fatalf("summary = %v", metrics["supportpal_reply_seconds"])
+	}
+	if q := summary.DataPoints[0]; q.Count != 1 || q.Sum != 10 || len(q.QuantileValues) != 1 || q.QuantileValues[0].Quantile != 0.5 || q.QuantileValues[0].Value != 10 {
+		t.Errorf("summary point = %v", q)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// snapshotMagic identifies a persisted snapshot file
+var snapshotMagic = []byte("SPS1")
+
+// stateVersion is the version of the persisted state format written by this
+// exporter. Bump it and add a migration whenever the format changes.
+const stateVersion = 4
+
+// persistedState is the document stored in a snapshot file. Besides the
+// snapshot it holds what counters need to continue across restarts.
+type persistedState struct {
+	Version  int       `json:"version"`
+	Snapshot *snapshot `json:"snapshot"`
+	// Lifecycle holds the last observed lifecycle of every ticket by ID
+	Lifecycle map[int]lifecycleState `json:"lifecycle,omitempty"`
+	// Replies holds the creation time of every counted reply by message ID
+	Replies map[int]int64 `json:"replies,omitempty"`
+	// Duplicates holds the creation time of every counted duplicate by ticket ID
+	Duplicates map[int]int64 `json:"duplicates,omitempty"`
+	// Counters holds the values of the persisted counters
+	Counters []counterValue `json:"counters,omitempty"`
+	// History holds the recent ticket events, oldest first
+	History []ticketEvent `json:"history,omitempty"`
+	// EmailLog holds the ID of the newest counted email log entry
+	EmailLog int `json:"email_log,omitempty"`
+	// StatusDurations holds the time in status histograms
+	StatusDurations []statusDuration `json:"status_durations,omitempty"`
+	// CounterCreated holds the creation times of the counters served in
+	// the OpenMetrics format
+	CounterCreated map[string]float64 `json:"counter_created,omitempty"`
+}
+
+// stateMigrations upgrade a persisted state document from the version at
+// their index to the next one. Version 1 files hold a bare snapshot.
+var stateMigrations = map[int]func(json.RawMessage) (json.RawMessage, error){
+	1: func(doc json.RawMessage) (json.RawMessage, error) {
+		return json.Marshal(map[string]any{"version": 2, "snapshot": doc})
+	},
+	// version 3 adds the lifecycles, replies and counters
+	2: setStateVersion(3),
+	// version 4 adds the duplicates, history, email log, status durations
+	// and counter creation times, and the priority and status of lifecycles
+	3: setStateVersion(4),
+}
+
+// setStateVersion returns a migration to a version that only adds optional
+// fields, it sets the version of the document
+func setStateVersion(version int) func(json.RawMessage) (json.RawMessage, error) {
+	return func(doc json.RawMessage) (json.RawMessage, error) {
+		var state map[string]json.RawMessage
+		if err := json.Unmarshal(doc, &state); err != nil {
+			return nil, err
+		}
+		state["version"], _ = json.Marshal(version)
+		return json.Marshal(state)
+	}
+}
+
+// migrateState upgrades a persisted state document to stateVersion
+func migrateState(doc json.RawMessage) (json.RawMessage, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(doc, &header); err != nil {
+		return nil, err
+	}
+
+	version := header.Version
+	if version == 0 {
+		version = 1
+	}
+
+	if version > stateVersion {
+		return nil, fmt.Errorf("state version %d is newer than supported version %d", version, stateVersion)
+	}
+
+	for ; version < stateVersion; version++ {
+		migrate, ok := stateMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from state version %d", version)
+		}
+
+		var err error
+		if doc, err = migrate(doc); err != nil {
+			return nil, fmt.Errorf("migrate state version %d: %w", version, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// currentState returns the state to persist after a cycle that produced s
+func currentState(s *snapshot) *persistedState {
+	return &persistedState{
+		Version:    stateVersion,
+		Snapshot:   s,
+		Lifecycle:  ticketLifecycle.export(),
+		Replies:    operatorReplies.export(),
+		Duplicates: ticketDuplicates.export(),
+		Counters:   exportCounters(),
+		History:    ticketHistory.export(),
+		EmailLog:   emailLog.export(),
+
+		StatusDurations: statusDurations.export(),
+		CounterCreated:  counterCreated.export(),
+	}
+}
+
+// restoreState applies a loaded state to the collector, trackers and counters
+func restoreState(state *persistedState) {
+	supportPalTickets.store(state.Snapshot)
+	restoreSchema(state.Snapshot)
+	setSnapshotStale(true)
+	ticketLifecycle.restore(state.Lifecycle)
+	operatorReplies.restore(state.Replies)
+	ticketDuplicates.restore(state.Duplicates)
+	restoreCounters(state.Counters)
+	ticketHistory.restore(state.History, time.Now())
+	emailLog.restore(state.EmailLog)
+	statusDurations.restore(state.StatusDurations)
+	restored := make([]string, len(state.Counters))
+	for i, v := range state.Counters {
+		restored[i] = counterKey(v.Name, v.Labels)
+	}
+	counterCreated.restore(state.CounterCreated, restored)
+}
+
+// saveState writes state to path as zstd compressed JSON prefixed with a
+// SHA-256 checksum of the uncompressed data. The file is replaced atomically
+// and the previous file is kept as backup to recover from corruption.
+func saveState(path string, state *persistedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	sum := sha256.Sum256(data)
+
+	var buf bytes.Buffer
+	buf.Write(snapshotMagic)
+	buf.Write(sum[:])
+	buf.Write(enc.EncodeAll(data, nil))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, backupPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupPath returns the path the previous state file is kept at
+func backupPath(path string) string {
+	return path + ".bak"
+}
+
+// loadState reads the state written by saveState. If the file is missing or
+// corrupt the backup of the previous cycle is used.
+func loadState(path string) (*persistedState, error) {
+	state, err := readState(path)
+	if err == nil {
+		return state, nil
+	}
+
+	backup, backupErr := readState(backupPath(path))
+	if backupErr != nil {
+		return nil, err
+	}
+
+	slog.Warn("recovered state from backup", "path", backupPath(path), "err", err)
+	return backup, nil
+}
+
+// readState reads a single state file, verifies its checksum and migrates it
+// from older state versions
+func readState(path string) (*persistedState, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(snapshotMagic) + sha256.Size
+	if len(raw) < header || !bytes.Equal(raw[:len(snapshotMagic)], snapshotMagic) {
+		return nil, errors.New("not a snapshot file")
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	data, err := dec.DecodeAll(raw[header:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompress snapshot: %w", err)
+	}
+
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], raw[len(snapshotMagic):header]) {
+		return nil, errors.New("snapshot checksum mismatch")
+	}
+
+	data, err = migrateState(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	if state.Snapshot == nil {
+		return nil, errors.New("state holds no snapshot")
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMigrateState(t *testing.T) {
+	for _, name := range []string{"v1.json", "v2.json", "v3.json"} {
+		raw, err := os.ReadFile(filepath.Join("testdata", "state", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrated, err := migrateState(raw)
+		if err != nil {
+			t.Fatalf("%s: migrateState() = %v", name, err)
+		}
+
+		var state persistedState
+		if err := json.Unmarshal(migrated, &state); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if state.Version != stateVersion {
+			t.Errorf("%s: migrated to version %d, want %d", name, state.Version, stateVersion)
+		}
+		if state.Snapshot == nil || len(state.Snapshot.Series) != 1 {
+			t.Fatalf("%s: migrated snapshot = %+v, want one series", name, state.Snapshot)
+		}
+		if series := state.Snapshot.Series[0]; series.ID != 7 || !slices.Equal(series.LabelValues, []string{"Open", "High"}) {
+			t.Errorf("%s: migrated series = %+v", name, series)
+		}
+		if !slices.Equal(state.Snapshot.LabelNames, []string{"status", "priority"}) {
+			t.Errorf("%s: migrated label names = %v", name, state.Snapshot.LabelNames)
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "state", "v3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := migrateState(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state persistedState
+	if err := json.Unmarshal(migrated, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Lifecycle[7].Created != 1767322800 || state.Replies[70] != 1767326400 || len(state.Counters) != 1 || state.Counters[0].Value != 1 {
+		t.Errorf("migrated version 3 state lost its trackers: %+v", state)
+	}
+
+	if _, err := migrateState(json.RawMessage(`{"version":99,"snapshot":{}}`)); err == nil {
+		t.Error("migrateState() of a newer version succeeded")
+	}
+}
+
+func TestCountersRoundTrip(t *testing.T) {
+	labels := map[string]string{"department": "counters-round-trip", "priority": "Low"}
+	restoreCounters([]counterValue{
+		{Name: "supportpal_tickets_created_total", Labels: labels, Value: 3},
+		{Name: "supportpal_unknown_total", Labels: labels, Value: 1},
+		{Name: "supportpal_tickets_resolved_total", Labels: map[string]string{"unknown": "x"}, Value: 1},
+	})
+
+	var found bool
+	for _, v := range exportCounters() {
+		if v.Name == "supportpal_tickets_created_total" && maps.Equal(v.Labels, labels) {
+			found = true
+			if v.Value != 3 {
+				t.Errorf("exported value = %v, want 3", v.Value)
+			}
+		}
+	}
+	if !found {
+		t.Error("restored counter was not exported")
+	}
+}
+
+func TestStateBackupRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	for _, id := range []int{1, 2} {
+		s := newSnapshot([]string{"status"})
+		s.Series = []*ticketSeries{{ID: id, LabelValues: []string{"Open"}}}
+		if err := saveState(path, &persistedState{Version: stateVersion, Snapshot: s, Replies: map[int]int64{id: 100}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded := func() int {
+		t.Helper()
+		state, err := loadState(path)
+		if err != nil {
+			t.Fatalf("loadState() = %v", err)
+		}
+		if state.Version != stateVersion || state.Replies[state.Snapshot.Series[0].ID] != 100 {
+			t.Errorf("loaded state = %+v", state)
+		}
+		return state.Snapshot.Series[0].ID
+	}
+
+	if id := loaded(); id != 2 {
+		t.Errorf("loaded the state of cycle %d, want the latest, 2", id)
+	}
+
+	// a corrupt state file falls back to the backup of the previous cycle
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 0xff
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if id := loaded(); id != 1 {
+		t.Errorf("loaded the state of cycle %d from a corrupt file, want the backup, 1", id)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if id := loaded(); id != 1 {
+		t.Errorf("loaded the state of cycle %d without state file, want the backup, 1", id)
+	}
+
+	if err := os.WriteFile(backupPath(path), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path); err == nil {
+		t.Error("loadState() without a readable file succeeded")
+	}
+}
+
+func TestWarmRestart(t *testing.T) {
+	defer func(s *snapshot, labels []string, configured []Sink) {
+		supportPalTickets.store(s)
+		globaLabels, sinks = labels, configured
+		setSnapshotStale(false)
+		supportPalDataStale.Set(0)
+	}(supportPalTickets.current.Load(), globaLabels, sinks)
+	sinks = []Sink{registrySink{collector: supportPalTickets}}
+
+	path := filepath.Join(t.TempDir(), "state")
+	persisted := newSnapshot([]string{"status"})
+	persisted.Series = []*ticketSeries{{ID: 7, LabelValues: []string{"Open"}}}
+	if err := saveState(path, currentState(persisted)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the persisted snapshot is served marked stale until a cycle completes
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreState(state)
+	if s := supportPalTickets.current.Load(); len(s.Series) != 1 || s.Series[0].ID != 7 {
+		t.Fatalf("serving %+v, want the persisted snapshot", s.Series)
+	}
+	if testutil.ToFloat64(supportPalSnapshotInfo.WithLabelValues("true")) != 1 || testutil.ToFloat64(supportPalDataStale) != 1 {
+		t.Error("persisted snapshot not marked stale")
+	}
+
+	live := newSnapshot([]string{"status"})
+	live.Series = []*ticketSeries{{ID: 8, LabelValues: []string{"Closed"}}}
+	publish(live)
+	if supportPalTickets.current.Load() != live {
+		t.Error("first cycle did not replace the persisted snapshot")
+	}
+	if n := testutil.CollectAndCount(supportPalSnapshotInfo); n != 1 || testutil.ToFloat64(supportPalSnapshotInfo.WithLabelValues("false")) != 1 {
+		t.Errorf("%d snapshot info series after the first cycle, want stale=\"false\" only", n)
+	}
+}
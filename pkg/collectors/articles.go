@@ -0,0 +1,110 @@
+package collectors
+
+import (
+	"sync/atomic"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var articlesDesc = prometheus.NewDesc("supportpal_kb_articles",
+	"Number of published knowledge base articles in a category", []string{"category"}, nil)
+
+var articleViewsDesc = prometheus.NewDesc("supportpal_kb_article_views",
+	"Number of views of the published knowledge base articles in a category as reported by the API, it decreases when articles are unpublished", []string{"category"}, nil)
+
+var articleLastPublishedDesc = prometheus.NewDesc("supportpal_kb_last_published_timestamp_seconds",
+	"Last time an article of a category was published (unix timestamp in seconds)", []string{"category"}, nil)
+
+// categoryStats holds the article statistics of a knowledge base category
+type categoryStats struct {
+	Articles      int
+	Views         int
+	LastPublished int64
+}
+
+// Articles exposes the knowledge base statistics of the last update
+type Articles struct {
+	client  *supportpal.Client
+	current atomic.Pointer[map[string]*categoryStats]
+}
+
+// NewArticles returns a knowledge base collector listing the articles with client
+func NewArticles(client *supportpal.Client) *Articles {
+	return &Articles{client: client}
+}
+
+// Update lists the knowledge base articles and replaces the exported
+// statistics. On failure the previous statistics are kept.
+func (c *Articles) Update() error {
+	articles, err := c.client.ListArticles()
+	if err != nil {
+		return err
+	}
+
+	stats := categorizeArticles(articles)
+	c.current.Store(&stats)
+	return nil
+}
+
+// categorizeArticles aggregates the published articles by category. Articles
+// in several categories count in each, uncategorized ones in category "".
+func categorizeArticles(articles []*supportpal.Article) map[string]*categoryStats {
+	stats := make(map[string]*categoryStats)
+
+	for _, article := range articles {
+		if article.Published == 0 {
+			continue
+		}
+
+		published := article.PublishedAt
+		if published == 0 {
+			published = article.CreatedAt
+		}
+
+		categories := []string{""}
+		if len(article.Categories) > 0 {
+			categories = categories[:0]
+			for _, category := range article.Categories {
+				categories = append(categories, category.Name)
+			}
+		}
+
+		for _, category := range categories {
+			s, ok := stats[category]
+			if !ok {
+				s = &categoryStats{}
+				stats[category] = s
+			}
+
+			s.Articles++
+			s.Views += article.Views
+			s.LastPublished = max(s.LastPublished, published)
+		}
+	}
+
+	return stats
+}
+
+// Describe implements prometheus.Collector
+func (c *Articles) Describe(ch chan<- *prometheus.Desc) {
+	ch <- articlesDesc
+	ch <- articleViewsDesc
+	ch <- articleLastPublishedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Articles) Collect(ch chan<- prometheus.Metric) {
+	stats := c.current.Load()
+	if stats == nil {
+		return
+	}
+
+	for category, s := range *stats {
+		ch <- prometheus.MustNewConstMetric(articlesDesc, prometheus.GaugeValue, float64(s.Articles), category)
+		ch <- prometheus.MustNewConstMetric(articleViewsDesc, prometheus.GaugeValue, float64(s.Views), category)
+		if s.LastPublished != 0 {
+			ch <- prometheus.MustNewConstMetric(articleLastPublishedDesc, prometheus.GaugeValue, float64(s.LastPublished), category)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package collectors
+
+import (
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backend exposes the version and scheduler state of the SupportPal
+// installation read by the last update
+type Backend struct {
+	client *supportpal.Client
+
+	info        *prometheus.GaugeVec
+	cronLastRun prometheus.Gauge
+}
+
+// NewBackend returns a collector reading the core settings with client
+func NewBackend(client *supportpal.Client) *Backend {
+	return &Backend{
+		client: client,
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "supportpal_backend_info",
+			Help: "Version of the SupportPal installation, always 1",
+		}, []string{"version"}),
+		cronLastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "supportpal_backend_cron_last_run_timestamp_seconds",
+			Help: "Last time the SupportPal scheduled tasks ran, alert on time() minus this to catch a stalled scheduler (unix timestamp in seconds)",
+		}),
+	}
+}
+
+// Update reads the core settings. On failure the previous values are kept,
+// as are those of settings the installation does not expose.
+func (c *Backend) Update() error {
+	info, err := c.client.GetSystemInfo()
+	if err != nil {
+		return err
+	}
+
+	if info.Version != "" {
+		c.info.Reset()
+		c.info.WithLabelValues(info.Version).Set(1)
+	}
+
+	if info.CronLastRun != 0 {
+		c.cronLastRun.Set(float64(info.CronLastRun))
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *Backend) Describe(ch chan<- *prometheus.Desc) {
+	c.info.Describe(ch)
+	c.cronLastRun.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Backend) Collect(ch chan<- prometheus.Metric) {
+	c.info.Collect(ch)
+	c.cronLastRun.Collect(ch)
+}
@@ -0,0 +1,85 @@
+package collectors
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Catalog exposes every configured ticket status, priority and department,
+// so dashboard variables can list them while no ticket holds them
+type Catalog struct {
+	client *supportpal.Client
+
+	// Name returns the label value of a status, priority or department
+	// named name by the API, the object being "status", "priority" or
+	// "department". Nil keeps the names of the API.
+	Name func(object string, id int, name string) string
+
+	statuses    *prometheus.GaugeVec
+	priorities  *prometheus.GaugeVec
+	departments *prometheus.GaugeVec
+}
+
+// NewCatalog returns a collector listing the ticket options with client
+func NewCatalog(client *supportpal.Client) *Catalog {
+	return &Catalog{
+		client: client,
+		statuses: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "supportpal_status_info",
+			Help: "Configured ticket status, always 1",
+		}, []string{"status_id", "status"}),
+		priorities: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "supportpal_priority_info",
+			Help: "Configured ticket priority, always 1",
+		}, []string{"priority_id", "priority"}),
+		departments: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "supportpal_department_info",
+			Help: "Configured ticket department, always 1",
+		}, []string{"department_id", "department"}),
+	}
+}
+
+// Update lists the statuses, priorities and departments. On failure the
+// previous values of the failed list are kept.
+func (c *Catalog) Update() error {
+	return errors.Join(
+		c.update(c.statuses, "status", c.client.ListStatuses),
+		c.update(c.priorities, "priority", c.client.ListPriorities),
+		c.update(c.departments, "department", c.client.ListDepartments),
+	)
+}
+
+// update replaces the series of vec by the options returned by list
+func (c *Catalog) update(vec *prometheus.GaugeVec, object string, list func() ([]*supportpal.TicketOption, error)) error {
+	options, err := list()
+	if err != nil {
+		return err
+	}
+
+	vec.Reset()
+	for _, option := range options {
+		name := option.Name
+		if c.Name != nil {
+			name = c.Name(object, option.ID, option.Name)
+		}
+		vec.WithLabelValues(strconv.Itoa(option.ID), name).Set(1)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *Catalog) Describe(ch chan<- *prometheus.Desc) {
+	c.statuses.Describe(ch)
+	c.priorities.Describe(ch)
+	c.departments.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Catalog) Collect(ch chan<- prometheus.Metric) {
+	c.statuses.Collect(ch)
+	c.priorities.Collect(ch)
+	c.departments.Collect(ch)
+}
@@ -0,0 +1,11 @@
+// Package collectors implements the Prometheus collectors of the SupportPal
+// exporter that only depend on the API client, so other programs can
+// register them into their own registry:
+//
+//	api := supportpal.New(supportpal.Config{BaseURL: url, Token: token})
+//	articles := collectors.NewArticles(api)
+//	registry.MustRegister(articles)
+//
+// The collectors export the values of their last update, the caller decides
+// when to update them, e.g. once per collection cycle.
+package collectors
@@ -0,0 +1,93 @@
+package collectors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestArticlesRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/selfservice/article" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":3,"data":[`+
+			`{"id":1,"views":10,"published":1,"published_at":100,"categories":[{"id":1,"name":"Billing"}]},`+
+			`{"id":2,"views":5,"published":1,"created_at":200,"categories":[{"id":1,"name":"Billing"}]},`+
+			`{"id":3,"views":7,"published":0}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	articles := NewArticles(supportpal.New(supportpal.Config{BaseURL: srv.URL, ListTimeout: time.Second}))
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(articles)
+
+	if err := articles.Update(); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+
+	expected := `
+# HELP supportpal_kb_article_views Number of views of the published knowledge base articles in a category as reported by the API, it decreases when articles are unpublished
+# TYPE supportpal_kb_article_views gauge
+supportpal_kb_article_views{category="Billing"} 15
+# HELP supportpal_kb_articles Number of published knowledge base articles in a category
+# TYPE supportpal_kb_articles gauge
+supportpal_kb_articles{category="Billing"} 2
+# HELP supportpal_kb_last_published_timestamp_seconds Last time an article of a category was published (unix timestamp in seconds)
+# TYPE supportpal_kb_last_published_timestamp_seconds gauge
+supportpal_kb_last_published_timestamp_seconds{category="Billing"} 200
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCatalogRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ticket/status":
+			fmt.Fprint(w, `{"status":"success","count":2,"data":[{"id":1,"name":"Open"},{"id":2,"name":"Closed"}]}`)
+		case "/api/ticket/priority":
+			fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":3,"name":"High"}]}`)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	catalog := NewCatalog(supportpal.New(supportpal.Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second}))
+	catalog.Name = func(object string, id int, name string) string {
+		if object == "status" {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(catalog)
+
+	// the departments fail, the statuses and priorities are still exported
+	if err := catalog.Update(); err == nil {
+		t.Fatal("Update() = nil, want the error of the department list")
+	}
+
+	expected := `
+# HELP supportpal_priority_info Configured ticket priority, always 1
+# TYPE supportpal_priority_info gauge
+supportpal_priority_info{priority="High",priority_id="3"} 1
+# HELP supportpal_status_info Configured ticket status, always 1
+# TYPE supportpal_status_info gauge
+supportpal_status_info{status="closed",status_id="2"} 1
+supportpal_status_info{status="open",status_id="1"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,90 @@
+package collectors
+
+import (
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// operatorOnlineWindow is how recently an operator must have been active to count as online
+const operatorOnlineWindow = 15 * time.Minute
+
+// Inventory exposes the user and operator headcounts of the last update
+type Inventory struct {
+	client *supportpal.Client
+
+	users           prometheus.Gauge
+	operators       prometheus.Gauge
+	operatorsOnline prometheus.Gauge
+}
+
+// NewInventory returns a headcount collector counting with client
+func NewInventory(client *supportpal.Client) *Inventory {
+	return &Inventory{
+		client: client,
+		users: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "supportpal_users",
+			Help: "Number of registered users",
+		}),
+		operators: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "supportpal_operators",
+			Help: "Number of enabled operator accounts",
+		}),
+		operatorsOnline: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "supportpal_operators_online",
+			Help: "Number of operators active within the last 15 minutes",
+		}),
+	}
+}
+
+// UpdateUsers counts the registered users. On failure the previous count is
+// kept.
+func (c *Inventory) UpdateUsers() error {
+	users, err := c.client.CountUsers()
+	if err != nil {
+		return err
+	}
+
+	c.users.Set(float64(users))
+	return nil
+}
+
+// UpdateOperators counts the enabled operators and those active within 15
+// minutes before now. On failure the previous counts are kept.
+func (c *Inventory) UpdateOperators(now time.Time) error {
+	operators, err := c.client.ListOperators()
+	if err != nil {
+		return err
+	}
+
+	var enabled, online int
+	for _, operator := range operators {
+		if operator.Active == 0 {
+			continue
+		}
+
+		enabled++
+		if operator.LastActiveAt != 0 && now.Sub(time.Unix(operator.LastActiveAt, 0)) <= operatorOnlineWindow {
+			online++
+		}
+	}
+
+	c.operators.Set(float64(enabled))
+	c.operatorsOnline.Set(float64(online))
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *Inventory) Describe(ch chan<- *prometheus.Desc) {
+	c.users.Describe(ch)
+	c.operators.Describe(ch)
+	c.operatorsOnline.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Inventory) Collect(ch chan<- prometheus.Metric) {
+	c.users.Collect(ch)
+	c.operators.Collect(ch)
+	c.operatorsOnline.Collect(ch)
+}
@@ -0,0 +1,175 @@
+package supportpal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// apiVersion describes how a major version of the SupportPal API differs
+// from v1, the version the requests of the client are written against
+type apiVersion struct {
+	name string
+	// paths maps v1 path prefixes to the prefixes of this version
+	paths [][2]string
+}
+
+// apiVersions are the supported major API versions. v1 is served by
+// SupportPal up to 4.x. v2 renamed the organisation endpoints and reports
+// the total of list responses in meta.total instead of count. The v2
+// differences are not taken from published API documentation, they are
+// assumptions based on the responses of upgraded installations. With
+// APIVersionAuto a wrong assumption costs a retry: a request the API answers
+// with 404 Not Found is sent again with the paths of the other versions.
+var apiVersions = []*apiVersion{
+	{name: "v1"},
+	{name: "v2", paths: [][2]string{{"/api/user/organisation", "/api/user/organization"}}},
+}
+
+// APIVersionAuto detects the API version from the first list response and
+// again when a request fails with 404 Not Found
+const APIVersionAuto = "auto"
+
+// APIVersions returns the names of the supported API versions
+func APIVersions() []string {
+	names := []string{APIVersionAuto}
+	for _, v := range apiVersions {
+		names = append(names, v.name)
+	}
+	return names
+}
+
+// lookupAPIVersion returns the API version with the given name, nil if it is
+// not supported
+func lookupAPIVersion(name string) *apiVersion {
+	i := slices.IndexFunc(apiVersions, func(v *apiVersion) bool { return v.name == name })
+	if i < 0 {
+		return nil
+	}
+	return apiVersions[i]
+}
+
+// path rewrites a v1 request path to this version
+func (v *apiVersion) path(path string) string {
+	for _, p := range v.paths {
+		if rest, ok := strings.CutPrefix(path, p[0]); ok {
+			return p[1] + rest
+		}
+	}
+	return path
+}
+
+// listMeta is the pagination object of v2 list responses
+type listMeta struct {
+	Total int `json:"total"`
+}
+
+// normalizeEnvelope returns a response body with the total of a v2 list
+// response copied to count, so list responses of every version decode into
+// the same structs. Other bodies are returned unchanged.
+func normalizeEnvelope(body []byte) []byte {
+	if !bytes.Contains(body, []byte(`"meta"`)) {
+		return body
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return body
+	}
+	if _, ok := envelope["count"]; ok {
+		return body
+	}
+
+	var meta listMeta
+	if err := json.Unmarshal(envelope["meta"], &meta); err != nil {
+		return body
+	}
+
+	envelope["count"], _ = json.Marshal(meta.Total)
+	normalized, err := json.Marshal(envelope)
+	if err != nil {
+		return body
+	}
+
+	return normalized
+}
+
+// detectAPIVersion returns the API version of a list response body and
+// whether the body is a list response at all
+func detectAPIVersion(body []byte) (*apiVersion, bool) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, false
+	}
+
+	if _, ok := envelope["meta"]; ok {
+		return lookupAPIVersion("v2"), true
+	}
+	if _, ok := envelope["count"]; ok {
+		return lookupAPIVersion("v1"), true
+	}
+	return nil, false
+}
+
+// detectVersion sets the API version from the first list response of a
+// client detecting it automatically
+func (c *Client) detectVersion(body []byte) {
+	if !c.autoVersion || c.versionDetected.Load() {
+		return
+	}
+
+	if v, ok := detectAPIVersion(body); ok {
+		c.setVersion(v)
+	}
+}
+
+// setVersion sends the following requests for API version v
+func (c *Client) setVersion(v *apiVersion) {
+	c.versionDetected.Store(true)
+	if previous := c.version.Swap(v); previous != v {
+		c.config.Logger.Info("detected SupportPal API version", "version", v.name, "previous", previous.name)
+	}
+}
+
+// redetectInterval is the least time between two attempts to detect the API
+// version again, most not found responses are for deleted objects
+const redetectInterval = time.Minute
+
+// redetectVersion sends a request that failed with 404 Not Found again with
+// the paths of the other API versions, when the client detects the version
+// automatically and their paths differ, e.g. because SupportPal was upgraded
+// since the version was detected. The version the request succeeds with is
+// used from then on, otherwise err is returned.
+func (c *Client) redetectVersion(tried *apiVersion, method, url string, body []byte, timeout time.Duration, read func(io.Reader, http.Header) error, err error) error {
+	if !c.autoVersion || !slices.ContainsFunc(apiVersions, func(v *apiVersion) bool { return v.path(url) != tried.path(url) }) {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	last := c.versionRedetected.Load()
+	if now-last < int64(redetectInterval) || !c.versionRedetected.CompareAndSwap(last, now) {
+		return err
+	}
+
+	for _, v := range apiVersions {
+		if v == tried || v.path(url) == tried.path(url) {
+			continue
+		}
+
+		if retryErr := c.send(v, method, url, body, timeout, read); retryErr == nil {
+			c.setVersion(v)
+			return nil
+		}
+	}
+
+	return err
+}
+
+// APIVersion returns the name of the API version requests are sent for
+func (c *Client) APIVersion() string {
+	return c.version.Load().name
+}
@@ -0,0 +1,63 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Article represents a self-service knowledge base article
+type Article struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Views       int    `json:"views"`
+	Published   int    `json:"published"`
+	PublishedAt int64  `json:"published_at"`
+	CreatedAt   int64  `json:"created_at"`
+	Categories  []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"categories"`
+}
+
+// ListArticlesResponse represents the response body for listing articles
+type ListArticlesResponse struct {
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	Count   int        `json:"count"`
+	Data    []*Article `json:"data"`
+}
+
+// ListArticles is a helper function to list all knowledge base articles
+func (c *Client) ListArticles() ([]*Article, error) {
+	var articles []*Article
+	start := 0
+	limit := 100
+	for {
+		url := "/api/selfservice/article?start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+		resp, err := c.request("GET", url, nil, c.config.ListTimeout)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page ListArticlesResponse
+		err = json.Unmarshal(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, article := range page.Data {
+			if article != nil {
+				articles = append(articles, article)
+			}
+		}
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return articles, nil
+}
@@ -0,0 +1,113 @@
+package supportpal
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	supportPalAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "supportpal_api_request_duration_seconds",
+		Help:    "Time from sending an API request until its response body was read, by endpoint and method",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"endpoint", "method"})
+
+	supportPalAPIRequestFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_api_request_failures_total",
+		Help: "Number of API requests the API failed, without response, with a 5xx status or a body that could not be read, by endpoint and method",
+	}, []string{"endpoint", "method"})
+
+	supportPalAPIAvailability = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "supportpal_api_availability_ratio",
+		Help: "Share of the API requests of an endpoint within the availability window that did not fail",
+	}, []string{"endpoint"})
+)
+
+// apiFailed reports whether a request failed because of the API rather than
+// the request. 4xx responses such as a missing ticket do not count.
+func apiFailed(status int, err error) bool {
+	switch {
+	case status == 0:
+		return err != nil
+	case status >= http.StatusInternalServerError:
+		return true
+	case status >= http.StatusBadRequest:
+		return false
+	default:
+		return err != nil
+	}
+}
+
+// availabilitySlot counts the requests of an endpoint within one minute
+type availabilitySlot struct {
+	minute int64
+	total  int
+	failed int
+}
+
+// availabilityTracker keeps the requests of the trailing availability window
+// by endpoint in minute slots
+type availabilityTracker struct {
+	mu    sync.Mutex
+	slots map[string][]availabilitySlot
+}
+
+var apiAvailability = &availabilityTracker{slots: make(map[string][]availabilitySlot)}
+
+// record counts a request of endpoint and sets the availability ratio of
+// every endpoint over window. Endpoints without requests within the window
+// are dropped.
+func (t *availabilityTracker) record(endpoint string, failed bool, window time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	minute := now.Unix() / 60
+	slots := t.slots[endpoint]
+	if n := len(slots); n == 0 || slots[n-1].minute != minute {
+		slots = append(slots, availabilitySlot{minute: minute})
+	}
+	slots[len(slots)-1].total++
+	if failed {
+		slots[len(slots)-1].failed++
+	}
+	t.slots[endpoint] = slots
+
+	oldest := now.Add(-window).Unix() / 60
+	for endpoint, slots := range t.slots {
+		for len(slots) > 0 && slots[0].minute < oldest {
+			slots = slots[1:]
+		}
+		if len(slots) == 0 {
+			delete(t.slots, endpoint)
+			supportPalAPIAvailability.DeleteLabelValues(endpoint)
+			continue
+		}
+		t.slots[endpoint] = slots
+
+		total, failed := 0, 0
+		for _, slot := range slots {
+			total += slot.total
+			failed += slot.failed
+		}
+		supportPalAPIAvailability.WithLabelValues(endpoint).Set(float64(total-failed) / float64(total))
+	}
+}
+
+// observeRequest records the duration and outcome of a completed request
+func (c *Client) observeRequest(method, path string, start time.Time, status int, err error) {
+	endpoint := endpointOf(path)
+	failed := apiFailed(status, err)
+
+	supportPalAPIRequestDuration.WithLabelValues(endpoint, method).Observe(time.Since(start).Seconds())
+	// the failures start at 0, so error ratios are defined before the first one
+	failures := supportPalAPIRequestFailures.WithLabelValues(endpoint, method)
+	if failed {
+		failures.Inc()
+	}
+	if c.config.AvailabilityWindow > 0 {
+		apiAvailability.record(endpoint, failed, c.config.AvailabilityWindow, time.Now())
+	}
+}
@@ -0,0 +1,68 @@
+package supportpal
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NormalizeBaseURL validates the base URL of a SupportPal installation and
+// returns it without trailing slashes. SupportPal may be served below a path
+// prefix, e.g. https://intranet.example.com/helpdesk, and on any port. A
+// trailing /api segment is removed, since the client adds it to every
+// request. A URL without scheme is taken as HTTPS, the API token is never
+// sent over HTTP unless the URL asks for it.
+func NormalizeBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("base URL is empty")
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q, expected http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("base URL has no host")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("base URL must not have a query or fragment")
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.Path = strings.TrimSuffix(u.Path, "/api")
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+// Check verifies that the API is reachable by listing a single ticket. An
+// automatic API version is detected from the response.
+func (c *Client) Check() error {
+	_, err := c.request("GET", "/api/ticket/ticket?start=0&limit=1", nil, c.config.EnrichmentTimeout)
+
+	switch {
+	case isNotFound(err):
+		return fmt.Errorf("%w, check the path prefix of the base URL", err)
+	case isAuthError(err):
+		return fmt.Errorf("%w, check the API token", err)
+	case err != nil && strings.HasPrefix(c.config.BaseURL, "https://") && !errors.As(err, new(*StatusError)):
+		return fmt.Errorf("%w, an installation served over plain HTTP needs a base URL starting with http://", err)
+	}
+
+	return err
+}
+
+// BaseURL returns the base URL requests are sent to
+func (c *Client) BaseURL() string {
+	return c.config.BaseURL
+}
@@ -0,0 +1,191 @@
+package supportpal
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	supportPalCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_cache_hits_total",
+		Help: "Number of lookups answered from the cache",
+	}, []string{"cache"})
+
+	supportPalCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_cache_misses_total",
+		Help: "Number of lookups not found in the cache or expired",
+	}, []string{"cache"})
+
+	supportPalSharedCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_shared_cache_requests_total",
+		Help: "Number of requests to the shared cache after local cache misses and for stored values, by result: hit, miss, set or error",
+	}, []string{"cache", "result"})
+)
+
+// SharedStore is a cache shared by several exporter instances, such as
+// RedisStore, so replicas look up the same objects only once. Values expire
+// after ttl, 0 keeps them.
+type SharedStore interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// cacheEntry is a value stored in a Cache
+type cacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Cache is a least recently used cache whose entries expire after ttl.
+// A maxEntries of 0 means the cache is unbounded. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	name       string
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]*list.Element
+	order      *list.List
+	// shared is asked on local misses and receives the stored values, nil
+	// for a local cache only
+	shared SharedStore
+}
+
+// NewCache returns an empty cache reporting hits and misses under name
+func NewCache[K comparable, V any](name string, ttl time.Duration, maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		name:       name,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Share makes the cache look up local misses in store and write the values
+// it stores through to it. Values are JSON encoded. It must be called before
+// the cache is used.
+func (c *Cache[K, V]) Share(store SharedStore) {
+	c.shared = store
+}
+
+// Get returns the value stored for key if it has not expired, from the
+// shared store if it is only stored there
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if value, ok := c.getLocal(key); ok {
+		return value, true
+	}
+
+	var zero V
+	if c.shared == nil {
+		return zero, false
+	}
+
+	data, ok, err := c.shared.Get(c.sharedKey(key))
+	if err != nil {
+		supportPalSharedCacheRequests.WithLabelValues(c.name, "error").Inc()
+		return zero, false
+	}
+	if !ok {
+		supportPalSharedCacheRequests.WithLabelValues(c.name, "miss").Inc()
+		return zero, false
+	}
+
+	var value V
+	if err := json.Unmarshal(data, &value); err != nil {
+		supportPalSharedCacheRequests.WithLabelValues(c.name, "error").Inc()
+		return zero, false
+	}
+	supportPalSharedCacheRequests.WithLabelValues(c.name, "hit").Inc()
+	c.setLocal(key, value)
+
+	return value, true
+}
+
+// getLocal returns the value stored locally for key if it has not expired
+func (c *Cache[K, V]) getLocal(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry[K, V])
+		if c.ttl <= 0 || time.Now().Before(entry.expiresAt) {
+			c.order.MoveToFront(el)
+			supportPalCacheHits.WithLabelValues(c.name).Inc()
+			return entry.value, true
+		}
+
+		c.remove(el)
+	}
+
+	supportPalCacheMisses.WithLabelValues(c.name).Inc()
+
+	var zero V
+	return zero, false
+}
+
+// sharedKey returns the key of key in the shared store
+func (c *Cache[K, V]) sharedKey(key K) string {
+	return c.name + ":" + fmt.Sprint(key)
+}
+
+// contains reports whether an unexpired value is stored for key, without
+// counting a hit or miss or marking the entry as used
+func (c *Cache[K, V]) contains(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	return ok && (c.ttl <= 0 || time.Now().Before(el.Value.(*cacheEntry[K, V]).expiresAt))
+}
+
+// Set stores value for key, evicting the least recently used entry when the
+// cache is full, and in the shared store
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.setLocal(key, value)
+
+	if c.shared == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = c.shared.Set(c.sharedKey(key), data, c.ttl)
+	}
+	if err != nil {
+		supportPalSharedCacheRequests.WithLabelValues(c.name, "error").Inc()
+		return
+	}
+	supportPalSharedCacheRequests.WithLabelValues(c.name, "set").Inc()
+}
+
+// setLocal stores value for key in the local cache only
+func (c *Cache[K, V]) setLocal(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops el from the cache, the caller must hold c.mu
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry[K, V]).key)
+}
@@ -0,0 +1,67 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// TicketOption is a status, priority or department tickets can take
+type TicketOption struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListTicketOptionsResponse represents the response body for listing
+// statuses, priorities or departments
+type ListTicketOptionsResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Count   int             `json:"count"`
+	Data    []*TicketOption `json:"data"`
+}
+
+// ListStatuses lists all configured ticket statuses
+func (c *Client) ListStatuses() ([]*TicketOption, error) {
+	return c.listTicketOptions("/api/ticket/status")
+}
+
+// ListPriorities lists all configured ticket priorities
+func (c *Client) ListPriorities() ([]*TicketOption, error) {
+	return c.listTicketOptions("/api/ticket/priority")
+}
+
+// ListDepartments lists all configured ticket departments
+func (c *Client) ListDepartments() ([]*TicketOption, error) {
+	return c.listTicketOptions("/api/ticket/department")
+}
+
+// listTicketOptions lists all items of the list at path
+func (c *Client) listTicketOptions(path string) ([]*TicketOption, error) {
+	var options []*TicketOption
+	start := 0
+	limit := 100
+	for {
+		url := path + "?start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+		resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page ListTicketOptionsResponse
+		err = json.Unmarshal(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, page.Data...)
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return options, nil
+}
@@ -0,0 +1,308 @@
+// Package supportpal implements a SupportPal API client with cached lookups of
+// organizations and custom fields. A Client is safe for concurrent use.
+package supportpal
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the settings of a Client
+type Config struct {
+	BaseURL string
+	Token   string
+	// TokenFile is read for the token instead of Token and read again when
+	// the API rejects the token, so a rotated token is picked up
+	TokenFile         string
+	ListTimeout       time.Duration
+	EnrichmentTimeout time.Duration
+	CacheTTL          time.Duration
+	CacheMaxEntries   int
+	// SharedCache shares the organizations and custom fields with other
+	// clients for CacheTTL, nil caches them per client
+	SharedCache SharedStore
+	// MessageCacheSize is the number of tickets whose messages and audit
+	// logs are cached until the ticket is updated, 0 disables the caches
+	MessageCacheSize int
+	// DisableCompression requests uncompressed responses instead of gzip
+	DisableCompression bool
+	// ConditionalCacheSize is the number of responses with an ETag or
+	// Last-Modified header kept to send conditional requests for them, 0
+	// disables conditional requests
+	ConditionalCacheSize int
+	// EnrichmentConcurrency is the number of organization and custom field
+	// lookups sent at a time for the tickets of a page before they are
+	// passed on, 1 or less looks them up one at a time when they are used
+	EnrichmentConcurrency int
+	// PageSize is the initial number of tickets requested per page
+	PageSize int
+	// OrderColumn is the column ticket lists are sorted by, newest first:
+	// id, created_at or updated_at. Empty uses the order of the API, by ID.
+	OrderColumn string
+	// MaxTickets stops listing tickets after this many, 0 lists all
+	MaxTickets int
+	// PageRetries is the number of times a ticket page failing at the
+	// smallest page size is retried before it is skipped
+	PageRetries int
+	// TicketFilter holds query parameters added to ticket list requests
+	TicketFilter url.Values
+	// Shard limits the listed tickets to those of the shard. The API cannot
+	// filter by it, so all pages are still fetched by every shard, N shards
+	// list N times the pages of one instance, but the tickets of other
+	// shards are dropped before their lookups.
+	Shard Shard
+	// Strict reports fields of API objects that are unknown or missing in
+	// the structs they are decoded into, to detect API changes early
+	Strict bool
+	// SelectFields restricts ticket responses to the fields the exporter
+	// uses. It is disabled once the API rejects the selection parameters.
+	SelectFields bool
+	// AvailabilityWindow is the trailing window the availability ratio of
+	// every endpoint is computed over, 0 disables the ratio
+	AvailabilityWindow time.Duration
+	// ErrorLogSize is the number of recent API errors kept for RecentErrors
+	ErrorLogSize int
+	// TLSConfig overrides the TLS settings of API requests, nil uses the system defaults
+	TLSConfig *tls.Config
+	// ProxyURL is the proxy all API requests are sent through, credentials
+	// are taken from its user info. Nil uses the proxy environment variables.
+	ProxyURL *url.URL
+	// APIVersion is the major API version of the installation, one of
+	// APIVersions. Empty or APIVersionAuto detects it from the responses.
+	APIVersion string
+	// Tracer receives the spans of API requests and ticket pages, nil
+	// disables tracing
+	Tracer Tracer
+	Logger *slog.Logger
+}
+
+// minPageSize is the smallest page size the client shrinks pages to
+const minPageSize = 10
+
+// StatusError is returned when the API responds with an error status code
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Status string
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %s", e.Method, e.URL, e.Status)
+}
+
+// Client talks to the SupportPal API
+type Client struct {
+	config        Config
+	httpClient    *http.Client
+	organizations *Cache[int, *Organization]
+	customFields  *Cache[int, *GetCustomFieldResponse]
+	messages      *Cache[messageKey, []*Message]
+	logs          *Cache[messageKey, []*TicketLog]
+	pageSize      atomic.Int64
+	// fieldsRejected is set once the API rejected the field selection
+	fieldsRejected atomic.Bool
+	token          atomic.Pointer[string]
+	errors         *errorLog
+	// drift is nil unless the strict decode mode is enabled
+	drift *driftDetector
+	// version is the API version requests are sent for
+	version atomic.Pointer[apiVersion]
+	// autoVersion is set when the version is detected from the responses,
+	// versionDetected once a response was seen
+	autoVersion     bool
+	versionDetected atomic.Bool
+	// versionRedetected is the Unix time in nanoseconds of the last attempt
+	// to detect the version again after a not found response
+	versionRedetected atomic.Int64
+	// conditional holds the responses replayed for 304 Not Modified, nil
+	// unless conditional requests are enabled
+	conditional *Cache[string, *conditionalEntry]
+	// deadline is the Unix time in nanoseconds requests are aborted at
+	// regardless of their timeout, 0 for none
+	deadline atomic.Int64
+	// pacer spaces the requests of a cycle started with Pace
+	pacer pacer
+	// ticketFilter holds the query parameters of ticket list requests,
+	// Config.TicketFilter until SetTicketFilter replaces them
+	ticketFilter atomic.Pointer[url.Values]
+}
+
+// New returns a Client for the given configuration
+func New(config Config) *Client {
+	if base, err := NormalizeBaseURL(config.BaseURL); err == nil {
+		config.BaseURL = base
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	if config.PageSize <= 0 {
+		config.PageSize = 100
+	}
+
+	// the default transport honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// responses are decompressed in send to count the transferred bytes
+	transport.DisableCompression = true
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	}
+	if config.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(config.ProxyURL)
+	}
+
+	c := &Client{
+		config:        config,
+		httpClient:    &http.Client{Transport: transport},
+		organizations: NewCache[int, *Organization]("organization", config.CacheTTL, config.CacheMaxEntries),
+		customFields:  NewCache[int, *GetCustomFieldResponse]("customfield", config.CacheTTL, config.CacheMaxEntries),
+		errors:        &errorLog{size: config.ErrorLogSize},
+	}
+	version := lookupAPIVersion(config.APIVersion)
+	if version == nil {
+		version = apiVersions[0]
+		c.autoVersion = true
+	}
+	c.version.Store(version)
+	c.ticketFilter.Store(&config.TicketFilter)
+	if config.SharedCache != nil {
+		c.organizations.Share(config.SharedCache)
+		c.customFields.Share(config.SharedCache)
+	}
+	if config.MessageCacheSize > 0 {
+		c.messages = NewCache[messageKey, []*Message]("messages", 0, config.MessageCacheSize)
+		c.logs = NewCache[messageKey, []*TicketLog]("ticketlogs", 0, config.MessageCacheSize)
+	}
+	if config.ConditionalCacheSize > 0 {
+		c.conditional = NewCache[string, *conditionalEntry]("conditional", 0, config.ConditionalCacheSize)
+	}
+	if config.Strict {
+		c.drift = &driftDetector{logger: config.Logger}
+	}
+	supportPalAPIPageSize.WithLabelValues("configured").Set(float64(config.PageSize))
+	supportPalAPIMaxConcurrency.Set(float64(max(config.EnrichmentConcurrency, 1)))
+	c.setPageSize(config.PageSize)
+	c.token.Store(&config.Token)
+
+	return c
+}
+
+// PageSize returns the number of tickets currently requested per page
+func (c *Client) PageSize() int {
+	return int(c.pageSize.Load())
+}
+
+// request is a helper function to make an API request that accepts method, url, and body.
+// The request is aborted after timeout. A rejected token is read again from
+// the token file and the request retried once if it changed.
+func (c *Client) request(method, url string, body []byte, timeout time.Duration) ([]byte, error) {
+	var resp []byte
+	err := c.stream(method, url, body, timeout, func(r io.Reader, _ http.Header) (err error) {
+		resp, err = io.ReadAll(r)
+		return err
+	})
+	if err == nil {
+		c.detectVersion(resp)
+	}
+
+	return normalizeEnvelope(resp), err
+}
+
+// stream makes an API request like request but passes the response body and
+// header to read instead of buffering it, read must consume it before timeout
+func (c *Client) stream(method, url string, body []byte, timeout time.Duration, read func(io.Reader, http.Header) error) error {
+	version := c.version.Load()
+	err := c.send(version, method, url, body, timeout, read)
+	if isNotFound(err) {
+		err = c.redetectVersion(version, method, url, body, timeout, read, err)
+	}
+	if !isAuthError(err) {
+		return err
+	}
+
+	changed, reloadErr := c.ReloadToken()
+	if reloadErr != nil {
+		c.config.Logger.Error("failed to reload API token", "err", reloadErr)
+	}
+	if !changed {
+		return err
+	}
+
+	c.config.Logger.Info("API token changed, retrying request", "method", method, "path", url)
+	return c.send(c.version.Load(), method, url, body, timeout, read)
+}
+
+// send makes a single API request with the paths of version and passes the body and header of a successful response to read.
+// GET requests are sent conditionally when an earlier response for the same
+// path carried validators, and the earlier body and header are passed for 304.
+func (c *Client) send(version *apiVersion, method, url string, body []byte, timeout time.Duration, read func(io.Reader, http.Header) error) (err error) {
+	path := version.path(url)
+	url = c.config.BaseURL + path
+	c.pacer.wait()
+	ctx, cancel := c.requestContext(timeout)
+	defer cancel()
+
+	start := time.Now()
+	status := 0
+	defer func() {
+		c.observeRequest(method, path, start, status, err)
+		c.traceRequest(method, path, start, status, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(*c.token.Load(), "X")
+	if !c.config.DisableCompression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	entry := c.conditionalRequest(req, path)
+
+	supportPalAPIRequestsInFlight.Inc()
+	defer supportPalAPIRequestsInFlight.Dec()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = deadlineError(ctx, err)
+		countRequest(0)
+		c.config.Logger.Debug("API request failed", "method", method, "path", path, "duration", time.Since(start), "err", err)
+		c.errors.add(APIError{Time: start, Method: method, Path: path, Error: err.Error()})
+		return err
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+	countRequest(resp.StatusCode)
+	c.reportDeprecation(path, resp.Header)
+	if err := decodeBody(resp); err != nil {
+		c.errors.add(APIError{Time: start, Method: method, Path: path, Code: resp.StatusCode, Error: err.Error()})
+		return err
+	}
+
+	c.config.Logger.Debug("API request", "method", method, "path", path, "status", resp.StatusCode, "duration", time.Since(start))
+
+	if resp.StatusCode >= 400 {
+		err := &StatusError{Method: method, URL: url, Code: resp.StatusCode, Status: resp.Status}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		c.errors.add(APIError{Time: start, Method: method, Path: path, Code: resp.StatusCode, Error: err.Error(), Body: string(body)})
+		return err
+	}
+
+	respBody, header, store := c.conditionalBody(resp, path, entry)
+	if err := read(respBody, header); err != nil {
+		return deadlineError(ctx, err)
+	}
+	store()
+
+	return nil
+}
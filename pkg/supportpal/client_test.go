@@ -0,0 +1,1112 @@
+package supportpal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/ticket/ticket":
+			fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Hello"}]}`)
+		case strings.HasPrefix(r.URL.Path, "/api/user/organisation/"):
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+		case strings.HasPrefix(r.URL.Path, "/api/ticket/customfield/"):
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"Area","type":7}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClientConcurrentLookups(t *testing.T) {
+	srv := newTestServer(t)
+	c := New(Config{
+		BaseURL:           srv.URL + "/",
+		ListTimeout:       time.Second,
+		EnrichmentTimeout: time.Second,
+		CacheTTL:          time.Minute,
+		CacheMaxEntries:   4,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			if _, err := c.FetchAllTickets(); err != nil {
+				t.Error(err)
+			}
+			if org, err := c.GetOrganization(id % 8); err != nil || org.Data.Name != "ACME" {
+				t.Errorf("GetOrganization(%d) = %v, %v", id%8, org, err)
+			}
+			if field, err := c.GetCustomField(id % 8); err != nil || field.Data.Name != "Area" {
+				t.Errorf("GetCustomField(%d) = %v, %v", id%8, field, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClientPrefetch(t *testing.T) {
+	var lookups, inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/ticket/ticket" {
+			fmt.Fprint(w, `{"status":"success","count":3,"data":[`+
+				`{"id":1,"user":{"organisation_id":1},"customfields":[{"field_id":1},{"field_id":2}]},`+
+				`{"id":2,"user":{"organisation_id":2},"customfields":[{"field_id":1}]},`+
+				`{"id":3,"user":{"organisation_id":1}}]}`)
+			return
+		}
+
+		lookups.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if strings.HasPrefix(r.URL.Path, "/api/user/organisation/") {
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"Area","type":7}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, EnrichmentTimeout: time.Second, EnrichmentConcurrency: 4})
+	err := c.StreamTickets(nil, func(ticket *Ticket) {
+		if n := lookups.Load(); n != 4 {
+			t.Errorf("%d lookups before ticket %d was passed, want the 2 organizations and 2 fields", n, ticket.ID)
+		}
+		if _, err := c.GetOrganization(ticket.User.OrganizationID); err != nil {
+			t.Error(err)
+		}
+		for _, field := range ticket.CustomFields {
+			if _, err := c.GetCustomField(field.FieldID); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := lookups.Load(); n != 4 {
+		t.Errorf("%d lookups, want every organization and field looked up once", n)
+	}
+	if n := maxInFlight.Load(); n < 2 {
+		t.Errorf("at most %d lookups in flight, want them sent concurrently", n)
+	}
+}
+
+func TestClientNotFoundTombstone(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/user/organisation/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status":"error","message":"Not found"}`)
+		default:
+			fmt.Fprint(w, `{"status":"success","data":null}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL + "/", EnrichmentTimeout: time.Second, CacheTTL: time.Minute, APIVersion: "v1"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetOrganization(1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetOrganization(1) error = %v, want ErrNotFound", err)
+		}
+		if _, err := c.GetCustomField(1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetCustomField(1) error = %v, want ErrNotFound", err)
+		}
+	}
+
+	if n := requests.Load(); n != 2 {
+		t.Errorf("made %d requests, want 2 with tombstones cached", n)
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		proxied.Add(1)
+		fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+	}))
+	t.Cleanup(proxy.Close)
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	c := New(Config{BaseURL: "http://supportpal.invalid/", EnrichmentTimeout: time.Second, ProxyURL: proxyURL})
+
+	if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "ACME" {
+		t.Fatalf("GetOrganization(1) = %v, %v", org, err)
+	}
+	if n := proxied.Load(); n != 1 {
+		t.Errorf("proxied %d requests, want 1", n)
+	}
+}
+
+func TestClientTokenReload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, _, _ := r.BasicAuth(); token != "rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("expired\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(Config{BaseURL: srv.URL + "/", EnrichmentTimeout: time.Second, TokenFile: path})
+	if _, err := c.ReloadToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "ACME" {
+		t.Fatalf("GetOrganization(1) = %v, %v, want retry with the rotated token", org, err)
+	}
+}
+
+func TestClientMessageCache(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":7,"ticket_id":1,"by":0,"created_at":100}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, MessageCacheSize: 10})
+	ticket := &Ticket{ID: 1, UpdatedAt: 100}
+
+	for i := 0; i < 2; i++ {
+		if messages, err := c.TicketMessages(ticket); err != nil || len(messages) != 1 {
+			t.Fatalf("TicketMessages() = %v, %v", messages, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("unchanged ticket fetched %d times, want 1", n)
+	}
+
+	ticket.UpdatedAt = 200
+	if _, err := c.TicketMessages(ticket); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("updated ticket was not fetched again, %d requests", n)
+	}
+}
+
+func TestClientTicketLogs(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/api/ticket/log" || r.URL.Query().Get("ticket_id") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":2,"data":[{"id":1,"ticket_id":1,"event":"assigned","operator_id":5},`+
+			`{"id":2,"ticket_id":1,"event":"assigned","operator_id":6}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, MessageCacheSize: 10})
+	ticket := &Ticket{ID: 1, UpdatedAt: 100}
+
+	for i := 0; i < 2; i++ {
+		logs, err := c.TicketLogs(ticket)
+		if err != nil || len(logs) != 2 || logs[1].Event != TicketLogAssigned || logs[1].OperatorID != 6 {
+			t.Fatalf("TicketLogs() = %v, %v", logs, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("unchanged ticket fetched %d times, want 1", n)
+	}
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"https://support.example.com/", "https://support.example.com"},
+		{"https://intranet.example.com:8443/helpdesk/", "https://intranet.example.com:8443/helpdesk"},
+		{"http://intranet.example.com/helpdesk/api", "http://intranet.example.com/helpdesk"},
+		{" intranet.example.com:8080/helpdesk ", "https://intranet.example.com:8080/helpdesk"},
+	}
+
+	for _, tt := range tests {
+		if got, err := NormalizeBaseURL(tt.raw); err != nil || got != tt.want {
+			t.Errorf("NormalizeBaseURL(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"", "ftp://example.com", "https://example.com/?a=b", "https://"} {
+		if _, err := NormalizeBaseURL(raw); err == nil {
+			t.Errorf("NormalizeBaseURL(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestClientCheckKeepsHTTPS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/helpdesk/api/ticket/ticket" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":0,"data":[]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	// the token is never sent over plain HTTP to a base URL without scheme
+	host := strings.TrimPrefix(srv.URL, "http://")
+	c := New(Config{BaseURL: host + "/helpdesk/", EnrichmentTimeout: time.Second})
+	if err := c.Check(); err == nil {
+		t.Error("Check() of an HTTP server without scheme succeeded")
+	}
+	if want := "https://" + host + "/helpdesk"; c.BaseURL() != want {
+		t.Errorf("BaseURL() = %q, want %q", c.BaseURL(), want)
+	}
+
+	c = New(Config{BaseURL: srv.URL + "/helpdesk/", EnrichmentTimeout: time.Second})
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	if err := c.Check(); !isNotFound(err) {
+		t.Errorf("Check() without path prefix = %v, want not found", err)
+	}
+}
+
+func TestClientAPIVersionDetection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/ticket/ticket" && r.URL.Query().Get("start") == "0":
+			fmt.Fprint(w, `{"status":"success","meta":{"total":2},"data":[{"id":2,"subject":"Hello"}]}`)
+		case r.URL.Path == "/api/ticket/ticket":
+			fmt.Fprint(w, `{"status":"success","meta":{"total":2},"data":[{"id":1,"subject":"World"}]}`)
+		case r.URL.Path == "/api/user/organization/1":
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, EnrichmentTimeout: time.Second})
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.APIVersion(); v != "v2" {
+		t.Errorf("APIVersion() = %q, want v2", v)
+	}
+
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 2 {
+		t.Errorf("FetchAllTickets() = %d tickets, %v, want both pages", len(tickets), err)
+	}
+	if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "ACME" {
+		t.Errorf("GetOrganization(1) = %v, %v", org, err)
+	}
+
+	c = New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, APIVersion: "v1"})
+	if err := c.Check(); err != nil || c.APIVersion() != "v1" {
+		t.Errorf("Check() with configured v1 = %v, version %q", err, c.APIVersion())
+	}
+}
+
+func TestClientAPIVersionRedetection(t *testing.T) {
+	var envelope atomic.Value
+	envelope.Store(`{"status":"success","count":1,"data":[{"id":1,"name":"Open"}]}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ticket/ticket", "/api/ticket/status":
+			fmt.Fprint(w, envelope.Load())
+		case "/api/user/organization/1":
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// the version is detected from the first list response without Check
+	envelope.Store(`{"status":"success","meta":{"total":1},"data":[{"id":1,"name":"Open"}]}`)
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	if _, err := c.ListStatuses(); err != nil || c.APIVersion() != "v2" {
+		t.Errorf("ListStatuses() = %v, version %q, want v2", err, c.APIVersion())
+	}
+
+	// an installation upgraded after the detection answers the v1 paths
+	// with not found
+	envelope.Store(`{"status":"success","count":1,"data":[{"id":1,"name":"Open"}]}`)
+	c = New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	if err := c.Check(); err != nil || c.APIVersion() != "v1" {
+		t.Fatalf("Check() = %v, version %q, want v1", err, c.APIVersion())
+	}
+	if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "ACME" {
+		t.Errorf("GetOrganization(1) = %v, %v", org, err)
+	}
+	if v := c.APIVersion(); v != "v2" {
+		t.Errorf("APIVersion() after not found = %q, want v2", v)
+	}
+
+	c = New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, APIVersion: "v1"})
+	if _, err := c.GetOrganization(1); !errors.Is(err, ErrNotFound) || c.APIVersion() != "v1" {
+		t.Errorf("GetOrganization(1) with configured v1 = %v, version %q", err, c.APIVersion())
+	}
+}
+
+func TestClientFieldSelection(t *testing.T) {
+	var selected, full atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("fields") {
+			selected.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		full.Add(1)
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Hello"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, SelectFields: true})
+	for i := 0; i < 2; i++ {
+		if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 {
+			t.Fatalf("FetchAllTickets() = %v, %v", tickets, err)
+		}
+	}
+
+	if selected.Load() != 1 || full.Load() != 2 {
+		t.Errorf("sent %d requests with and %d without field selection, want 1 and 2", selected.Load(), full.Load())
+	}
+}
+
+func TestTicketFieldsCoverTicket(t *testing.T) {
+	typ := reflect.TypeOf(Ticket{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = strings.ToLower(typ.Field(i).Name)
+		}
+		if !slices.Contains(ticketFields, name) {
+			t.Errorf("field %s of Ticket is not requested with field selection", name)
+		}
+	}
+}
+
+func TestClientListCustomFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"status":"success","count":3,"data":[{"id":1,"name":"Area","type":7},{"id":2,"name":"Notes","type":8}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":3,"data":[{"id":3,"name":"Go Live","type":1}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	fields, err := c.ListCustomFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields[2].Name != "Go Live" {
+		t.Errorf("ListCustomFields() = %d fields, want all 3 pages", len(fields))
+	}
+}
+
+func TestClientListEmailLogs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"status":"success","count":5,"data":[{"id":5,"type":1,"status":1},{"id":4,"type":0,"status":0}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":5,"data":[{"id":3,"type":1,"status":1},{"id":2,"type":1,"status":1}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second})
+	if id, err := c.LastEmailLogID(); err != nil || id != 5 {
+		t.Errorf("LastEmailLogID() = %d, %v, want 5", id, err)
+	}
+
+	entries, err := c.ListEmailLogs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].ID != 3 {
+		t.Errorf("ListEmailLogs(2) = %d entries, want the 3 entries above 2", len(entries))
+	}
+}
+
+func TestClientSystemInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"version":"5.4.1","cron_last_run":1700000000,"brand_name":"ACME"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	info, err := c.GetSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "5.4.1" || info.CronLastRun != 1700000000 {
+		t.Errorf("GetSystemInfo() = %+v", info)
+	}
+}
+
+func TestClientConditionalRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"title":"Hello","published":1}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	notModified := testutil.ToFloat64(supportPalAPIRequests.WithLabelValues("304"))
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, ConditionalCacheSize: 10})
+	for i := 0; i < 2; i++ {
+		if articles, err := c.ListArticles(); err != nil || len(articles) != 1 || articles[0].Title != "Hello" {
+			t.Fatalf("ListArticles() = %v, %v", articles, err)
+		}
+	}
+	if v := testutil.ToFloat64(supportPalAPIRequests.WithLabelValues("304")) - notModified; v != 1 {
+		t.Errorf("%v requests answered with 304, want the second one", v)
+	}
+}
+
+func TestClientDeprecationHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@1767225600")
+		w.Header().Set("Sunset", "Wed, 01 Jul 2026 00:00:00 GMT")
+		fmt.Fprint(w, `{"status":"success","data":{"id":42,"name":"ACME"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	if _, err := c.GetOrganization(42); err != nil {
+		t.Fatal(err)
+	}
+
+	for header, value := range map[string]string{"deprecation": "@1767225600", "sunset": "Wed, 01 Jul 2026 00:00:00 GMT"} {
+		if v := testutil.ToFloat64(supportPalAPIDeprecationWarning.WithLabelValues("/api/user/organisation/:id", header, value)); v != 1 {
+			t.Errorf("%s warning = %v, want 1", header, v)
+		}
+	}
+}
+
+func TestClientCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Plain"}]}`)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, `{"status":"success","count":1,"data":[{"id":1,"subject":"Compressed"}]}`)
+		zw.Close()
+	}))
+	t.Cleanup(srv.Close)
+
+	transferred := testutil.ToFloat64(supportPalAPIResponseBytes.WithLabelValues("gzip"))
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 || tickets[0].Subject != "Compressed" {
+		t.Fatalf("FetchAllTickets() = %v, %v", tickets, err)
+	}
+	if v := testutil.ToFloat64(supportPalAPIResponseBytes.WithLabelValues("gzip")) - transferred; v == 0 {
+		t.Error("no compressed bytes counted")
+	}
+
+	c = New(Config{BaseURL: srv.URL, ListTimeout: time.Second, DisableCompression: true})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 || tickets[0].Subject != "Plain" {
+		t.Fatalf("FetchAllTickets() without compression = %v, %v", tickets, err)
+	}
+}
+
+func TestClientDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Hello"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Minute})
+	c.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := c.FetchAllTickets(); !errors.Is(err, ErrDeadline) {
+		t.Fatalf("FetchAllTickets() after the deadline = %v, want ErrDeadline", err)
+	}
+	if c.PageSize() != 100 {
+		t.Errorf("PageSize() after the deadline = %d, want 100", c.PageSize())
+	}
+
+	c.SetDeadline(time.Time{})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 {
+		t.Fatalf("FetchAllTickets() without deadline = %v, %v", tickets, err)
+	}
+}
+
+func TestClientSchemaDrift(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Hello","number":"1001","last_reply":2,`+
+			`"channel":"web","status":{"id":1,"name":"Open","colour":"#fff"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, Strict: true})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 || tickets[0].Subject != "Hello" {
+		t.Fatalf("FetchAllTickets() = %v, %v", tickets, err)
+	}
+
+	for _, want := range [][]string{
+		{"ticket", "number", "unknown"},
+		{"ticket", "last_reply", "unknown"},
+		{"ticket", "colour", "unknown"},
+		{"ticket", "priority", "missing"},
+	} {
+		if v := testutil.ToFloat64(supportPalSchemaDrift.WithLabelValues(want...)); v != 1 {
+			t.Errorf("drift %v = %v, want 1", want, v)
+		}
+	}
+}
+
+func TestClientLoadMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "20" {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","count":1,"data":[{"id":1,"subject":"Hello"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	timeouts := testutil.ToFloat64(supportPalAPIRequests.WithLabelValues("504"))
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 40})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 1 {
+		t.Fatalf("FetchAllTickets() = %v, %v", tickets, err)
+	}
+
+	for setting, want := range map[string]float64{"configured": 40, "effective": 20} {
+		if v := testutil.ToFloat64(supportPalAPIPageSize.WithLabelValues(setting)); v != want {
+			t.Errorf("%s page size = %v, want %v", setting, v, want)
+		}
+	}
+	if v := testutil.ToFloat64(supportPalAPIRequests.WithLabelValues("504")) - timeouts; v != 1 {
+		t.Errorf("counted %v failed requests, want 1", v)
+	}
+	if v := testutil.ToFloat64(supportPalAPIRequestsInFlight); v != 0 {
+		t.Errorf("%v requests in flight after the cycle, want 0", v)
+	}
+}
+
+// timeoutError is a net.Error of a timed out request
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestShrinkable(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{Offset: 10}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &StatusError{Code: http.StatusBadGateway}, true},
+		{"timeout", fmt.Errorf("list: %w", timeoutError{}), true},
+		{"request timeout", fmt.Errorf("list: %w", context.DeadlineExceeded), true},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"empty body", io.EOF, true},
+		{"invalid body", syntaxErr, true},
+		{"authentication", &StatusError{Code: http.StatusUnauthorized}, false},
+		{"cycle deadline", fmt.Errorf("list: %w", ErrDeadline), false},
+		{"connection refused", refused, false},
+		{"untrusted certificate", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, false},
+	} {
+		if got := shrinkable(tt.err); got != tt.want {
+			t.Errorf("shrinkable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientPageSizeKeptOnClientErrors(t *testing.T) {
+	var limits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+
+	// a smaller page does not fix a rejected token
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 40})
+	if _, err := c.FetchAllTickets(); err == nil {
+		t.Fatal("FetchAllTickets() succeeded")
+	}
+	if !slices.Equal(limits, []string{"40"}) || c.PageSize() != 40 {
+		t.Errorf("requested limits %v, page size %d, want one request of 40", limits, c.PageSize())
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	l := &errorLog{size: 3}
+	for i := range 5 {
+		l.add(APIError{Path: strconv.Itoa(i)})
+	}
+	var paths []string
+	for _, e := range l.recent() {
+		paths = append(paths, e.Path)
+	}
+	if !slices.Equal(paths, []string{"4", "3", "2"}) {
+		t.Errorf("recent errors %v, want the last three newest first", paths)
+	}
+
+	// a log without a size keeps nothing
+	disabled := &errorLog{}
+	disabled.add(APIError{Path: "0"})
+	if recent := disabled.recent(); len(recent) != 0 {
+		t.Errorf("recent errors of a disabled log = %v", recent)
+	}
+}
+
+func TestClientRecentErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, strings.Repeat("x", 2*maxErrorBody))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, ErrorLogSize: 2})
+	for id := range 3 {
+		if _, err := c.GetTicket(id + 1); err == nil {
+			t.Fatalf("GetTicket(%d) succeeded", id+1)
+		}
+	}
+	recent := c.RecentErrors()
+	if len(recent) != 2 {
+		t.Fatalf("%d recent errors, want 2", len(recent))
+	}
+	if e := recent[0]; e.Method != "GET" || !strings.HasPrefix(e.Path, "/api/ticket/ticket/3") || e.Code != http.StatusInternalServerError ||
+		len(e.Body) != maxErrorBody || e.Time.IsZero() || e.Error == "" {
+		t.Errorf("newest error = %+v", e)
+	}
+	if !strings.HasPrefix(recent[1].Path, "/api/ticket/ticket/2") {
+		t.Errorf("second error path %q, want ticket 2", recent[1].Path)
+	}
+
+	// a request without a response has no status code
+	srv.Close()
+	c.GetTicket(4)
+	if e := c.RecentErrors()[0]; e.Code != 0 || !strings.HasPrefix(e.Path, "/api/ticket/ticket/4") {
+		t.Errorf("error without a response = %+v", e)
+	}
+}
+
+func TestClientAvailability(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/organisation/1":
+			fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+		case "/api/user/organisation/2":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	endpoint := "/api/user/organisation/:id"
+	failures := testutil.ToFloat64(supportPalAPIRequestFailures.WithLabelValues(endpoint, http.MethodGet))
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, AvailabilityWindow: time.Hour})
+	for id := 1; id <= 3; id++ {
+		c.GetOrganization(id)
+	}
+
+	if v := testutil.ToFloat64(supportPalAPIRequestFailures.WithLabelValues(endpoint, http.MethodGet)) - failures; v != 1 {
+		t.Errorf("counted %v failed requests, want 1, the 404 does not count", v)
+	}
+	if v := testutil.ToFloat64(supportPalAPIAvailability.WithLabelValues(endpoint)); v != 2.0/3 {
+		t.Errorf("availability ratio = %v, want 2/3", v)
+	}
+	if n := testutil.CollectAndCount(supportPalAPIRequestDuration, "supportpal_api_request_duration_seconds"); n == 0 {
+		t.Error("no request durations observed")
+	}
+}
+
+func TestClientHeaderPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		if start < 20 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/ticket/ticket?start=%d>; rel="next"`, "http://"+r.Host, start+10))
+		} else {
+			w.Header().Set("Link", `<http://`+r.Host+`/api/ticket/ticket?start=0>; rel="first"`)
+		}
+		w.Header().Set("X-Total-Count", "25")
+
+		var data []string
+		for id := start + 1; id <= min(start+10, 25); id++ {
+			data = append(data, fmt.Sprintf(`{"id":%d}`, id))
+		}
+		fmt.Fprintf(w, `{"status":"success","data":[%s]}`, strings.Join(data, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 10})
+	resp, err := c.ListTickets(0, 10)
+	if err != nil || resp.Count != 25 || resp.Links["next"] == "" {
+		t.Fatalf("ListTickets() = %+v, %v, want the total and next link of the headers", resp, err)
+	}
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 25 {
+		t.Fatalf("FetchAllTickets() = %d tickets, %v, want 25", len(tickets), err)
+	}
+}
+
+func TestClientCursorPagination(t *testing.T) {
+	var cursors []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// pages by the cursor of the next link, start only ends the
+		// listing
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		first := 1
+		switch {
+		case r.URL.Query().Get("start") != "" && r.URL.Query().Get("start") != "0":
+			fmt.Fprint(w, `{"status":"success","data":[]}`)
+			return
+		case cursor == "":
+			w.Header().Set("Link", `<http://`+r.Host+`/api/ticket/ticket?cursor=b&limit=10>; rel="next"`)
+		case cursor == "b":
+			first = 11
+			w.Header().Set("Link", `</api/ticket/ticket?cursor=c&limit=10>; rel="next", <http://example.com/api/ticket/ticket>; rel="first"`)
+		case cursor == "c":
+			first = 21
+			w.Header().Set("Link", `<http://example.com/api/ticket/ticket?cursor=d>; rel="next"`)
+		default:
+			t.Errorf("request for cursor %q", cursor)
+		}
+
+		var data []string
+		for id := first; id < first+10; id++ {
+			data = append(data, fmt.Sprintf(`{"id":%d}`, id))
+		}
+		fmt.Fprintf(w, `{"status":"success","data":[%s]}`, strings.Join(data, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	// the absolute and relative links are followed, the link to another
+	// host is not and the listing continues with start
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 10})
+	tickets, err := c.FetchAllTickets()
+	if err != nil || len(tickets) != 30 || tickets[29].ID != 30 {
+		t.Fatalf("FetchAllTickets() = %d tickets, %v, want 30", len(tickets), err)
+	}
+	if !slices.Equal(cursors, []string{"", "b", "c", ""}) {
+		t.Errorf("requested cursors %q, want the next links until the foreign one", cursors)
+	}
+}
+
+func TestClientRepeatedPages(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ignores start and reports more tickets than it returns
+		requests.Add(1)
+		fmt.Fprint(w, `{"status":"success","count":1000,"data":[{"id":2},{"id":1}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 2})
+	if tickets, err := c.FetchAllTickets(); err != nil || len(tickets) != 2 {
+		t.Fatalf("FetchAllTickets() = %d tickets, %v, want 2", len(tickets), err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("sent %d requests, want 2 until the repeated page", n)
+	}
+}
+
+func TestClientSharedCache(t *testing.T) {
+	var lookups atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		fmt.Fprint(w, `{"status":"success","data":{"id":1,"name":"ACME"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	redis := newFakeRedis(t)
+	if store, err := NewRedisStore("redis://:wrong@" + redis.addr); err != nil || store.Ping() == nil {
+		t.Fatalf("Ping() with a wrong password succeeded, err %v", err)
+	}
+
+	// two replicas sharing one Redis
+	for i := 0; i < 2; i++ {
+		store, err := NewRedisStore("redis://:secret@" + redis.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Prefix = "test:"
+		c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second, CacheTTL: time.Hour, SharedCache: store})
+		if org, err := c.GetOrganization(1); err != nil || org.Data.Name != "ACME" {
+			t.Fatalf("replica %d: GetOrganization() = %v, %v", i, org, err)
+		}
+	}
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("the API was asked %d times, want once for both replicas", n)
+	}
+	if _, ok := redis.values.Load("test:organization:1"); !ok {
+		t.Error("organization not stored under the prefixed key")
+	}
+}
+
+func TestClientSkipsFailedPages(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.URL.Query().Get("start")
+		if start == "10" {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","count":30,"data":[{"id":%s}]}`, start)
+	}))
+	t.Cleanup(srv.Close)
+
+	// every page holds one ticket, the skipped page covers the offsets 10 to 19
+	skipped := testutil.ToFloat64(supportPalPagesFailed)
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 10, PageRetries: 2})
+	var ids []int
+	err := c.StreamTickets(nil, func(ticket *Ticket) { ids = append(ids, ticket.ID) })
+
+	var pageErr *PageError
+	if !errors.As(err, &pageErr) || !slices.Equal(pageErr.Starts, []int{10}) {
+		t.Fatalf("StreamTickets() = %v, want a PageError of the page at 10", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("requested the failed page %d times, want 3", n)
+	}
+	if v := testutil.ToFloat64(supportPalPagesFailed) - skipped; v != 1 {
+		t.Errorf("counted %v failed pages, want 1", v)
+	}
+	if len(ids) == 0 || ids[0] != 0 || !slices.Contains(ids, 20) {
+		t.Errorf("passed tickets %v, want those around the failed page", ids)
+	}
+}
+
+func TestClientPacing(t *testing.T) {
+	var mu sync.Mutex
+	var sent []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, time.Now())
+		mu.Unlock()
+		fmt.Fprint(w, `{"status":"success","count":0,"data":[]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, EnrichmentTimeout: time.Second})
+	cycle := func() {
+		c.Pace(200 * time.Millisecond)
+		defer c.Pace(0)
+		for i := 0; i < 4; i++ {
+			if _, err := c.ListStatuses(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the first cycle counts the requests the second one is paced by
+	cycle()
+	start := time.Now()
+	mu.Lock()
+	sent = nil
+	mu.Unlock()
+	cycle()
+
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("paced cycle took %v, want the requests spread over 200ms", elapsed)
+	}
+	for i := 1; i < len(sent); i++ {
+		if gap := sent[i].Sub(sent[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d sent %v after the previous one, want about 50ms", i, gap)
+		}
+	}
+
+	// unpaced requests are sent immediately
+	start = time.Now()
+	c.ListStatuses()
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("unpaced request took %v", elapsed)
+	}
+}
+
+func TestClientShard(t *testing.T) {
+	for s, want := range map[string]Shard{"": {}, "1/1": {1, 1}, "2/4": {2, 4}} {
+		if shard, err := ParseShard(s); err != nil || shard != want {
+			t.Errorf("ParseShard(%q) = %v, %v, want %v", s, shard, err, want)
+		}
+	}
+	for _, s := range []string{"2", "0/4", "5/4", "a/4", "1/0"} {
+		if _, err := ParseShard(s); err == nil {
+			t.Errorf("ParseShard(%q) succeeded, want an error", s)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","count":5,"data":[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, Shard: Shard{Index: 2, Count: 3}})
+	tickets, err := c.FetchAllTickets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, ticket := range tickets {
+		ids = append(ids, ticket.ID)
+	}
+	if !slices.Equal(ids, []int{1, 4}) {
+		t.Errorf("shard 2/3 listed tickets %v, want [1 4]", ids)
+	}
+}
+
+func TestClientMaxTickets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if column := r.URL.Query().Get("order_column"); column != "updated_at" {
+			t.Errorf("order_column = %q, want updated_at", column)
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var data []string
+		for id := start; id < min(start+limit, 100); id++ {
+			data = append(data, fmt.Sprintf(`{"id":%d}`, id))
+		}
+		fmt.Fprintf(w, `{"status":"success","count":100,"data":[%s]}`, strings.Join(data, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, PageSize: 10, OrderColumn: "updated_at", MaxTickets: 25})
+	// tickets out of bound do not end the pagination when ordered by update
+	tickets, err := c.FetchTickets(func(ticket *Ticket) bool { return ticket.ID != 3 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 24 || tickets[len(tickets)-1].ID != 24 {
+		t.Errorf("listed %d tickets, want 24 up to ID 24", len(tickets))
+	}
+}
+
+// recordingTracer records the names and errors of the spans it receives
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (r *recordingTracer) Span(name string, start, end time.Time, attributes map[string]string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		name += " error"
+	}
+	r.spans = append(r.spans, name+" "+attributes["http.response.status_code"])
+}
+
+func TestClientTracer(t *testing.T) {
+	srv := newTestServer(t)
+	tracer := &recordingTracer{}
+	c := New(Config{BaseURL: srv.URL, ListTimeout: time.Second, EnrichmentTimeout: time.Second, Tracer: tracer})
+
+	if _, err := c.FetchTickets(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrganization(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CountUsers(); err == nil {
+		t.Fatal("CountUsers() succeeded against a server without users")
+	}
+
+	want := []string{
+		"GET /api/ticket/ticket 200",
+		"ticket page ",
+		"GET /api/user/organisation/{id} 200",
+		"GET /api/user/user error 404",
+	}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Errorf("spans = %q, want %q", tracer.spans, want)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache[int, string]("test", time.Minute, 2)
+	c.Set(1, "a")
+	c.Set(2, "b")
+	c.Get(1)
+	c.Set(3, "c")
+
+	if _, ok := c.Get(2); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if v, ok := c.Get(1); !ok || v != "a" {
+		t.Errorf("Get(1) = %q, %v", v, ok)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := NewCache[int, string]("test", time.Millisecond, 0)
+	c.Set(1, "a")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get(1); ok {
+		t.Error("expired entry was returned")
+	}
+}
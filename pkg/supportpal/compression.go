@@ -0,0 +1,70 @@
+package supportpal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalAPIResponseBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_api_response_bytes_total",
+	Help: "Number of response body bytes received from the API as transferred, by content encoding",
+}, []string{"encoding"})
+
+// countingReader counts the bytes read from r as transferred with encoding
+type countingReader struct {
+	r       io.Reader
+	counter prometheus.Counter
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.counter.Add(float64(n))
+	return n, err
+}
+
+// decodedBody closes the response body when the reader of the decoded body
+// is done
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// decodeBody replaces the body of resp with its decompressed content and
+// counts the transferred bytes. The transport does not decompress itself,
+// so the compressed size is known.
+func decodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" {
+		encoding = "identity"
+	}
+
+	counted := &countingReader{r: resp.Body, counter: supportPalAPIResponseBytes.WithLabelValues(encoding)}
+	switch {
+	case encoding == "identity":
+		resp.Body = decodedBody{counted, resp.Body}
+		return nil
+	case encoding != "gzip":
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	case resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusNoContent:
+		return nil
+	}
+
+	zr, err := gzip.NewReader(counted)
+	if err != nil {
+		return fmt.Errorf("decompress response: %w", err)
+	}
+
+	resp.Body = decodedBody{zr, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
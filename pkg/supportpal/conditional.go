@@ -0,0 +1,65 @@
+package supportpal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// conditionalEntry holds the validators and body of a response to a GET
+// request. The body is replayed when the API answers a conditional request
+// for the same path with 304 Not Modified.
+type conditionalEntry struct {
+	etag         string
+	lastModified string
+	body         []byte
+	// header is replayed with the body, a 304 need not repeat pagination headers
+	header http.Header
+}
+
+// conditionalRequest adds the validators of the stored response for path to
+// req and returns the stored entry, nil if there is none
+func (c *Client) conditionalRequest(req *http.Request, path string) *conditionalEntry {
+	if c.conditional == nil || req.Method != http.MethodGet {
+		return nil
+	}
+
+	entry, ok := c.conditional.Get(path)
+	if !ok {
+		return nil
+	}
+
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+
+	return entry
+}
+
+// conditionalBody returns the body and header passed to read for a
+// successful response: the stored ones for 304 Not Modified, otherwise those
+// of the response, which are recorded for later conditional requests when it
+// carries validators. store saves the recorded body once read consumed it.
+func (c *Client) conditionalBody(resp *http.Response, path string, entry *conditionalEntry) (body io.Reader, header http.Header, store func()) {
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		return bytes.NewReader(entry.body), entry.header, func() {}
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if c.conditional == nil || resp.Request.Method != http.MethodGet || (etag == "" && lastModified == "") {
+		return resp.Body, resp.Header, func() {}
+	}
+
+	var recorded bytes.Buffer
+	body = io.TeeReader(resp.Body, &recorded)
+	return body, resp.Header, func() {
+		// decoders may stop before the trailing whitespace
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return
+		}
+		c.conditional.Set(path, &conditionalEntry{etag: etag, lastModified: lastModified, body: recorded.Bytes(), header: resp.Header.Clone()})
+	}
+}
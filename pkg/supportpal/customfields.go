@@ -0,0 +1,110 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// CustomField represents a ticket custom field
+type CustomField struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Type    int    `json:"type"`
+	Options []struct {
+		ID    int    `json:"id"`
+		Value string `json:"value"`
+	} `json:"options"`
+}
+
+// GetCustomFieldResponse represents the response body for getting a custom field
+type GetCustomFieldResponse struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Data    CustomField `json:"data"`
+}
+
+// ListCustomFieldsResponse represents the response body for listing custom fields
+type ListCustomFieldsResponse struct {
+	Status  string         `json:"status"`
+	Message string         `json:"message"`
+	Count   int            `json:"count"`
+	Data    []*CustomField `json:"data"`
+}
+
+// ListCustomFields is a helper function to list all ticket custom fields
+func (c *Client) ListCustomFields() ([]*CustomField, error) {
+	var fields []*CustomField
+	start := 0
+	limit := 100
+	for {
+		url := "/api/ticket/customfield?start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+		resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page ListCustomFieldsResponse
+		err = json.Unmarshal(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, page.Data...)
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return fields, nil
+}
+
+// GetCustomField is a helper function to get a custom field. It returns an
+// error wrapping ErrNotFound if the custom field was deleted.
+func (c *Client) GetCustomField(id int) (*GetCustomFieldResponse, error) {
+	if field, found := c.customFields.Get(id); found {
+		if field == nil {
+			return nil, fmt.Errorf("custom field %d: %w", id, ErrNotFound)
+		}
+		return field, nil
+	}
+	url := "/api/ticket/customfield/" + strconv.Itoa(id)
+	resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+	if err == nil {
+		checkResponseDrift[CustomField](c.drift, "customfield", resp)
+
+		var customField *GetCustomFieldResponse
+		if customField, err = decodeCustomField(resp); err == nil {
+			c.customFields.Set(id, customField)
+			return customField, nil
+		}
+	}
+
+	if isNotFound(err) {
+		c.customFields.Set(id, nil)
+		supportPalNotFound.WithLabelValues("customfield").Inc()
+		return nil, fmt.Errorf("custom field %d: %w", id, ErrNotFound)
+	}
+
+	return nil, err
+}
+
+// decodeCustomField decodes the response body of a custom field request.
+// Responses without custom field wrap ErrNotFound.
+func decodeCustomField(data []byte) (*GetCustomFieldResponse, error) {
+	var customField GetCustomFieldResponse
+	if err := json.Unmarshal(data, &customField); err != nil {
+		return nil, err
+	}
+
+	if customField.Data.ID == 0 {
+		return nil, fmt.Errorf("custom field response holds no custom field: %w", ErrNotFound)
+	}
+
+	return &customField, nil
+}
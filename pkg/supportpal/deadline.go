@@ -0,0 +1,44 @@
+package supportpal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeadline is wrapped by the errors of requests aborted at the deadline
+// set with SetDeadline
+var ErrDeadline = errors.New("deadline exceeded")
+
+// SetDeadline aborts every request still running at deadline and fails
+// later ones immediately, e.g. once a collection cycle ran out of time. The
+// zero time removes the deadline.
+func (c *Client) SetDeadline(deadline time.Time) {
+	if deadline.IsZero() {
+		c.deadline.Store(0)
+		return
+	}
+	c.deadline.Store(deadline.UnixNano())
+}
+
+// requestContext returns the context of a request aborted after timeout or
+// at the deadline, whichever comes first
+func (c *Client) requestContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(timeout)
+	if d := c.deadline.Load(); d != 0 && d < deadline.UnixNano() {
+		return context.WithDeadlineCause(context.Background(), time.Unix(0, d), ErrDeadline)
+	}
+
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// deadlineError wraps ErrDeadline around the error of a request aborted at
+// the deadline instead of its timeout
+func deadlineError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), ErrDeadline) {
+		return fmt.Errorf("%w: %w", ErrDeadline, err)
+	}
+
+	return err
+}
@@ -0,0 +1,51 @@
+package supportpal
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalAPIDeprecationWarning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "supportpal_api_deprecation_warning",
+	Help: "Deprecation, Sunset and Warning headers received on API responses by endpoint, always 1",
+}, []string{"endpoint", "header", "value"})
+
+// deprecationHeaders are the response headers announcing API changes
+var deprecationHeaders = []string{"Deprecation", "Sunset", "Warning"}
+
+// deprecationWarnings holds the deprecation headers already reported, every
+// header is logged once per endpoint and value
+var deprecationWarnings sync.Map
+
+// endpointOf returns the path of a request without query, with numeric
+// segments such as IDs replaced by :id to bound the endpoints
+func endpointOf(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment != "" && strings.Trim(segment, "0123456789") == "" {
+			segments[i] = ":id"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// reportDeprecation logs and exports the deprecation headers of a response
+func (c *Client) reportDeprecation(path string, header http.Header) {
+	for _, name := range deprecationHeaders {
+		for _, value := range header.Values(name) {
+			endpoint := endpointOf(path)
+			if _, loaded := deprecationWarnings.LoadOrStore(endpoint+"\xff"+name+"\xff"+value, true); loaded {
+				continue
+			}
+
+			supportPalAPIDeprecationWarning.WithLabelValues(endpoint, strings.ToLower(name), value).Set(1)
+			c.config.Logger.Warn("API announced a change", "endpoint", endpoint, "header", name, "value", value)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package supportpal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalSchemaDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "supportpal_api_schema_drift",
+	Help: "Fields of API objects found by the strict decode mode that the exporter does not know (change=unknown) or expects but did not receive (change=missing), always 1",
+}, []string{"object", "field", "change"})
+
+// maxDriftFields bounds the unknown fields looked for per decoded object
+const maxDriftFields = 50
+
+// driftDetector reports differences between the objects returned by the API
+// and the structs they are decoded into. Every difference is logged once.
+type driftDetector struct {
+	logger *slog.Logger
+	seen   sync.Map
+}
+
+// report records a difference of object
+func (d *driftDetector) report(object, field, change string) {
+	if _, loaded := d.seen.LoadOrStore(object+"\xff"+field+"\xff"+change, true); loaded {
+		return
+	}
+
+	supportPalSchemaDrift.WithLabelValues(object, field, change).Set(1)
+	d.logger.Warn("API schema drift", "object", object, "field", field, "change", change)
+}
+
+// checkDrift compares the JSON object data with T. Fields unknown to T are
+// found by decoding with DisallowUnknownFields, dropping every reported
+// field and decoding again; unknown fields of nested objects are reported
+// one at a time. Nothing happens when d is nil.
+func checkDrift[T any](d *driftDetector, object string, data []byte) {
+	if d == nil {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return
+	}
+
+	for _, name := range jsonFields(reflect.TypeFor[T]()) {
+		found := false
+		for key := range fields {
+			if strings.EqualFold(key, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.report(object, name, "missing")
+		}
+	}
+
+	for i := 0; i < maxDriftFields; i++ {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(new(T))
+		if err == nil {
+			return
+		}
+
+		quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+		if !ok {
+			return
+		}
+		name, err := strconv.Unquote(quoted)
+		if err != nil {
+			return
+		}
+		d.report(object, name, "unknown")
+
+		if _, ok := fields[name]; !ok {
+			// a nested field, which cannot be dropped to look further
+			return
+		}
+		delete(fields, name)
+		if data, err = json.Marshal(fields); err != nil {
+			return
+		}
+	}
+}
+
+// checkResponseDrift compares the data of an API response, a single object
+// or an array of objects, with T
+func checkResponseDrift[T any](d *driftDetector, object string, resp []byte) {
+	if d == nil {
+		return
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &envelope); err != nil {
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(envelope.Data, &items); err != nil {
+		checkDrift[T](d, object, envelope.Data)
+		return
+	}
+
+	for _, item := range items {
+		checkDrift[T](d, object, item)
+	}
+}
+
+// jsonFields returns the JSON names of the fields of the struct type t
+func jsonFields(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -0,0 +1,99 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Email log types and statuses
+const (
+	EmailIncoming = 0
+	EmailOutgoing = 1
+
+	EmailFailed = 0
+	EmailSent   = 1
+)
+
+// EmailLog represents an entry of the email log, an email piped into or sent
+// by SupportPal
+type EmailLog struct {
+	ID        int    `json:"id"`
+	Type      int    `json:"type"`
+	Status    int    `json:"status"`
+	Subject   string `json:"subject"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// ListEmailLogsResponse represents the response body for listing the email log
+type ListEmailLogsResponse struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Count   int         `json:"count"`
+	Data    []*EmailLog `json:"data"`
+}
+
+// listEmailLogs requests a page of the email log, newest first
+func (c *Client) listEmailLogs(start, limit int) (*ListEmailLogsResponse, error) {
+	url := "/api/core/emaillog?order_column=id&order_direction=desc&start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+	resp, err := c.request("GET", url, nil, c.config.ListTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var page ListEmailLogsResponse
+	if err := json.Unmarshal(resp, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// LastEmailLogID returns the ID of the newest email log entry, 0 if the log
+// is empty
+func (c *Client) LastEmailLogID() (int, error) {
+	page, err := c.listEmailLogs(0, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range page.Data {
+		if entry != nil {
+			return entry.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// ListEmailLogs is a helper function to list the email log entries with an ID
+// above afterID, newest first. It stops paging at the first older entry, so
+// a cycle only requests the entries logged since the previous one.
+func (c *Client) ListEmailLogs(afterID int) ([]*EmailLog, error) {
+	var entries []*EmailLog
+	start := 0
+	limit := 100
+	for {
+		page, err := c.listEmailLogs(start, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page.Data {
+			if entry == nil {
+				continue
+			}
+			if entry.ID <= afterID {
+				return entries, nil
+			}
+			entries = append(entries, entry)
+		}
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,65 @@
+package supportpal
+
+import (
+	"sync"
+	"time"
+)
+
+// maxErrorBody is the number of response body bytes kept of a failed request
+const maxErrorBody = 512
+
+// APIError describes a failed API request
+type APIError struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	// Code is the status code of the response, 0 if none was received
+	Code  int    `json:"code,omitempty"`
+	Error string `json:"error"`
+	// Body is the start of the response body
+	Body string `json:"body,omitempty"`
+}
+
+// errorLog is a ring buffer of the most recent API errors
+type errorLog struct {
+	mu     sync.Mutex
+	errors []APIError
+	next   int
+	size   int
+}
+
+// add records an error, replacing the oldest recorded error once full
+func (l *errorLog) add(e APIError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size <= 0 {
+		return
+	}
+
+	if len(l.errors) < l.size {
+		l.errors = append(l.errors, e)
+		return
+	}
+
+	l.errors[l.next] = e
+	l.next = (l.next + 1) % l.size
+}
+
+// recent returns the recorded errors, newest first
+func (l *errorLog) recent() []APIError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := make([]APIError, 0, len(l.errors))
+	for i := len(l.errors) - 1; i >= 0; i-- {
+		recent = append(recent, l.errors[(l.next+i)%len(l.errors)])
+	}
+
+	return recent
+}
+
+// RecentErrors returns the most recent failed API requests, newest first
+func (c *Client) RecentErrors() []APIError {
+	return c.errors.recent()
+}
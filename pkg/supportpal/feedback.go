@@ -0,0 +1,61 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Feedback represents a satisfaction rating left by a user on a ticket
+type Feedback struct {
+	ID       int     `json:"id"`
+	TicketID int     `json:"ticket_id"`
+	Rating   float64 `json:"rating"`
+	Operator struct {
+		FormattedName string `json:"formatted_name"`
+	} `json:"operator"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+// ListFeedbackResponse represents the response body for listing feedback
+type ListFeedbackResponse struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Count   int         `json:"count"`
+	Data    []*Feedback `json:"data"`
+}
+
+// ListFeedback lists the ticket feedback created at or after since, newest first
+func (c *Client) ListFeedback(since int64) ([]*Feedback, error) {
+	var feedback []*Feedback
+	start := 0
+	limit := 100
+	for {
+		url := "/api/ticket/feedback?order_direction=desc&start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+		resp, err := c.request("GET", url, nil, c.config.ListTimeout)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page ListFeedbackResponse
+		err = json.Unmarshal(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range page.Data {
+			if f.CreatedAt < since {
+				return feedback, nil
+			}
+			feedback = append(feedback, f)
+		}
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return feedback, nil
+}
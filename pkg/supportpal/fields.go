@@ -0,0 +1,62 @@
+package supportpal
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ticketFields are the ticket fields requested when field selection is
+// enabled, the fields decoded into Ticket
+var ticketFields = []string{
+	"id", "subject", "channel", "status", "priority", "department", "user",
+	"tags", "assigned", "due_time", "created_at", "updated_at", "deleted_at",
+	"resolved_time", "operator_url", "frontend_url", "customfields",
+}
+
+// ticketRelations are the relations of a ticket loaded with it when field
+// selection is enabled
+var ticketRelations = []string{"status", "priority", "department", "user", "tags", "assigned", "customfields"}
+
+// selectFields adds the field selection parameters to the ticket request url
+// unless field selection is disabled or was rejected by the API
+func (c *Client) selectFields(url string) string {
+	if !c.config.SelectFields || c.fieldsRejected.Load() {
+		return url
+	}
+
+	sep := "&"
+	if !strings.Contains(url, "?") {
+		sep = "?"
+	}
+
+	return url + sep + fieldSelection().Encode()
+}
+
+// fieldSelection returns the query parameters restricting ticket responses
+// to ticketFields
+func fieldSelection() url.Values {
+	return url.Values{
+		"fields": {strings.Join(ticketFields, ",")},
+		"with":   {strings.Join(ticketRelations, ",")},
+	}
+}
+
+// rejectedSelection reports whether err is the API rejecting the field
+// selection parameters of a request that was sent with them, in which case
+// field selection is disabled for the lifetime of the client
+func (c *Client) rejectedSelection(selected bool, err error) bool {
+	var statusErr *StatusError
+	if !selected || !errors.As(err, &statusErr) {
+		return false
+	}
+	if statusErr.Code != http.StatusBadRequest && statusErr.Code != http.StatusUnprocessableEntity {
+		return false
+	}
+
+	if !c.fieldsRejected.Swap(true) {
+		c.config.Logger.Warn("API rejected field selection, requesting full tickets", "status", statusErr.Status)
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package supportpal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzDecodeTickets(f *testing.F) {
+	f.Add([]byte(`{"status":"success","count":1,"data":[{"id":1,"subject":"Hello","customfields":[{"id":1,"field_id":2,"value":"3"}]}]}`))
+	f.Add([]byte(`{"status":"success","count":2,"data":[null,{"id":2,"customfields":[null,{"value":[1,2]}]}]}`))
+	f.Add([]byte(`{"status":"success","data":[{"id":1,"customfields":[{"value":{"a":1}},{"value":null},{"value":1.5}]}]}`))
+	f.Add([]byte(`{"status":"success","count":1,"data":[{"id":1,"subj`))
+	f.Add([]byte(``))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		resp, err := decodeTickets(bytes.NewReader(data), nil)
+		if err != nil {
+			return
+		}
+
+		for _, ticket := range resp.Data {
+			if ticket == nil {
+				t.Fatal("decoded nil ticket")
+			}
+			for _, field := range ticket.CustomFields {
+				if field == nil {
+					t.Fatalf("ticket %d: decoded nil custom field", ticket.ID)
+				}
+			}
+		}
+	})
+}
+
+func FuzzDecodeOrganization(f *testing.F) {
+	f.Add([]byte(`{"status":"success","data":{"id":1,"name":"ACME"}}`))
+	f.Add([]byte(`{"status":"success","data":null}`))
+	f.Add([]byte(`{"status":"error","message":"Not found"}`))
+	f.Add([]byte(`{"status":"success","data":{"id":1,"na`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		resp, err := decodeOrganization(data)
+		if err != nil {
+			return
+		}
+
+		if resp.Data == nil {
+			t.Fatal("decoded organization response without organization")
+		}
+	})
+}
+
+func FuzzDecodeCustomField(f *testing.F) {
+	f.Add([]byte(`{"status":"success","data":{"id":1,"name":"Area","type":7,"options":[{"id":7,"value":"Web"}]}}`))
+	f.Add([]byte(`{"status":"success","data":null}`))
+	f.Add([]byte(`{"status":"success","data":{"options":[null]}}`))
+	f.Add([]byte(`{"status":"success","data":{"id":"1"}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeCustomField(data)
+	})
+}
@@ -0,0 +1,50 @@
+package supportpal
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	supportPalAPIPageSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "supportpal_api_page_size",
+		Help: "Number of tickets requested per page, configured and effective after pages were shrunk because of failed requests",
+	}, []string{"setting"})
+
+	supportPalAPIMaxConcurrency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_api_max_concurrency",
+		Help: "Maximum number of API requests a collection cycle sends at a time, lookups of webhook updates are sent alongside",
+	})
+
+	supportPalAPIRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_api_requests_in_flight",
+		Help: "Number of API requests currently sent",
+	})
+
+	supportPalAPIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_api_requests_total",
+		Help: "Number of API requests sent by response status code, error for requests without response",
+	}, []string{"code"})
+
+	supportPalPagesFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "supportpal_pages_failed_total",
+		Help: "Number of ticket pages skipped because they failed after all retries",
+	})
+)
+
+// setPageSize sets the number of tickets requested per page
+func (c *Client) setPageSize(size int) {
+	c.pageSize.Store(int64(size))
+	supportPalAPIPageSize.WithLabelValues("effective").Set(float64(size))
+}
+
+// countRequest counts a completed request by its status code, 0 for a
+// request without response
+func countRequest(code int) {
+	if code == 0 {
+		supportPalAPIRequests.WithLabelValues("error").Inc()
+		return
+	}
+	supportPalAPIRequests.WithLabelValues(strconv.Itoa(code)).Inc()
+}
@@ -0,0 +1,94 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Message authors
+const (
+	ByOperator = 0
+	ByUser     = 1
+)
+
+// Message types
+const (
+	MessageReply        = 0
+	MessageInternalNote = 1
+)
+
+// Message represents a ticket message
+type Message struct {
+	ID       int `json:"id"`
+	TicketID int `json:"ticket_id"`
+	UserID   int `json:"user_id"`
+	User     struct {
+		FormattedName string `json:"formatted_name"`
+	} `json:"user"`
+	By          int           `json:"by"`
+	Type        int           `json:"type"`
+	CreatedAt   int64         `json:"created_at"`
+	Attachments []*Attachment `json:"attachments"`
+}
+
+// Attachment represents a file attached to a ticket message
+type Attachment struct {
+	ID           int    `json:"id"`
+	OriginalName string `json:"original_name"`
+}
+
+// ListMessagesResponse represents the response body for listing ticket messages
+type ListMessagesResponse struct {
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	Count   int        `json:"count"`
+	Data    []*Message `json:"data"`
+}
+
+// messageKey identifies the messages of a ticket at a given update time
+type messageKey struct {
+	ticketID  int
+	updatedAt int64
+}
+
+// TicketMessages returns the messages of ticket, oldest first. They are
+// cached until the ticket is updated, so the messages of unchanged tickets
+// are not fetched again. Tickets without update time are always fetched.
+func (c *Client) TicketMessages(ticket *Ticket) ([]*Message, error) {
+	if c.messages == nil || ticket.UpdatedAt == 0 {
+		return c.ListMessages(ticket.ID)
+	}
+
+	key := messageKey{ticketID: ticket.ID, updatedAt: ticket.UpdatedAt}
+	if messages, ok := c.messages.Get(key); ok {
+		return messages, nil
+	}
+
+	messages, err := c.ListMessages(ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.messages.Set(key, messages)
+	return messages, nil
+}
+
+// ListMessages is a helper function to list the messages of a ticket, oldest first
+func (c *Client) ListMessages(ticketID int) ([]*Message, error) {
+	url := "/api/ticket/message?ticket_id=" + strconv.Itoa(ticketID) + "&order_column=created_at&order_direction=asc"
+	resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	checkResponseDrift[Message](c.drift, "message", resp)
+
+	var messages ListMessagesResponse
+	err = json.Unmarshal(resp, &messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages.Data, nil
+}
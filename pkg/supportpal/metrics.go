@@ -0,0 +1,29 @@
+package supportpal
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Collectors returns the metrics of the API clients of the package, e.g.
+// request durations, failures and cache hits. They are shared by all clients
+// and not registered anywhere, register them into the registry serving them:
+//
+//	registry.MustRegister(supportpal.Collectors()...)
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		supportPalAPIRequestDuration,
+		supportPalAPIRequestFailures,
+		supportPalAPIAvailability,
+		supportPalCacheHits,
+		supportPalCacheMisses,
+		supportPalSharedCacheRequests,
+		supportPalAPIResponseBytes,
+		supportPalAPIDeprecationWarning,
+		supportPalSchemaDrift,
+		supportPalAPIPageSize,
+		supportPalAPIMaxConcurrency,
+		supportPalAPIRequestsInFlight,
+		supportPalAPIRequests,
+		supportPalPagesFailed,
+		supportPalNotFound,
+		supportPalAPIPacingInterval,
+	}
+}
@@ -0,0 +1,26 @@
+package supportpal
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrNotFound is wrapped by the errors of lookups for deleted entities
+var ErrNotFound = errors.New("not found")
+
+var supportPalNotFound = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_enrichment_not_found_total",
+	Help: "Number of enrichment lookups answered with not found, e.g. for deleted organizations",
+}, []string{"kind"})
+
+// isNotFound reports whether err means the requested entity does not exist
+func isNotFound(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusNotFound
+	}
+
+	return errors.Is(err, ErrNotFound)
+}
@@ -0,0 +1,91 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Organization represents an organization
+type Organization struct {
+	ID           int                  `json:"id"`
+	Name         string               `json:"name"`
+	CustomFields []*OrganizationField `json:"customfields"`
+}
+
+// OrganizationField is the value of an organization custom field
+type OrganizationField struct {
+	FieldID int        `json:"field_id"`
+	Value   FieldValue `json:"value"`
+}
+
+// Field returns the value of the custom field with the given ID and whether
+// the organization has it
+func (o *Organization) Field(id int) (string, bool) {
+	for _, field := range o.CustomFields {
+		if field != nil && field.FieldID == id {
+			return string(field.Value), true
+		}
+	}
+
+	return "", false
+}
+
+// GetOrganizationResponse represents the response body for getting an organization
+type GetOrganizationResponse struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message"`
+	Data    *Organization `json:"data"`
+}
+
+// GetOrganization is a helper function to get an organization. It returns an
+// error wrapping ErrNotFound if the organization was deleted.
+func (c *Client) GetOrganization(id int) (*GetOrganizationResponse, error) {
+	if org, found := c.organizations.Get(id); found {
+		if org == nil {
+			return nil, fmt.Errorf("organization %d: %w", id, ErrNotFound)
+		}
+
+		return &GetOrganizationResponse{
+			Status:  "success",
+			Message: "",
+			Data:    org,
+		}, nil
+	}
+
+	url := "/api/user/organisation/" + strconv.Itoa(id)
+	resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+	if err == nil {
+		checkResponseDrift[Organization](c.drift, "organization", resp)
+
+		var organization *GetOrganizationResponse
+		if organization, err = decodeOrganization(resp); err == nil {
+			c.organizations.Set(id, organization.Data)
+			return organization, nil
+		}
+	}
+
+	if isNotFound(err) {
+		c.organizations.Set(id, nil)
+		supportPalNotFound.WithLabelValues("organization").Inc()
+		return nil, fmt.Errorf("organization %d: %w", id, ErrNotFound)
+	}
+
+	return nil, err
+}
+
+// decodeOrganization decodes the response body of an organization request.
+// Responses without organization, e.g. for deleted organizations, wrap ErrNotFound.
+func decodeOrganization(data []byte) (*GetOrganizationResponse, error) {
+	var organization GetOrganizationResponse
+	if err := json.Unmarshal(data, &organization); err != nil {
+		return nil, err
+	}
+
+	if organization.Data == nil {
+		return nil, fmt.Errorf("organization response holds no organization: %w", ErrNotFound)
+	}
+
+	return &organization, nil
+}
@@ -0,0 +1,76 @@
+package supportpal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalAPIPacingInterval = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "supportpal_api_pacing_interval_seconds",
+	Help: "Time between the starts of the API requests of the current paced collection cycle, 0 while requests are not paced",
+})
+
+// pacer spreads the requests of a collection cycle over a window, one slot
+// per request of the previous cycle
+type pacer struct {
+	mu sync.Mutex
+	// active is set between the calls of Pace starting and ending a cycle
+	active bool
+	// sent counts the requests of the current cycle, previous those of the
+	// last complete one
+	sent, previous int
+	// gap is the time between two slots, 0 sends requests immediately
+	gap time.Duration
+	// next is the start of the next free slot, requests after end are sent
+	// immediately
+	next, end time.Time
+}
+
+// Pace spreads the requests sent until the next call of Pace evenly over
+// window from now, e.g. over most of the interval of a collection cycle, so
+// they do not reach the API as a burst at the start of the cycle. The
+// requests are spaced by window divided by the number of requests of the
+// previous paced cycle, the first cycle is not paced. Requests beyond that
+// number are sent once window passed. 0 ends the cycle and sends requests
+// immediately again.
+func (c *Client) Pace(window time.Duration) {
+	p := &c.pacer
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active {
+		p.previous = p.sent
+	}
+	p.active = window > 0
+	p.sent = 0
+	p.gap = 0
+	if p.active && p.previous > 0 {
+		p.gap = window / time.Duration(p.previous)
+	}
+	p.next = time.Now()
+	p.end = p.next.Add(window)
+	supportPalAPIPacingInterval.Set(p.gap.Seconds())
+}
+
+// wait blocks until the slot of a request of the current cycle
+func (p *pacer) wait() {
+	p.mu.Lock()
+	if !p.active {
+		p.mu.Unlock()
+		return
+	}
+	p.sent++
+	slot := p.next
+	if p.gap > 0 && slot.Before(p.end) {
+		// slots are kept relative to the start of the cycle, so slow
+		// requests do not push the later ones beyond the window
+		p.next = p.next.Add(p.gap)
+	}
+	p.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,109 @@
+package supportpal
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// totalCountHeaders are the response headers some SupportPal versions and
+// proxies in front of them report the total of a list in instead of count
+var totalCountHeaders = []string{"X-Total-Count", "X-Pagination-Total-Count"}
+
+// maxPages bounds the page requests of a ticket list, so a list whose end is
+// never reported cannot keep a cycle listing forever
+const maxPages = 100000
+
+// applyPaginationHeaders sets the total of a list response from its headers
+// when the body holds none, and the links of the Link header
+func applyPaginationHeaders(tickets *ListTicketsResponse, header http.Header) {
+	if tickets.Count == 0 {
+		for _, name := range totalCountHeaders {
+			if total, err := strconv.Atoi(strings.TrimSpace(header.Get(name))); err == nil && total > 0 {
+				tickets.Count = total
+				break
+			}
+		}
+	}
+
+	if link := header.Values("Link"); len(link) > 0 {
+		tickets.Links = parseLinks(strings.Join(link, ","))
+	}
+}
+
+// parseLinks returns the URLs of a Link header by relation, e.g.
+// <https://host/api/ticket/ticket?start=100>; rel="next"
+func parseLinks(header string) map[string]string {
+	links := make(map[string]string)
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(name, "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				links[strings.ToLower(rel)] = target
+			}
+		}
+	}
+
+	return links
+}
+
+// linkPath returns the path relative to the base URL of a link, which may be
+// relative itself. Links outside of the base URL are not followed, so the
+// credentials are never sent to another host, and yield an empty path.
+func (c *Client) linkPath(link string) string {
+	if link == "" {
+		return ""
+	}
+
+	base, err := url.Parse(c.config.BaseURL + "/")
+	if err != nil {
+		return ""
+	}
+	target, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	path, ok := strings.CutPrefix(base.ResolveReference(target).String(), c.config.BaseURL)
+	if !ok || !strings.HasPrefix(path, "/") {
+		c.config.Logger.Warn("ignoring next link outside of the base URL", "link", link)
+		return ""
+	}
+	return path
+}
+
+// lastPage reports whether the page of a list starting at start is its last
+// one. The Link header decides when the response carries one, otherwise the
+// total, and without a total a page shorter than limit is the last.
+func lastPage(tickets *ListTicketsResponse, start, limit int) bool {
+	switch {
+	case len(tickets.Data) == 0:
+		return true
+	case tickets.Links != nil:
+		return tickets.Links["next"] == ""
+	case tickets.Count > 0:
+		return tickets.Count <= start+len(tickets.Data)
+	default:
+		return len(tickets.Data) < limit
+	}
+}
+
+// repeatedPage reports whether page holds the same tickets as the previous
+// page, as returned by APIs ignoring the start of a request
+func repeatedPage(previous, page []*Ticket) bool {
+	if len(previous) == 0 || len(previous) != len(page) {
+		return false
+	}
+
+	return previous[0].ID == page[0].ID && previous[len(previous)-1].ID == page[len(page)-1].ID
+}
@@ -0,0 +1,46 @@
+package supportpal
+
+import "sync"
+
+// prefetch looks up the organizations and custom fields of tickets that are
+// not cached yet, with up to EnrichmentConcurrency requests at a time, so
+// the lookups made for every ticket are answered from the caches. Failed
+// lookups are not cached and left to those lookups, which report them.
+func (c *Client) prefetch(tickets []*Ticket) {
+	var orgIDs, fieldIDs []int
+	seen := make(map[[2]int]bool)
+	for _, ticket := range tickets {
+		if id := ticket.User.OrganizationID; id != 0 && !seen[[2]int{0, id}] && !c.organizations.contains(id) {
+			seen[[2]int{0, id}] = true
+			orgIDs = append(orgIDs, id)
+		}
+
+		for _, field := range ticket.CustomFields {
+			if id := field.FieldID; !seen[[2]int{1, id}] && !c.customFields.contains(id) {
+				seen[[2]int{1, id}] = true
+				fieldIDs = append(fieldIDs, id)
+			}
+		}
+	}
+
+	lookups := make(chan func(), len(orgIDs)+len(fieldIDs))
+	for _, id := range orgIDs {
+		lookups <- func() { _, _ = c.GetOrganization(id) }
+	}
+	for _, id := range fieldIDs {
+		lookups <- func() { _, _ = c.GetCustomField(id) }
+	}
+	close(lookups)
+
+	var wg sync.WaitGroup
+	for range min(c.config.EnrichmentConcurrency, len(lookups)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lookup := range lookups {
+				lookup()
+			}
+		}()
+	}
+	wg.Wait()
+}
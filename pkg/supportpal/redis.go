@@ -0,0 +1,291 @@
+package supportpal
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// redisIdleConns is the number of idle connections a RedisStore keeps
+const redisIdleConns = 8
+
+// redisRetryAfter is the time commands fail without connecting after a
+// connection failed, so an unreachable server does not slow down every lookup
+const redisRetryAfter = 10 * time.Second
+
+// RedisStore is a SharedStore in Redis or a server speaking its protocol,
+// such as Valkey. Keys are prefixed with Prefix, so installations can share
+// a server.
+type RedisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	// Prefix is prepended to every key
+	Prefix string
+	// Timeout bounds every command including connecting
+	Timeout time.Duration
+	idle    chan *redisConn
+	// downUntil is the Unix time in nanoseconds until which commands fail
+	// without connecting
+	downUntil atomic.Int64
+}
+
+// redisConn is a connection to Redis with its reply reader
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedisStore returns a store for a Redis URL such as
+// redis://:password@host:6379/0, rediss:// connects with TLS. No connection
+// is made until the store is used. Errors do not include the URL, which may
+// hold the password.
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.New("redis URL: invalid URL")
+	}
+
+	s := &RedisStore{addr: u.Host, Timeout: time.Second, idle: make(chan *redisConn, redisIdleConns)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		s.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("redis URL: unsupported scheme %q, expected redis or rediss", u.Scheme)
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis URL: invalid database %q", db)
+		}
+	}
+
+	return s, nil
+}
+
+// Get implements SharedStore
+func (s *RedisStore) Get(key string) ([]byte, bool, error) {
+	reply, err := s.do("GET", s.Prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis GET: unexpected reply %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set implements SharedStore
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", s.Prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+
+	_, err := s.do(args...)
+	return err
+}
+
+// leaseScript takes the lease of KEYS[1] for the owner ARGV[1] for ARGV[2]
+// milliseconds if it is free or renews it if the owner holds it already
+const leaseScript = `local holder = redis.call("GET", KEYS[1])
+if holder and holder ~= ARGV[1] then return 0 end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1`
+
+// Lease takes the lease of key for owner, or renews it if owner holds it
+// already, and reports whether owner holds it for ttl from now. Taking and
+// renewing is atomic, so at most one owner holds a lease at a time.
+func (s *RedisStore) Lease(key, owner string, ttl time.Duration) (bool, error) {
+	reply, err := s.do("EVAL", leaseScript, "1", s.Prefix+key, owner, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+
+	held, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("redis EVAL: unexpected reply %v", reply)
+	}
+	return held == 1, nil
+}
+
+// releaseScript deletes KEYS[1] if the owner ARGV[1] holds it
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end
+return 0`
+
+// Release gives up the lease of key if owner holds it, so another owner can
+// take it before it expires
+func (s *RedisStore) Release(key, owner string) error {
+	_, err := s.do("EVAL", releaseScript, "1", s.Prefix+key, owner)
+	return err
+}
+
+// Ping checks that the server is reachable and accepts the credentials
+func (s *RedisStore) Ping() error {
+	_, err := s.do("PING")
+	return err
+}
+
+// do sends a command on an idle or new connection and returns its reply. A
+// connection is reused unless the command failed on it.
+func (s *RedisStore) do(args ...string) (any, error) {
+	conn, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.command(s.Timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case s.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+
+	return reply, err
+}
+
+// conn returns an idle connection or connects, authenticates and selects
+// the database
+func (s *RedisStore) conn() (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+
+	if time.Now().UnixNano() < s.downUntil.Load() {
+		return nil, errors.New("redis: unreachable, retrying later")
+	}
+
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		s.downUntil.Store(time.Now().Add(redisRetryAfter).UnixNano())
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	var setup [][]string
+	switch {
+	case s.username != "":
+		setup = append(setup, []string{"AUTH", s.username, s.password})
+	case s.password != "":
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.command(s.Timeout, args...); err != nil {
+			conn.Close()
+			// wrong credentials or database fail the same way on retry
+			s.downUntil.Store(time.Now().Add(redisRetryAfter).UnixNano())
+			return nil, fmt.Errorf("redis %s: %w", args[0], err)
+		}
+	}
+
+	return c, nil
+}
+
+// redisError is an error reply of the server, the connection stays usable
+type redisError string
+
+// Error implements error
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// command writes a command as array of bulk strings and reads its reply
+func (c *redisConn) command(timeout time.Duration, args ...string) (any, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	return c.reply()
+}
+
+// reply reads a reply: a string, an integer, a bulk string as []byte, nil
+// for a missing value or an array of replies
+func (c *redisConn) reply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		replies := make([]any, n)
+		for i := range replies {
+			if replies[i], err = c.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return replies, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
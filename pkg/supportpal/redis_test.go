@@ -0,0 +1,223 @@
+package supportpal
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves GET, SET, AUTH, SELECT of the databases 0 to 9, PING and
+// the scripts of the leases from a map. Keys of a database other than 0 are
+// stored prefixed with it.
+type fakeRedis struct {
+	addr   string
+	values *sync.Map
+	// conns is the number of accepted connections
+	conns atomic.Int32
+	// mu serializes the scripts, as Redis runs them atomically
+	mu sync.Mutex
+}
+
+// newFakeRedis starts a fakeRedis, commands are parsed like replies as they
+// share the encoding
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	redis := &fakeRedis{addr: listener.Addr().String(), values: &sync.Map{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			redis.conns.Add(1)
+			go redis.serve(conn)
+		}
+	}()
+
+	return redis
+}
+
+// serve answers the commands of a connection
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	db := "0"
+	key := func(name []byte) string {
+		if db == "0" {
+			return string(name)
+		}
+		return db + "/" + string(name)
+	}
+
+	for {
+		command, err := c.reply()
+		if err != nil {
+			return
+		}
+		args := command.([]any)
+		switch string(args[0].([]byte)) {
+		case "GET":
+			if value, ok := f.values.Load(key(args[1].([]byte))); ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value.([]byte)), value)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		case "SET":
+			f.values.Store(key(args[1].([]byte)), args[2].([]byte))
+			fmt.Fprint(conn, "+OK\r\n")
+		case "AUTH":
+			if string(args[len(args)-1].([]byte)) != "secret" {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			fmt.Fprint(conn, "+OK\r\n")
+		case "SELECT":
+			if len(args[1].([]byte)) > 1 {
+				fmt.Fprint(conn, "-ERR DB index is out of range\r\n")
+				continue
+			}
+			db = string(args[1].([]byte))
+			fmt.Fprint(conn, "+OK\r\n")
+		case "EVAL":
+			fmt.Fprintf(conn, ":%d\r\n", f.eval(string(args[1].([]byte)), key(args[3].([]byte)), string(args[4].([]byte))))
+		default:
+			fmt.Fprint(conn, "+PONG\r\n")
+		}
+	}
+}
+
+// eval runs the lease or release script for the key and owner
+func (f *fakeRedis) eval(script, key, owner string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	holder, held := f.values.Load(key)
+	switch script {
+	case leaseScript:
+		if held && string(holder.([]byte)) != owner {
+			return 0
+		}
+		f.values.Store(key, []byte(owner))
+		return 1
+	case releaseScript:
+		if !held || string(holder.([]byte)) != owner {
+			return 0
+		}
+		f.values.Delete(key)
+		return 1
+	default:
+		return -1
+	}
+}
+
+func TestRedisReply(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  any
+		err   bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{"-ERR unknown command\r\n", nil, true},
+		{":42\r\n", int64(42), false},
+		{"$5\r\nhello\r\n", []byte("hello"), false},
+		{"$0\r\n\r\n", []byte{}, false},
+		{"$-1\r\n", nil, false},
+		{"*2\r\n$1\r\na\r\n:1\r\n", []any{[]byte("a"), int64(1)}, false},
+		{"*0\r\n", []any{}, false},
+		{"$5\r\nhel", nil, true},
+		{"?\r\n", nil, true},
+		{"\r\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		c := &redisConn{r: bufio.NewReader(strings.NewReader(tt.reply))}
+		got, err := c.reply()
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("reply(%q) = %#v, %v, want %#v", tt.reply, got, err, tt.want)
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	redis := newFakeRedis(t)
+	store, err := NewRedisStore("redis://user:secret@" + redis.addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Prefix = "test:"
+
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Errorf("Get() of a missing key = %v, %v", ok, err)
+	}
+	if err := store.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := store.Get("key"); !ok || err != nil || string(value) != "value" {
+		t.Errorf("Get() = %q, %v, %v", value, ok, err)
+	}
+	if _, ok := redis.values.Load("2/test:key"); !ok {
+		t.Error("value not stored under the prefixed key of the selected database")
+	}
+
+	// at most one owner holds the lease, until it releases it
+	for _, step := range []struct {
+		owner string
+		held  bool
+	}{{"a", true}, {"b", false}, {"a", true}} {
+		if held, err := store.Lease("leader", step.owner, time.Minute); err != nil || held != step.held {
+			t.Errorf("Lease(%s) = %v, %v, want %v", step.owner, held, err, step.held)
+		}
+	}
+	if err := store.Release("leader", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := store.Lease("leader", "b", time.Minute); held {
+		t.Error("lease released by another owner")
+	}
+	if err := store.Release("leader", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := store.Lease("leader", "b", time.Minute); err != nil || !held {
+		t.Errorf("Lease(b) after the release = %v, %v", held, err)
+	}
+
+	// the connection is reused by the commands in sequence
+	if n := redis.conns.Load(); n != 1 {
+		t.Errorf("%d connections for sequential commands, want 1", n)
+	}
+}
+
+func TestRedisStoreSetupBackoff(t *testing.T) {
+	redis := newFakeRedis(t)
+	for _, url := range []string{"redis://:wrong@" + redis.addr, "redis://:secret@" + redis.addr + "/99"} {
+		store, err := NewRedisStore(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conns := redis.conns.Load()
+		if err := store.Ping(); err == nil {
+			t.Fatalf("%s: Ping() succeeded", url)
+		}
+		if err := store.Ping(); err == nil || !strings.Contains(err.Error(), "retrying later") {
+			t.Errorf("%s: second Ping() = %v, want to fail without connecting", url, err)
+		}
+		if n := redis.conns.Load() - conns; n != 1 {
+			t.Errorf("%s: %d connections, want 1 until the retry", url, n)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package supportpal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Shard is the part of the tickets an instance collects when the tickets are
+// split across several instances by ticket ID. The zero Shard holds all
+// tickets.
+type Shard struct {
+	// Index is the 1-based number of the shard
+	Index int
+	// Count is the number of shards, 0 or 1 for a single instance
+	Count int
+}
+
+// ParseShard parses a shard written as index/count, e.g. 2/4. The empty
+// string is the zero Shard.
+func ParseShard(s string) (Shard, error) {
+	if s == "" {
+		return Shard{}, nil
+	}
+
+	index, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("shard %q: expected index/count, e.g. 2/4", s)
+	}
+
+	var shard Shard
+	var err error
+	if shard.Index, err = strconv.Atoi(index); err != nil {
+		return Shard{}, fmt.Errorf("shard %q: invalid index: %w", s, err)
+	}
+	if shard.Count, err = strconv.Atoi(count); err != nil {
+		return Shard{}, fmt.Errorf("shard %q: invalid count: %w", s, err)
+	}
+	if shard.Count < 1 || shard.Index < 1 || shard.Index > shard.Count {
+		return Shard{}, fmt.Errorf("shard %q: the index must be between 1 and the count", s)
+	}
+
+	return shard, nil
+}
+
+// String returns the shard as index/count, empty for the zero Shard
+func (s Shard) String() string {
+	if s.Count <= 1 {
+		return ""
+	}
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+}
+
+// Contains reports whether the ticket with the given ID belongs to the shard
+func (s Shard) Contains(id int) bool {
+	return s.Count <= 1 || id%s.Count == s.Index-1
+}
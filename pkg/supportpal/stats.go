@@ -0,0 +1,81 @@
+package supportpal
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// pagesListed is the number of ticket pages listed by all clients
+var pagesListed atomic.Int64
+
+// Stats are the totals of the ticket pages, API requests and cache lookups
+// of all clients since the start of the process. The difference of two Stats
+// describes the requests in between.
+type Stats struct {
+	Pages int64 `json:"pages"`
+	// Requests are the API requests by response status code, error for
+	// requests without response
+	Requests map[string]float64 `json:"requests"`
+	// CacheHits and CacheMisses are the lookups by cache name
+	CacheHits   map[string]float64 `json:"cache_hits"`
+	CacheMisses map[string]float64 `json:"cache_misses"`
+}
+
+// ReadStats returns the current totals
+func ReadStats() Stats {
+	return Stats{
+		Pages:       pagesListed.Load(),
+		Requests:    counterValues(supportPalAPIRequests),
+		CacheHits:   counterValues(supportPalCacheHits),
+		CacheMisses: counterValues(supportPalCacheMisses),
+	}
+}
+
+// Sub returns the totals counted since earlier
+func (s Stats) Sub(earlier Stats) Stats {
+	sub := func(now, before map[string]float64) map[string]float64 {
+		diff := make(map[string]float64, len(now))
+		for key, v := range now {
+			if d := v - before[key]; d != 0 {
+				diff[key] = d
+			}
+		}
+		return diff
+	}
+
+	return Stats{
+		Pages:       s.Pages - earlier.Pages,
+		Requests:    sub(s.Requests, earlier.Requests),
+		CacheHits:   sub(s.CacheHits, earlier.CacheHits),
+		CacheMisses: sub(s.CacheMisses, earlier.CacheMisses),
+	}
+}
+
+// counterValues returns the values of the series of vec by their label
+// values, joined by commas
+func counterValues(vec *prometheus.CounterVec) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+
+		labels := make([]string, len(m.Label))
+		for i, pair := range m.Label {
+			labels[i] = pair.GetValue()
+		}
+		values[strings.Join(labels, ",")] = m.GetCounter().GetValue()
+	}
+
+	return values
+}
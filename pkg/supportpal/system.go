@@ -0,0 +1,69 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// SystemInfo holds the version and scheduler state of the installation as
+// read from the core settings
+type SystemInfo struct {
+	Version string
+	// CronLastRun is the last time the scheduled tasks ran, 0 if unknown
+	CronLastRun int64
+}
+
+// GetSettingsResponse represents the response body for the core settings
+type GetSettingsResponse struct {
+	Status  string                     `json:"status"`
+	Message string                     `json:"message"`
+	Data    map[string]json.RawMessage `json:"data"`
+}
+
+// GetSystemInfo reads the version and the last cron run from the core
+// settings. Settings missing in the response are left empty.
+func (c *Client) GetSystemInfo() (*SystemInfo, error) {
+	resp, err := c.request("GET", "/api/core/settings", nil, c.config.EnrichmentTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings GetSettingsResponse
+	if err := json.Unmarshal(resp, &settings); err != nil {
+		return nil, err
+	}
+
+	info := &SystemInfo{
+		Version:     settingString(settings.Data["version"]),
+		CronLastRun: settingInt(settings.Data["cron_last_run"]),
+	}
+
+	return info, nil
+}
+
+// settingString returns a setting value as string, settings are strings or
+// numbers depending on the version
+func settingString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+
+	return ""
+}
+
+// settingInt returns a numeric setting value, 0 if it is missing or not a
+// number
+func settingInt(raw json.RawMessage) int64 {
+	n, err := strconv.ParseInt(settingString(raw), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
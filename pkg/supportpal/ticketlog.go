@@ -0,0 +1,66 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// TicketLogAssigned is the event of an operator being assigned to a ticket
+const TicketLogAssigned = "assigned"
+
+// TicketLog represents an entry of the audit log of a ticket
+type TicketLog struct {
+	ID       int    `json:"id"`
+	TicketID int    `json:"ticket_id"`
+	Event    string `json:"event"`
+	// OperatorID is the operator the event concerns, e.g. the assigned one
+	OperatorID int   `json:"operator_id"`
+	CreatedAt  int64 `json:"created_at"`
+}
+
+// ListTicketLogsResponse represents the response body for listing the audit
+// log of a ticket
+type ListTicketLogsResponse struct {
+	Status  string       `json:"status"`
+	Message string       `json:"message"`
+	Count   int          `json:"count"`
+	Data    []*TicketLog `json:"data"`
+}
+
+// TicketLogs returns the audit log of ticket, oldest first. Like the
+// messages it is cached until the ticket is updated.
+func (c *Client) TicketLogs(ticket *Ticket) ([]*TicketLog, error) {
+	if c.logs == nil || ticket.UpdatedAt == 0 {
+		return c.ListTicketLogs(ticket.ID)
+	}
+
+	key := messageKey{ticketID: ticket.ID, updatedAt: ticket.UpdatedAt}
+	if logs, ok := c.logs.Get(key); ok {
+		return logs, nil
+	}
+
+	logs, err := c.ListTicketLogs(ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logs.Set(key, logs)
+	return logs, nil
+}
+
+// ListTicketLogs is a helper function to list the audit log of a ticket,
+// oldest first
+func (c *Client) ListTicketLogs(ticketID int) ([]*TicketLog, error) {
+	url := "/api/ticket/log?ticket_id=" + strconv.Itoa(ticketID) + "&order_column=created_at&order_direction=asc"
+	resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs ListTicketLogsResponse
+	if err := json.Unmarshal(resp, &logs); err != nil {
+		return nil, err
+	}
+
+	return logs.Data, nil
+}
@@ -0,0 +1,482 @@
+package supportpal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Ticket represents the response from the API
+type Ticket struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Channel string `json:"channel"`
+	Status  struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"status"`
+	Priority struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"priority"`
+	Department struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"department"`
+	User struct {
+		ID             int    `json:"id"`
+		Email          string `json:"email"`
+		FormattedName  string `json:"formatted_name"`
+		OrganizationID int    `json:"organisation_id"`
+	}
+	Tags []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"tags"`
+	Assigned []struct {
+		ID            int    `json:"id"`
+		FormattedName string `json:"formatted_name"`
+	} `json:"assigned"`
+	DueTime      int64  `json:"due_time"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+	DeletedAt    int64  `json:"deleted_at"`
+	ResolvedTime int64  `json:"resolved_time"`
+	OperatorURL  string `json:"operator_url"`
+	FrontendURL  string `json:"frontend_url"`
+	CustomFields []*struct {
+		ID      int        `json:"id"`
+		FieldID int        `json:"field_id"`
+		Value   FieldValue `json:"value"`
+	} `json:"customfields"`
+}
+
+// FieldValue is the value of a ticket custom field. SupportPal returns
+// strings for most field types but numbers, booleans or arrays for others,
+// which are kept in their JSON form.
+type FieldValue string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *FieldValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = FieldValue(s)
+		return nil
+	}
+
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+
+	*v = FieldValue(data)
+	return nil
+}
+
+// ListTicketsResponse represents the response body for listing tickets
+type ListTicketsResponse struct {
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	Count   int       `json:"count"`
+	Data    []*Ticket `json:"data"`
+	// Links are the URLs of the Link header by relation, e.g. next, nil
+	// without Link header
+	Links map[string]string `json:"-"`
+}
+
+// SetTicketFilter replaces the query parameters added to ticket list
+// requests, e.g. when the statuses they select changed
+func (c *Client) SetTicketFilter(filter url.Values) {
+	c.ticketFilter.Store(&filter)
+}
+
+// ListTickets is a helper function to list tickets with start and limit
+func (c *Client) ListTickets(start, limit int) (*ListTicketsResponse, error) {
+	url := "/api/ticket/ticket?order_direction=desc&start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+	if c.config.OrderColumn != "" {
+		url += "&order_column=" + c.config.OrderColumn
+	}
+	if filter := *c.ticketFilter.Load(); len(filter) > 0 {
+		url += "&" + filter.Encode()
+	}
+
+	return c.listTickets(url)
+}
+
+// listTickets lists the page of tickets at url, a path relative to the base
+// URL. The fields are selected unless url selects them already, as the next
+// link of a page does.
+func (c *Client) listTickets(url string) (*ListTicketsResponse, error) {
+	var tickets *ListTicketsResponse
+	read := func(r io.Reader, header http.Header) (err error) {
+		if tickets, err = decodeTickets(r, c.drift); err == nil {
+			applyPaginationHeaders(tickets, header)
+		}
+		return err
+	}
+
+	selected := url
+	if !strings.Contains(url, "fields=") {
+		selected = c.selectFields(url)
+	}
+	err := c.stream("GET", selected, nil, c.config.ListTimeout, read)
+	if c.rejectedSelection(selected != url, err) {
+		err = c.stream("GET", url, nil, c.config.ListTimeout, read)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// decodeTickets decodes the response body of a ticket list request while it
+// is read, one ticket at a time, so a large page is never buffered as a
+// whole. Null tickets and custom fields are dropped so callers never see nil
+// entries.
+func decodeTickets(r io.Reader, drift *driftDetector) (*ListTicketsResponse, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var tickets ListTicketsResponse
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		// keys match case insensitively like with json.Unmarshal
+		key, _ := token.(string)
+		switch strings.ToLower(key) {
+		case "status":
+			err = dec.Decode(&tickets.Status)
+		case "message":
+			err = dec.Decode(&tickets.Message)
+		case "count":
+			err = dec.Decode(&tickets.Count)
+		case "meta":
+			// v2 reports the total in meta.total
+			var meta listMeta
+			if err = dec.Decode(&meta); err == nil && tickets.Count == 0 {
+				tickets.Count = meta.Total
+			}
+		case "data":
+			tickets.Data, err = decodeTicketArray(dec, drift)
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	return &tickets, nil
+}
+
+// decodeTicketArray decodes the ticket array of a list response, null
+// decodes as no tickets
+func decodeTicketArray(dec *json.Decoder, drift *driftDetector) ([]*Ticket, error) {
+	token, err := dec.Token()
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, fmt.Errorf("decoding tickets: unexpected %v, expected an array", token)
+	}
+
+	var tickets []*Ticket
+	for dec.More() {
+		var ticket *Ticket
+		if drift != nil {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			checkDrift[Ticket](drift, "ticket", raw)
+			if err := json.Unmarshal(raw, &ticket); err != nil {
+				return nil, err
+			}
+		} else if err := dec.Decode(&ticket); err != nil {
+			return nil, err
+		}
+		if ticket == nil {
+			continue
+		}
+
+		dropNullFields(ticket)
+		tickets = append(tickets, ticket)
+	}
+
+	return tickets, expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token of dec, which must be delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("decoding tickets: unexpected %v, expected %v", token, delim)
+	}
+
+	return nil
+}
+
+// dropNullFields removes null custom fields from ticket
+func dropNullFields(ticket *Ticket) {
+	fields := ticket.CustomFields[:0]
+	for _, field := range ticket.CustomFields {
+		if field != nil {
+			fields = append(fields, field)
+		}
+	}
+	ticket.CustomFields = fields
+}
+
+// GetTicketResponse represents the response body for getting a ticket
+type GetTicketResponse struct {
+	Status  string  `json:"status"`
+	Message string  `json:"message"`
+	Data    *Ticket `json:"data"`
+}
+
+// GetTicket is a helper function to get a single ticket. It returns an error
+// wrapping ErrNotFound if the ticket does not exist.
+func (c *Client) GetTicket(id int) (*Ticket, error) {
+	url := "/api/ticket/ticket/" + strconv.Itoa(id)
+	selected := c.selectFields(url)
+	resp, err := c.request("GET", selected, nil, c.config.EnrichmentTimeout)
+	if c.rejectedSelection(selected != url, err) {
+		resp, err = c.request("GET", url, nil, c.config.EnrichmentTimeout)
+	}
+
+	if isNotFound(err) {
+		return nil, fmt.Errorf("ticket %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checkResponseDrift[Ticket](c.drift, "ticket", resp)
+
+	var ticket GetTicketResponse
+	err = json.Unmarshal(resp, &ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.Data == nil {
+		return nil, fmt.Errorf("ticket %d: %w", id, ErrNotFound)
+	}
+	dropNullFields(ticket.Data)
+
+	return ticket.Data, nil
+}
+
+// FetchAllTickets is a helper function to fetch all tickets and return a slice of Ticket
+func (c *Client) FetchAllTickets() ([]*Ticket, error) {
+	return c.FetchTickets(nil)
+}
+
+// FetchTickets fetches tickets newest first while inBound returns true for
+// them. Pagination stops after the first page holding a ticket out of bound,
+// so old tickets are never fetched. A nil inBound fetches all tickets.
+func (c *Client) FetchTickets(inBound func(*Ticket) bool) ([]*Ticket, error) {
+	var tickets []*Ticket
+	err := c.StreamTickets(inBound, func(ticket *Ticket) {
+		tickets = append(tickets, ticket)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// PageError is returned by StreamTickets when pages failed after all retries
+// and were skipped, the tickets of the other pages were passed
+type PageError struct {
+	// Starts are the offsets of the skipped pages
+	Starts []int
+	// Err is the error of the last skipped page
+	Err error
+}
+
+// Error implements error
+func (e *PageError) Error() string {
+	return fmt.Sprintf("%d ticket pages skipped, last at start %d: %v", len(e.Starts), e.Starts[len(e.Starts)-1], e.Err)
+}
+
+// Unwrap returns the error of the last skipped page
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// StreamTickets fetches tickets like FetchTickets but passes them to fn page
+// by page instead of collecting them, so only one page is held in memory. A
+// page is passed once it was fetched completely, a page retried with a
+// smaller size never passes a ticket twice. With EnrichmentConcurrency above
+// 1 the organizations and custom fields of a page are looked up concurrently
+// before its tickets are passed.
+//
+// Tickets outside of the configured Shard are dropped. With MaxTickets
+// listing stops after that many tickets. Ordered by updated_at, tickets out
+// of bound are skipped but do not stop the pagination, as later pages may
+// hold tickets in bound.
+//
+// A page whose Link header has a next link is followed by the page of that
+// link, as installations paginating with cursors ignore the start of a
+// request, and the last page is the one without next link. Without Link
+// header the last page is the one reaching the total of the body or of the
+// X-Total-Count header, and without a total the first page that is not full.
+// Listing stops as well at an empty page, at a page repeating the previous
+// one and after maxPages page requests.
+//
+// A page failing at the smallest page size is retried PageRetries times.
+// If it still fails and the total is known from an earlier page, it is
+// skipped and counted in supportpal_pages_failed_total, and the remaining
+// pages are fetched; a *PageError reports the skipped pages at the end. On
+// other errors fn has already seen the tickets of the earlier pages.
+func (c *Client) StreamTickets(inBound func(*Ticket) bool, fn func(*Ticket)) error {
+	start, count, retries := 0, 0, 0
+	// the bound is by ID or creation time, which only orders by those follow
+	stopOutOfBound := c.config.OrderColumn != "updated_at"
+	var skipped *PageError
+	var previous []*Ticket
+	// next is the path of the next link of the previous page
+	next := ""
+	for requests := 0; ; requests++ {
+		if requests == maxPages {
+			return fmt.Errorf("sent %d ticket page requests without reaching the last page, stopped at start %d", requests, start)
+		}
+
+		limit := c.PageSize()
+		ticketsResponse, err := c.listPage(start, limit, next)
+
+		if err != nil {
+			// the retries change the page size, which the link fixes
+			next = ""
+			if !shrinkable(err) {
+				return err
+			}
+			if limit > minPageSize {
+				c.setPageSize(max(limit/2, minPageSize))
+				c.config.Logger.Warn("ticket page failed, retrying with a smaller page",
+					"start", start, "page_size", limit, "new_page_size", c.PageSize(), "err", err)
+				continue
+			}
+			if retries < c.config.PageRetries {
+				retries++
+				c.config.Logger.Warn("ticket page failed, retrying",
+					"start", start, "page_size", limit, "retry", retries, "err", err)
+				continue
+			}
+
+			// without the total of an earlier page the remaining pages are unknown
+			if count == 0 {
+				return err
+			}
+			supportPalPagesFailed.Inc()
+			c.config.Logger.Error("ticket page failed, skipping it",
+				"start", start, "page_size", limit, "retries", retries, "err", err)
+			if skipped == nil {
+				skipped = &PageError{}
+			}
+			skipped.Starts = append(skipped.Starts, start)
+			skipped.Err = err
+
+			retries = 0
+			start += limit
+			if count <= start || c.config.MaxTickets > 0 && start >= c.config.MaxTickets {
+				break
+			}
+			continue
+		}
+		retries = 0
+		count = ticketsResponse.Count
+
+		if repeatedPage(previous, ticketsResponse.Data) {
+			c.config.Logger.Warn("ticket page repeats the previous page, stopping the listing",
+				"start", start, "page_size", limit)
+			break
+		}
+		previous = ticketsResponse.Data
+
+		done := false
+		data := ticketsResponse.Data
+		if c.config.MaxTickets > 0 && start+len(data) >= c.config.MaxTickets {
+			data = data[:max(c.config.MaxTickets-start, 0)]
+			done = true
+		}
+
+		page := make([]*Ticket, 0, len(data))
+		for _, ticket := range data {
+			if inBound != nil && !inBound(ticket) {
+				done = done || stopOutOfBound
+				continue
+			}
+			if c.config.Shard.Contains(ticket.ID) {
+				page = append(page, ticket)
+			}
+		}
+
+		if c.config.EnrichmentConcurrency > 1 {
+			c.prefetch(page)
+		}
+		for _, ticket := range page {
+			fn(ticket)
+		}
+
+		if done || lastPage(ticketsResponse, start, limit) {
+			break
+		}
+
+		start += len(ticketsResponse.Data)
+		next = c.linkPath(ticketsResponse.Links["next"])
+	}
+
+	if skipped != nil {
+		return skipped
+	}
+	return nil
+}
+
+// shrinkable reports whether a failed page request may succeed with a
+// smaller page: timeouts, server errors and truncated or empty bodies.
+// Client errors such as failed authentication and connection or TLS
+// failures are not retried.
+func shrinkable(err error) bool {
+	// a smaller page does not help once the deadline passed
+	if errors.Is(err, ErrDeadline) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) || errors.As(err, &syntaxErr)
+}
@@ -0,0 +1,45 @@
+package supportpal
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// readTokenFile reads an API token from path, surrounding whitespace such as
+// the trailing newline of mounted secrets is dropped
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("token file " + path + " is empty")
+	}
+
+	return token, nil
+}
+
+// ReloadToken re-reads the token from the configured token file and reports
+// whether it changed. Without token file it does nothing.
+func (c *Client) ReloadToken() (bool, error) {
+	if c.config.TokenFile == "" {
+		return false, nil
+	}
+
+	token, err := readTokenFile(c.config.TokenFile)
+	if err != nil {
+		return false, err
+	}
+
+	return *c.token.Swap(&token) != token, nil
+}
+
+// isAuthError reports whether err is a rejected API token
+func isAuthError(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status) && (status.Code == http.StatusUnauthorized || status.Code == http.StatusForbidden)
+}
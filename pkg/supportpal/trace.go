@@ -0,0 +1,87 @@
+package supportpal
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tracer receives a span for every API request and ticket page of a Client
+// once it ended, e.g. to export them with OTLP. Request spans carry the
+// http.request.method attribute. Span must be safe for concurrent use.
+type Tracer interface {
+	Span(name string, start, end time.Time, attributes map[string]string, err error)
+}
+
+// trace reports a span that started at start and ended now to the tracer of
+// the client, if it has one
+func (c *Client) trace(name string, start time.Time, attributes map[string]string, err error) {
+	if c.config.Tracer == nil {
+		return
+	}
+
+	c.config.Tracer.Span(name, start, time.Now(), attributes, err)
+}
+
+// traceRequest reports the span of an API request, named after its method
+// and route with IDs replaced by {id} so requests of the same endpoint are
+// grouped. Status is 0 for requests without response.
+func (c *Client) traceRequest(method, path string, start time.Time, status int, err error) {
+	if c.config.Tracer == nil {
+		return
+	}
+
+	path, _, _ = strings.Cut(path, "?")
+	attributes := map[string]string{
+		"http.request.method": method,
+		"url.path":            path,
+		"server.address":      c.config.BaseURL,
+	}
+	if status != 0 {
+		attributes["http.response.status_code"] = strconv.Itoa(status)
+	}
+
+	c.trace(method+" "+route(path), start, attributes, err)
+}
+
+// listPage lists a page of tickets like ListTickets, or the page of the next
+// link of the previous page if not empty, counts it in the Stats and reports
+// its span
+func (c *Client) listPage(start, limit int, next string) (*ListTicketsResponse, error) {
+	pagesListed.Add(1)
+	list := func() (*ListTicketsResponse, error) {
+		if next != "" {
+			return c.listTickets(next)
+		}
+		return c.ListTickets(start, limit)
+	}
+	if c.config.Tracer == nil {
+		return list()
+	}
+
+	began := time.Now()
+	resp, err := list()
+
+	attributes := map[string]string{
+		"supportpal.page.start": strconv.Itoa(start),
+		"supportpal.page.limit": strconv.Itoa(limit),
+	}
+	if err == nil {
+		attributes["supportpal.page.tickets"] = strconv.Itoa(len(resp.Data))
+	}
+	c.trace("ticket page", began, attributes, err)
+
+	return resp, err
+}
+
+// route replaces the numeric segments of path by {id}
+func route(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
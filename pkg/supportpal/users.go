@@ -0,0 +1,82 @@
+package supportpal
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Operator represents an operator account
+type Operator struct {
+	ID            int    `json:"id"`
+	FormattedName string `json:"formatted_name"`
+	Active        int    `json:"active"`
+	LastActiveAt  int64  `json:"last_active_at"`
+	Groups        []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"groups"`
+}
+
+// ListUsersResponse represents the response body for listing users
+type ListUsersResponse struct {
+	Status  string            `json:"status"`
+	Message string            `json:"message"`
+	Count   int               `json:"count"`
+	Data    []json.RawMessage `json:"data"`
+}
+
+// ListOperatorsResponse represents the response body for listing operators
+type ListOperatorsResponse struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Count   int         `json:"count"`
+	Data    []*Operator `json:"data"`
+}
+
+// CountUsers returns the number of registered users
+func (c *Client) CountUsers() (int, error) {
+	resp, err := c.request("GET", "/api/user/user?start=0&limit=1", nil, c.config.EnrichmentTimeout)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var users ListUsersResponse
+	err = json.Unmarshal(resp, &users)
+	if err != nil {
+		return 0, err
+	}
+
+	return users.Count, nil
+}
+
+// ListOperators is a helper function to list all operators
+func (c *Client) ListOperators() ([]*Operator, error) {
+	var operators []*Operator
+	start := 0
+	limit := 100
+	for {
+		url := "/api/operator/operator?start=" + strconv.Itoa(start) + "&limit=" + strconv.Itoa(limit)
+		resp, err := c.request("GET", url, nil, c.config.EnrichmentTimeout)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page ListOperatorsResponse
+		err = json.Unmarshal(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		operators = append(operators, page.Data...)
+
+		if len(page.Data) == 0 || page.Count <= start+len(page.Data) {
+			break
+		}
+
+		start += len(page.Data)
+	}
+
+	return operators, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// primeInterval is the minimum time between two cache primings
+const primeInterval = time.Minute
+
+// primeResult describes a completed cache priming
+type primeResult struct {
+	Tickets         int     `json:"tickets"`
+	Organizations   int     `json:"organizations"`
+	CustomFields    int     `json:"custom_fields"`
+	Failed          int     `json:"failed"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// primer runs cache primings one at a time and at most once per primeInterval
+type primer struct {
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+}
+
+var cachePrimer = &primer{}
+
+// start reserves a priming, it returns the time until the next priming is
+// allowed if one ran recently or is running
+func (p *primer) start(now time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return primeInterval, false
+	}
+	if wait := p.last.Add(primeInterval).Sub(now); !p.last.IsZero() && wait > 0 {
+		return wait, false
+	}
+
+	p.running = true
+	p.last = now
+	return 0, true
+}
+
+// done releases the priming reserved by start
+func (p *primer) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.running = false
+}
+
+// primeCaches looks up the organizations and custom fields of the tickets in
+// sync, so they are cached before the first collection needs them. Lookups
+// of a page are sent concurrently with --api.enrichment-concurrency.
+func primeCaches() (primeResult, error) {
+	var result primeResult
+	organizations := make(map[int]bool)
+	fields := make(map[int]bool)
+
+	err := source.StreamTickets(ticketInSyncBound, func(ticket *supportpal.Ticket) {
+		if !ticketSelected(ticket) {
+			return
+		}
+		result.Tickets++
+
+		if id := ticket.User.OrganizationID; id != 0 && !organizations[id] {
+			organizations[id] = true
+			if _, err := lastKnown.organization(id); err != nil && !errors.Is(err, supportpal.ErrNotFound) {
+				result.Failed++
+			}
+		}
+
+		for _, field := range ticket.CustomFields {
+			if fields[field.FieldID] {
+				continue
+			}
+			fields[field.FieldID] = true
+			if _, err := lastKnown.customField(field.FieldID); err != nil && !errors.Is(err, supportpal.ErrNotFound) {
+				result.Failed++
+			}
+		}
+	})
+
+	result.Organizations = len(organizations)
+	result.CustomFields = len(fields)
+	return result, err
+}
+
+// primeHandler primes the organization and custom field caches on POST and
+// answers once they are filled, so a rollout can prepare a new instance
+// before traffic is switched to it. Primings are rate limited, a request
+// within primeInterval of the last one is answered with 429.
+func primeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	wait, ok := cachePrimer.start(time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		http.Error(w, "cache priming ran recently or is running", http.StatusTooManyRequests)
+		return
+	}
+	defer cachePrimer.done()
+
+	start := time.Now()
+	result, err := primeCaches()
+	if err != nil {
+		slog.Error("failed to prime caches", "err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	result.DurationSeconds = time.Since(start).Seconds()
+	slog.Info("primed caches", "tickets", result.Tickets, "organizations", result.Organizations,
+		"custom_fields", result.CustomFields, "failed", result.Failed, "duration", time.Since(start))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// privacy modes of the labels listed in config.PrivacyLabels
+const (
+	// privacyHash replaces values by a salted hash, so tickets can still be
+	// grouped by customer without storing names
+	privacyHash = "hash"
+	// privacyRedact replaces values by a constant
+	privacyRedact = "redact"
+)
+
+// redactedValue replaces redacted label values
+const redactedValue = "redacted"
+
+// validatePrivacy checks the privacy configuration
+func validatePrivacy(mode, salt string, labels []string) error {
+	switch mode {
+	case privacyHash:
+		if len(labels) > 0 && salt == "" {
+			return fmt.Errorf("hashing labels requires a salt, unsalted hashes of names are easily reversed")
+		}
+	case privacyRedact:
+	default:
+		return fmt.Errorf("unknown privacy mode %q, expected %s or %s", mode, privacyHash, privacyRedact)
+	}
+
+	return nil
+}
+
+// anonymize returns value hashed or redacted according to the privacy mode.
+// Empty values are kept empty.
+func anonymize(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	if config.PrivacyMode == privacyRedact {
+		return redactedValue
+	}
+
+	return hashValue(value)
+}
+
+// hashValue returns a short hash of value keyed with the privacy salt. It is
+// stable across restarts and instances sharing the salt.
+func hashValue(value string) string {
+	mac := hmac.New(sha256.New, []byte(config.PrivacySalt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// subjectLabel returns the name and value of the subject label of a ticket,
+// a hash of the subject with config.SubjectHash
+func subjectLabel(subject string) (string, string) {
+	if !config.SubjectHash {
+		return "subject", subject
+	}
+
+	if subject == "" {
+		return "subject_hash", ""
+	}
+	return "subject_hash", hashValue(subject)
+}
+
+// anonymizeTicket anonymizes the configured labels of a ticket. The
+// organization name shown by the organization and ticket endpoints follows
+// the client label.
+func anonymizeTicket(labels prometheus.Labels, series *ticketSeries) {
+	for _, name := range config.PrivacyLabels {
+		if value, ok := labels[name]; ok {
+			labels[name] = anonymize(value)
+		}
+	}
+
+	if slices.Contains(config.PrivacyLabels, "client") {
+		series.Organization = anonymize(series.Organization)
+	}
+}
+
+// operatorName returns the value of an operator name in the operator and
+// resolved_by labels, normalized like user names and anonymized if operator
+// is a privacy label. Names are converted where they are read from the API,
+// so they are consistent across all operator metrics and never reach the
+// state file.
+func operatorName(name string) string {
+	name = normalizeName(name)
+	if slices.Contains(config.PrivacyLabels, "operator") {
+		return anonymize(name)
+	}
+
+	return name
+}
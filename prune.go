@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushGrouping is a group of metrics on a Pushgateway
+type pushGrouping struct {
+	Labels map[string]string
+	// PushTime is the time of the last push, zero if the Pushgateway
+	// reported none that parses
+	PushTime time.Time
+}
+
+// String returns the grouping labels in the form name="value",...
+func (g pushGrouping) String() string {
+	names := slices.Sorted(maps.Keys(g.Labels))
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(g.Labels[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// pushGatewayMetrics is the response of the /api/v1/metrics endpoint of a
+// Pushgateway, only the grouping labels and push times are read
+type pushGatewayMetrics struct {
+	Data []struct {
+		Labels   map[string]string `json:"labels"`
+		PushTime struct {
+			Metrics []struct {
+				Value string `json:"value"`
+			} `json:"metrics"`
+		} `json:"push_time_seconds"`
+	} `json:"data"`
+}
+
+// groupings returns the groupings of the job on the Pushgateway
+func (p pushSink) groupings() ([]pushGrouping, error) {
+	resp, err := p.client.Get(strings.TrimSuffix(p.url, "/") + "/api/v1/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("list groupings: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var metrics pushGatewayMetrics
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		return nil, fmt.Errorf("list groupings: %w", err)
+	}
+
+	var groupings []pushGrouping
+	for _, group := range metrics.Data {
+		if group.Labels["job"] != p.job {
+			continue
+		}
+
+		g := pushGrouping{Labels: group.Labels}
+		if len(group.PushTime.Metrics) > 0 {
+			if seconds, err := strconv.ParseFloat(group.PushTime.Metrics[0].Value, 64); err == nil {
+				g.PushTime = time.Unix(int64(seconds), 0)
+			}
+		}
+		groupings = append(groupings, g)
+	}
+
+	return groupings, nil
+}
+
+// prune deletes the groupings of the job last pushed before cutoff, except
+// the grouping the exporter pushes to itself. Pushgateway keeps every group
+// until it is deleted, so groups of renamed instances or of tickets pushed
+// as groups of their own are exported with their last values forever.
+// Groupings without a push time that parses are kept, as their age is
+// unknown.
+func (p pushSink) prune(cutoff time.Time) ([]pushGrouping, error) {
+	groupings, err := p.groupings()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []pushGrouping
+	for _, g := range groupings {
+		if len(g.Labels) == 2 && g.Labels["instance"] == p.instance {
+			continue
+		}
+		if g.PushTime.IsZero() {
+			slog.Warn("keeping Pushgateway grouping without push time", "grouping", g.String())
+			continue
+		}
+		if !g.PushTime.Before(cutoff) {
+			continue
+		}
+
+		pusher := push.New(p.url, p.job).Client(p.client)
+		for name, value := range g.Labels {
+			if name != "job" {
+				pusher = pusher.Grouping(name, value)
+			}
+		}
+		if err := pusher.Delete(); err != nil {
+			return deleted, fmt.Errorf("delete grouping %s: %w", g, err)
+		}
+		deleted = append(deleted, g)
+	}
+
+	return deleted, nil
+}
+
+// runPrune deletes the stale groupings of the job from the Pushgateway once
+// and writes the deleted groupings to w
+func runPrune(w io.Writer) error {
+	if config.PushURL == "" || config.PushMode != pushGateway {
+		return fmt.Errorf("prune requires --push.url with --push.mode=%s", pushGateway)
+	}
+
+	deleted, err := newPushSink(nil).prune(time.Now().Add(-config.PushPruneAfter))
+	for _, g := range deleted {
+		fmt.Fprintf(w, "deleted %s\n", g)
+	}
+
+	return err
+}
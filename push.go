@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// push modes of --push.mode
+const (
+	// pushGateway replaces the metrics of the job and instance on a
+	// Prometheus Pushgateway
+	pushGateway = "pushgateway"
+	// pushRemoteWrite sends the metrics with the Prometheus remote write protocol
+	pushRemoteWrite = "remote-write"
+)
+
+// pushTimeout bounds a single push request
+const pushTimeout = 30 * time.Second
+
+// pushSink pushes all metrics to a Pushgateway or remote write endpoint
+// after every cycle, for environments that only allow outbound connections
+type pushSink struct {
+	mode     string
+	url      string
+	job      string
+	instance string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+	// pruneAfter deletes the other groupings of the job on the Pushgateway
+	// not pushed to for this long after every push, 0 keeps them
+	pruneAfter time.Duration
+}
+
+// Name implements Sink
+func (pushSink) Name() string { return "push" }
+
+// Publish implements Sink. Runtime metrics of the Go process are left out
+// like in the textfile, they describe the exporter and not SupportPal. The
+// Pushgateway rejects sample timestamps, remote write keeps them.
+func (p pushSink) Publish(s *snapshot) error {
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := p.gatherer.Gather()
+		return withoutRuntimeMetrics(families), err
+	})
+
+	if p.mode == pushRemoteWrite {
+		return p.remoteWrite(gatherer, time.Now())
+	}
+
+	pushGatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		return withoutTimestamps(families), err
+	})
+	err := push.New(p.url, p.job).Grouping("instance", p.instance).Gatherer(pushGatherer).Client(p.client).Push()
+	if err != nil || p.pruneAfter == 0 {
+		return err
+	}
+
+	deleted, err := p.prune(time.Now().Add(-p.pruneAfter))
+	for _, g := range deleted {
+		slog.Info("deleted stale Pushgateway grouping", "grouping", g.String(), "pushed", g.PushTime)
+	}
+	return err
+}
+
+// remoteWrite sends the gathered metrics as a snappy compressed remote write
+// request with the job and instance labels added
+func (p pushSink) remoteWrite(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	body := s2.EncodeSnappy(nil, writeRequest(families, map[string]string{"job": p.job, "instance": p.instance}, now))
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// remoteSample is a single series of a remote write request
+type remoteSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// writeRequest encodes the families as remote write WriteRequest protobuf.
+// Histograms and summaries are split into their series like on a scrape.
+// Labels of a metric take precedence over the extra labels.
+func writeRequest(families []*dto.MetricFamily, extra map[string]string, now time.Time) []byte {
+	var req []byte
+	eachSample(families, extra, now, func(sample remoteSample, timestamp int64) {
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, timeSeries(sample, timestamp))
+	})
+
+	return req
+}
+
+// eachSample calls fn with every series of the families, split like on a
+// scrape, and its timestamp in milliseconds, now for samples without one.
+// Labels of a metric take precedence over the extra labels.
+func eachSample(families []*dto.MetricFamily, extra map[string]string, now time.Time, fn func(sample remoteSample, timestamp int64)) {
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			labels := make(map[string]string, len(metric.Label)+len(extra)+1)
+			for name, value := range extra {
+				labels[name] = value
+			}
+			for _, pair := range metric.Label {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			timestamp := now.UnixMilli()
+			if metric.TimestampMs != nil {
+				timestamp = metric.GetTimestampMs()
+			}
+
+			for _, sample := range metricSamples(family.GetName(), metric, labels) {
+				fn(sample, timestamp)
+			}
+		}
+	}
+}
+
+// metricSamples returns the series of a metric
+func metricSamples(name string, metric *dto.Metric, labels map[string]string) []remoteSample {
+	with := func(suffix, label, value string, v float64) remoteSample {
+		l := make(map[string]string, len(labels)+2)
+		for k, v := range labels {
+			l[k] = v
+		}
+		l["__name__"] = name + suffix
+		if label != "" {
+			l[label] = value
+		}
+		return remoteSample{labels: l, value: v}
+	}
+
+	switch {
+	case metric.Counter != nil:
+		return []remoteSample{with("", "", "", metric.Counter.GetValue())}
+	case metric.Gauge != nil:
+		return []remoteSample{with("", "", "", metric.Gauge.GetValue())}
+	case metric.Untyped != nil:
+		return []remoteSample{with("", "", "", metric.Untyped.GetValue())}
+	case metric.Summary != nil:
+		samples := []remoteSample{
+			with("_sum", "", "", metric.Summary.GetSampleSum()),
+			with("_count", "", "", float64(metric.Summary.GetSampleCount())),
+		}
+		for _, q := range metric.Summary.Quantile {
+			samples = append(samples, with("", "quantile", formatFloat(q.GetQuantile()), q.GetValue()))
+		}
+		return samples
+	case metric.Histogram != nil:
+		samples := []remoteSample{
+			with("_sum", "", "", metric.Histogram.GetSampleSum()),
+			with("_count", "", "", float64(metric.Histogram.GetSampleCount())),
+			with("_bucket", "le", "+Inf", float64(metric.Histogram.GetSampleCount())),
+		}
+		for _, b := range metric.Histogram.Bucket {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			samples = append(samples, with("_bucket", "le", formatFloat(b.GetUpperBound()), float64(b.GetCumulativeCount())))
+		}
+		return samples
+	}
+
+	return nil
+}
+
+// formatFloat formats le and quantile label values like the text format
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// timeSeries encodes a TimeSeries message with labels sorted by name, as
+// required by the remote write protocol
+func timeSeries(sample remoteSample, timestamp int64) []byte {
+	names := make([]string, 0, len(sample.labels))
+	for name := range sample.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ts []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, sample.labels[name])
+
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+
+	var s []byte
+	s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+	s = protowire.AppendFixed64(s, math.Float64bits(sample.value))
+	s = protowire.AppendTag(s, 2, protowire.VarintType)
+	s = protowire.AppendVarint(s, uint64(timestamp))
+
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	return protowire.AppendBytes(ts, s)
+}
+
+// pushAuth adds basic or bearer authentication to push requests
+type pushAuth struct {
+	next      http.RoundTripper
+	username  string
+	password  string
+	tokenFile string
+}
+
+// RoundTrip implements http.RoundTripper. The token file is read for every
+// request, so a rotated token is picked up.
+func (a pushAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	switch {
+	case a.tokenFile != "":
+		token, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	case a.username != "":
+		req.SetBasicAuth(a.username, a.password)
+	}
+
+	return a.next.RoundTrip(req)
+}
+
+// newPushSink returns the push sink of the configuration
+func newPushSink(gatherer prometheus.Gatherer) pushSink {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.PushTLS != nil {
+		transport.TLSClientConfig = config.PushTLS
+	}
+
+	return pushSink{
+		mode:       config.PushMode,
+		url:        config.PushURL,
+		job:        config.PushJob,
+		instance:   config.PushInstance,
+		gatherer:   gatherer,
+		pruneAfter: config.PushPruneAfter,
+		client: &http.Client{
+			Timeout: pushTimeout,
+			Transport: pushAuth{
+				next:      transport,
+				username:  config.PushUsername,
+				password:  config.PushPassword,
+				tokenFile: config.PushBearerTokenFile,
+			},
+		},
+	}
+}
+
+// validatePush checks the push configuration
+func validatePush(mode, username, tokenFile string) error {
+	if mode != pushGateway && mode != pushRemoteWrite {
+		return fmt.Errorf("unknown push mode %q, expected %s or %s", mode, pushGateway, pushRemoteWrite)
+	}
+
+	if username != "" && tokenFile != "" {
+		return fmt.Errorf("basic authentication and a bearer token file cannot be used together")
+	}
+
+	return nil
+}
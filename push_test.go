@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// prompbTypes are the messages of the remote write protocol as defined in
+// prompb/remote.proto and prompb/types.proto of Prometheus, whose module is
+// too heavy a dependency for a test
+const prompbTypes = `
+name: "prompb.proto" package: "prometheus" syntax: "proto3"
+message_type {
+  name: "WriteRequest"
+  field { name: "timeseries" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.TimeSeries" json_name: "timeseries" }
+}
+message_type {
+  name: "TimeSeries"
+  field { name: "labels" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.Label" json_name: "labels" }
+  field { name: "samples" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.Sample" json_name: "samples" }
+}
+message_type {
+  name: "Label"
+  field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+  field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "value" }
+}
+message_type {
+  name: "Sample"
+  field { name: "value" number: 1 label: LABEL_OPTIONAL type: TYPE_DOUBLE json_name: "value" }
+  field { name: "timestamp" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "timestamp" }
+}
+`
+
+// writeRequestJSON is the JSON mapping of a decoded WriteRequest
+type writeRequestJSON struct {
+	Timeseries []struct {
+		Labels []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"labels"`
+		Samples []struct {
+			Value     float64 `json:"value"`
+			Timestamp string  `json:"timestamp"`
+		} `json:"samples"`
+	} `json:"timeseries"`
+}
+
+// decodeWriteRequest decodes a remote write request body with the prompb
+// message types
+func decodeWriteRequest(t *testing.T, body []byte) writeRequestJSON {
+	t.Helper()
+
+	var file descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(prompbTypes), &file); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := protodesc.NewFile(&file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := dynamicpb.NewMessage(desc.Messages().ByName("WriteRequest"))
+	if err := proto.Unmarshal(body, msg); err != nil {
+		t.Fatalf("body is no WriteRequest: %v", err)
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req writeRequestJSON
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestRemoteWrite(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	created := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "supportpal_tickets_created_total", Help: "Created"}, []string{"department"})
+	created.WithLabelValues("Sales").Add(3)
+	open := prometheus.NewGauge(prometheus.GaugeOpts{Name: "supportpal_tickets_open", Help: "Open", ConstLabels: prometheus.Labels{"instance": "own"}})
+	open.Set(5)
+	age := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "supportpal_ticket_age_seconds", Help: "Age", Buckets: []float64{60}})
+	age.Observe(30)
+	age.Observe(90)
+	reg.MustRegister(created, open, age)
+
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("request headers %v", r.Header)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		var err error
+		if body, err = s2.Decode(nil, compressed); err != nil {
+			t.Errorf("body is not snappy compressed: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	now := time.UnixMilli(1767225600123)
+	p := pushSink{mode: pushRemoteWrite, url: srv.URL, job: "supportpal", instance: "exporter", client: srv.Client()}
+	if err := p.remoteWrite(reg, now); err != nil {
+		t.Fatal(err)
+	}
+
+	series := make(map[string]float64)
+	for _, ts := range decodeWriteRequest(t, body).Timeseries {
+		var names, pairs []string
+		for _, l := range ts.Labels {
+			names = append(names, l.Name)
+			pairs = append(pairs, l.Name+"="+l.Value)
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Errorf("labels %v are not sorted by name", names)
+		}
+		if len(ts.Samples) != 1 || ts.Samples[0].Timestamp != strconv.FormatInt(now.UnixMilli(), 10) {
+			t.Errorf("series %v: samples %+v, want one at %d", pairs, ts.Samples, now.UnixMilli())
+			continue
+		}
+		series[strings.Join(pairs, ",")] = ts.Samples[0].Value
+	}
+
+	want := map[string]float64{
+		"__name__=supportpal_ticket_age_seconds_bucket,instance=exporter,job=supportpal,le=+Inf":      2,
+		"__name__=supportpal_ticket_age_seconds_bucket,instance=exporter,job=supportpal,le=60":        1,
+		"__name__=supportpal_ticket_age_seconds_count,instance=exporter,job=supportpal":               2,
+		"__name__=supportpal_ticket_age_seconds_sum,instance=exporter,job=supportpal":                 120,
+		"__name__=supportpal_tickets_created_total,department=Sales,instance=exporter,job=supportpal": 3,
+		// the labels of the metric win over the added ones
+		"__name__=supportpal_tickets_open,instance=own,job=supportpal": 5,
+	}
+	for name, value := range want {
+		if got, ok := series[name]; !ok || got != value {
+			t.Errorf("series %s = %v, %v, want %v", name, got, ok, value)
+		}
+	}
+	if len(series) != len(want) {
+		t.Errorf("%d series, want %d: %v", len(series), len(want), slices.Sorted(maps.Keys(series)))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	grouping := func(instance, pushTime string) string {
+		times := `[]`
+		if pushTime != "" {
+			times = `[{"value":"` + pushTime + `"}]`
+		}
+		return `{"labels":{"job":"supportpal","instance":"` + instance + `"},"push_time_seconds":{"metrics":` + times + `}}`
+	}
+	groupings := []string{
+		grouping("exporter", "1"),
+		grouping("stale", strconv.FormatInt(now.Add(-2*time.Hour).Unix(), 10)),
+		grouping("fresh", strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)),
+		grouping("untimed", ""),
+		grouping("unparsable", "yesterday"),
+		`{"labels":{"job":"other","instance":"stale"},"push_time_seconds":{"metrics":[{"value":"1"}]}}`,
+	}
+
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":[%s]}`, strings.Join(groupings, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	// only the grouping known to be stale is deleted, not the own one nor
+	// the ones of unknown age
+	p := pushSink{mode: pushGateway, url: srv.URL, job: "supportpal", instance: "exporter", client: srv.Client()}
+	pruned, err := p.prune(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Labels["instance"] != "stale" {
+		t.Errorf("pruned %v, want the stale grouping", pruned)
+	}
+	if !slices.Equal(deleted, []string{"/metrics/job/supportpal/instance/stale"}) {
+		t.Errorf("deleted %v, want the stale grouping", deleted)
+	}
+}
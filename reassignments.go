@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reassignmentBuckets are the upper bounds of the reassignment histogram
+var reassignmentBuckets = []float64{0, 1, 2, 3, 5, 10}
+
+var supportPalOpenTicketReassignmentsDesc = prometheus.NewDesc("supportpal_open_ticket_reassignments",
+	"Distribution of the number of times open tickets were reassigned", nil, nil)
+
+// reassignmentCount returns the number of times a ticket was reassigned
+// according to its audit log. The first assignment is not a reassignment.
+func reassignmentCount(logs []*supportpal.TicketLog) int {
+	assignments := 0
+	for _, entry := range logs {
+		if entry.Event == supportpal.TicketLogAssigned {
+			assignments++
+		}
+	}
+
+	return max(assignments-1, 0)
+}
+
+// fetchReassignments sets the reassignments of an open ticket from its audit
+// log, or keeps those of the previous snapshot if it cannot be listed
+func fetchReassignments(ticket *supportpal.Ticket, series *ticketSeries) {
+	logs, err := source.TicketLogs(ticket)
+	if err != nil {
+		slog.Error("failed to list ticket log", "ticket_id", ticket.ID, "err", err)
+		collectionError("ticket_log")
+		keepReassignments(series)
+		return
+	}
+
+	series.ReassignmentsFetched = true
+	series.Reassignments = reassignmentCount(logs)
+}
+
+// collectReassignments sends the per ticket reassignments of the open tickets
+// of s and their distribution
+func collectReassignments(ch chan<- prometheus.Metric, s *snapshot) {
+	if !config.FetchReassignments {
+		return
+	}
+
+	reassignmentsDesc := newSeriesDesc("supportpal_ticket_reassignments",
+		"Number of times an open ticket was reassigned to another operator")
+
+	buckets := make(map[float64]uint64, len(reassignmentBuckets))
+	var count uint64
+	var sum float64
+
+	for _, series := range s.Series {
+		if !series.ReassignmentsFetched || !series.open() {
+			continue
+		}
+
+		if !series.Hidden {
+			ch <- reassignmentsDesc.metric(s, series, prometheus.GaugeValue, float64(series.Reassignments))
+		}
+
+		count++
+		sum += float64(series.Reassignments)
+		for _, bound := range reassignmentBuckets {
+			if float64(series.Reassignments) <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstHistogram(supportPalOpenTicketReassignmentsDesc, count, sum, buckets)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Attributions of resolved tickets to operators, see config.ResolvedBy
+const (
+	// resolvedByAssigned attributes a ticket to the first operator assigned
+	// to it when it was seen resolved
+	resolvedByAssigned = "assigned"
+	// resolvedByLastReply attributes a ticket to the operator who sent the
+	// last response before it was resolved
+	resolvedByLastReply = "last-reply"
+)
+
+var supportPalTicketsResolvedByOperator = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_tickets_resolved_by_operator_total",
+	Help: "Number of times tickets were resolved by the operator they are attributed to, counted once per resolution across collection cycles",
+}, []string{"department", "operator"})
+
+// resolvingOperator returns the operator who sent the last response to the
+// ticket at or before it was resolved at resolved, empty if there is none.
+// The API has no record of who changed the status, the operator who answered
+// last is taken as the one who resolved it.
+func resolvingOperator(messages []*supportpal.Message, resolved int64) string {
+	var last *supportpal.Message
+	for _, m := range messages {
+		if countsAsResponse(m) && m.CreatedAt <= resolved && (last == nil || m.CreatedAt >= last.CreatedAt) {
+			last = m
+		}
+	}
+
+	if last == nil {
+		return ""
+	}
+	return operatorName(last.User.FormattedName)
+}
+
+// resolvedBy sets the operator a resolved ticket is attributed to by
+// config.ResolvedBy. With last-reply it is set from the messages already.
+func resolvedBy(series *ticketSeries) {
+	if series.Resolved == 0 || config.ResolvedBy != resolvedByAssigned {
+		return
+	}
+
+	series.ResolvedBy = ""
+	if len(series.Assigned) > 0 {
+		series.ResolvedBy = series.Assigned[0]
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// fieldTypeNames are the names of the custom field types the exporter renders
+var fieldTypeNames = map[int]string{
+	fieldTypeCheckbox:    "checkbox",
+	fieldTypeDate:        "date",
+	fieldTypeMultiSelect: "multiple options",
+	fieldTypeNumber:      "number",
+	fieldTypeSelect:      "options",
+}
+
+// fieldTypeName returns the name of a custom field type
+func fieldTypeName(fieldType int) string {
+	if name, ok := fieldTypeNames[fieldType]; ok {
+		return name
+	}
+	return "type " + strconv.Itoa(fieldType)
+}
+
+// fieldExport describes how the exporter exports a custom field
+func fieldExport(field *supportpal.GetCustomFieldResponse) string {
+	switch {
+	case isNumericField(field):
+		return "value"
+	case isLabelField(field):
+		return "label"
+	case config.DisableFieldLabels:
+		return "disabled"
+	default:
+		return "excluded"
+	}
+}
+
+// runSchema lists every custom field of the API with its ID, label name and
+// type and how it is exported, followed by the label schema of the ticket
+// metrics. Unlike the schema discovered at startup it includes fields no
+// ticket in sync uses yet.
+func runSchema(w io.Writer) error {
+	fields, err := apiClient.ListCustomFields()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(fields, func(a, b *supportpal.CustomField) int { return a.ID - b.ID })
+	resolveFieldLabels(fields)
+
+	labels := labelSchema(nil)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tLABEL\tEXPORTED AS")
+	for _, data := range fields {
+		field := &supportpal.GetCustomFieldResponse{Status: "success", Data: *data}
+		name := customFieldLabel(field)
+		export := fieldExport(field)
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", data.ID, data.Name, fieldTypeName(data.Type), name, export)
+		if export == "label" && !slices.Contains(labels, name) {
+			labels = append(labels, name)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nlabels: %s\n", strings.Join(labels, ","))
+	for _, j := range config.Jobs {
+		fmt.Fprintf(w, "job %s labels: %s\n", j.Name, strings.Join(j.schema(labels), ","))
+	}
+
+	return nil
+}
+
+// schemaField describes how a custom field is exported
+type schemaField struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Slug is the label name derived from the field name, Label differs
+	// from it for explicit names and remapped labels
+	Slug       string `json:"slug"`
+	Label      string `json:"label"`
+	ExportedAs string `json:"exported_as"`
+}
+
+// schemaJob is the label schema of a job
+type schemaJob struct {
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Labels []string `json:"labels"`
+}
+
+// schemaNormalization holds the rules label names and values follow
+type schemaNormalization struct {
+	SlugLanguage     string   `json:"slug_language"`
+	LabelPrefix      string   `json:"label_prefix"`
+	MissingFields    string   `json:"missing_fields"`
+	MissingSentinel  string   `json:"missing_sentinel,omitempty"`
+	LabelSets        string   `json:"label_sets"`
+	TicketInfo       bool     `json:"ticket_info"`
+	TicketInfoLabels []string `json:"ticket_info_labels,omitempty"`
+	PrivacyLabels    []string `json:"privacy_labels,omitempty"`
+	PrivacyMode      string   `json:"privacy_mode,omitempty"`
+	DurationUnit     string   `json:"duration_unit"`
+	// OrganizationNames is the normalization of the client label
+	OrganizationNames string `json:"organization_names"`
+}
+
+// schemaDocument is the effective label schema served at /schema
+type schemaDocument struct {
+	CollectedAt time.Time `json:"collected_at"`
+	Labels      []string  `json:"labels"`
+	// Departments holds the labels of every department with department
+	// label sets
+	Departments   map[string][]string `json:"departments,omitempty"`
+	CustomFields  []schemaField       `json:"custom_fields"`
+	Jobs          []schemaJob         `json:"jobs,omitempty"`
+	Normalization schemaNormalization `json:"normalization"`
+}
+
+// currentSchema returns the effective label schema of s
+func currentSchema(s *snapshot) schemaDocument {
+	doc := schemaDocument{
+		CollectedAt:  s.CollectedAt,
+		Labels:       s.LabelNames,
+		Departments:  s.Groups,
+		CustomFields: []schemaField{},
+		Normalization: schemaNormalization{
+			SlugLanguage:  config.SlugLanguage,
+			LabelPrefix:   config.FieldLabelPrefix,
+			MissingFields: config.MissingFields,
+			LabelSets:     config.LabelSets,
+			TicketInfo:    config.TicketInfo,
+			PrivacyLabels: config.PrivacyLabels,
+			DurationUnit:  config.DurationUnit,
+
+			OrganizationNames: config.OrganizationNames,
+		},
+	}
+
+	if config.MissingFields == missingSentinel {
+		doc.Normalization.MissingSentinel = config.MissingFieldSentinel
+	}
+	if config.TicketInfo {
+		doc.Normalization.TicketInfoLabels = infoKeyLabels
+	}
+	if len(config.PrivacyLabels) > 0 {
+		doc.Normalization.PrivacyMode = config.PrivacyMode
+	}
+
+	for _, field := range lastKnown.knownCustomFields() {
+		doc.CustomFields = append(doc.CustomFields, schemaField{
+			ID:         field.Data.ID,
+			Name:       field.Data.Name,
+			Type:       fieldTypeName(field.Data.Type),
+			Slug:       fieldSlug(field.Data.Name, config.SlugLanguage),
+			Label:      customFieldLabel(field),
+			ExportedAs: fieldExport(field),
+		})
+	}
+
+	for _, j := range config.Jobs {
+		doc.Jobs = append(doc.Jobs, schemaJob{Name: j.Name, Prefix: j.Prefix, Labels: j.schema(s.LabelNames)})
+	}
+
+	return doc
+}
+
+// schemaHandler serves the effective label schema of the current snapshot as
+// JSON, e.g. to generate dashboard panels for the custom fields of an install
+func schemaHandler(w http.ResponseWriter, r *http.Request) {
+	s := supportPalTickets.current.Load()
+	if s == nil {
+		http.Error(w, "no data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(collectionInterval.Seconds())))
+	w.Header().Set("Last-Modified", s.CollectedAt.UTC().Format(http.TimeFormat))
+
+	if err := json.NewEncoder(w).Encode(currentSchema(s)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
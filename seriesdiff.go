@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxDiffTickets is the number of ticket IDs logged per change of a series diff
+const maxDiffTickets = 10
+
+var (
+	supportPalSeriesAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "supportpal_series_added_total",
+		Help: "Number of ticket label sets a collection cycle exported that the previous cycle did not, each a new series of every per ticket metric",
+	})
+
+	supportPalSeriesRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "supportpal_series_removed_total",
+		Help: "Number of ticket label sets the previous collection cycle exported that a cycle dropped, each a series gone stale in every per ticket metric",
+	})
+)
+
+// seriesDiff is the change of the exported ticket series between two cycles
+type seriesDiff struct {
+	added, updated, removed []int
+}
+
+// diffSeries compares the exported ticket series of two snapshots. A ticket
+// whose labels changed, e.g. its status, removes its old series and adds a
+// new one, a ticket updated without label changes keeps its series.
+func diffSeries(previous, current *snapshot) seriesDiff {
+	last := make(map[string]*ticketSeries, len(previous.Series))
+	for _, series := range previous.Series {
+		if !series.Hidden {
+			last[seriesKey(previous.schema(series), series.LabelValues)] = series
+		}
+	}
+
+	var diff seriesDiff
+	for _, series := range current.Series {
+		if series.Hidden {
+			continue
+		}
+
+		key := seriesKey(current.schema(series), series.LabelValues)
+		before, ok := last[key]
+		switch {
+		case !ok:
+			diff.added = append(diff.added, series.ID)
+		case before.Updated != series.Updated:
+			diff.updated = append(diff.updated, series.ID)
+		}
+		delete(last, key)
+	}
+	for _, series := range last {
+		diff.removed = append(diff.removed, series.ID)
+	}
+	slices.Sort(diff.removed)
+
+	return diff
+}
+
+// logSeriesDiff counts and logs the ticket series a cycle added, updated and
+// removed compared to the previous snapshot, to find the tickets behind
+// cardinality churn and series that disappear unexpectedly
+func logSeriesDiff(previous, current *snapshot) {
+	diff := diffSeries(previous, current)
+	supportPalSeriesAdded.Add(float64(len(diff.added)))
+	supportPalSeriesRemoved.Add(float64(len(diff.removed)))
+
+	slog.Info("ticket series changed",
+		"added", len(diff.added), "updated", len(diff.updated), "removed", len(diff.removed),
+		"added_tickets", firstTickets(diff.added), "removed_tickets", firstTickets(diff.removed))
+}
+
+// firstTickets returns up to maxDiffTickets of ids
+func firstTickets(ids []int) []int {
+	return ids[:min(len(ids), maxDiffTickets)]
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Windows service commands of the service subcommand
+const (
+	serviceInstall   = "install"
+	serviceUninstall = "uninstall"
+)
+
+// defaultServiceName is the name the Windows service is installed under
+const defaultServiceName = "supportpal-exporter"
+
+// serviceServer is the HTTP server a stop request of the Windows service
+// manager shuts down, nil until it listens
+var serviceServer atomic.Pointer[http.Server]
+
+// stopServer shuts down the HTTP server, waiting up to 10s for the running
+// requests to complete
+func stopServer() {
+	server := serviceServer.Load()
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
+
+// serviceArgs returns the arguments the installed service starts the
+// exporter with: those given to the install command, logging to the
+// Windows event log unless a log format was given
+func serviceArgs(args []string) []string {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "log.format" {
+			return args
+		}
+	}
+
+	return append(args[:len(args):len(args)], "--log.format=eventlog")
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// errNotWindows is returned by the Windows service commands on other systems
+var errNotWindows = errors.New("Windows services and the event log are only supported on Windows")
+
+// startService does nothing, the exporter only runs as service on Windows
+func startService() {}
+
+// runServiceCommand fails, services are only installed on Windows
+func runServiceCommand(string, []string) error {
+	return errNotWindows
+}
+
+// newEventLogHandler fails, the event log only exists on Windows
+func newEventLogHandler(*slog.HandlerOptions) (slog.Handler, error) {
+	return nil, errNotWindows
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// startService reports the exporter as running to the Windows service
+// manager when it was started as service, and shuts it down on a stop
+// request or at system shutdown. The service manager is connected before
+// the API, so a slow API does not time out the start of the service.
+func startService() {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		fatal("failed to detect the Windows service manager", "err", err)
+	}
+	if !isService {
+		return
+	}
+
+	go func() {
+		if err := svc.Run(config.ServiceName, windowsService{}); err != nil {
+			fatal("failed to run as Windows service", "name", config.ServiceName, "err", err)
+		}
+		os.Exit(0)
+	}()
+}
+
+// windowsService handles the requests of the Windows service manager
+type windowsService struct{}
+
+// Execute implements svc.Handler
+func (windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			status <- r.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			slog.Info("stopping Windows service", "name", config.ServiceName)
+			status <- svc.Status{State: svc.StopPending}
+			stopServer()
+			stepDown()
+			return false, 0
+		}
+	}
+
+	return false, 0
+}
+
+// runServiceCommand installs or uninstalls the Windows service
+func runServiceCommand(command string, args []string) error {
+	switch command {
+	case serviceInstall:
+		return installService(config.ServiceName, serviceArgs(args))
+	case serviceUninstall:
+		return uninstallService(config.ServiceName)
+	default:
+		return fmt.Errorf("unknown service command %q, expected install or uninstall", command)
+	}
+}
+
+// installService registers the running executable as automatically started
+// service name, restarted after failures, with args and an event log source
+// of the same name. API_BASE_PATH is stored in the environment of the
+// service, the other settings are passed as flags.
+func installService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "SupportPal Prometheus exporter",
+		Description: "Exports the tickets of SupportPal as Prometheus metrics",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// like Restart=on-failure of systemd, fatal errors included
+	err = errors.Join(
+		s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 10 * time.Second}}, uint32((24*time.Hour).Seconds())),
+		s.SetRecoveryActionsOnNonCrashFailures(true),
+		setServiceEnvironment(name),
+		eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info),
+	)
+	if err != nil {
+		s.Delete()
+		return err
+	}
+
+	if os.Getenv("API_TOKEN") != "" && config.TokenFile == "" {
+		slog.Warn("API_TOKEN is not stored with the service, pass the token with --api.token-file")
+	}
+	slog.Info("installed Windows service", "name", name, "args", strings.Join(args, " "))
+	return nil
+}
+
+// setServiceEnvironment stores API_BASE_PATH in the environment of service
+// name, services do not inherit the environment of the installing user
+func setServiceEnvironment(name string) error {
+	base := os.Getenv("API_BASE_PATH")
+	if base == "" {
+		return nil
+	}
+
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+name, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	return key.SetStringsValue("Environment", []string{"API_BASE_PATH=" + base})
+}
+
+// uninstallService stops and removes service name and its event log source
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	// a stopped service rejects the stop request
+	s.Control(svc.Stop)
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	if err := eventlog.Remove(name); err != nil {
+		slog.Warn("failed to remove the event log source", "name", name, "err", err)
+	}
+
+	slog.Info("uninstalled Windows service", "name", name)
+	return nil
+}
+
+// eventLogHandler writes logfmt records without the time, which the event
+// log records itself, to the Windows event log as error, warning or
+// information events by their level
+type eventLogHandler struct {
+	mu   *sync.Mutex
+	buf  *bytes.Buffer
+	log  *eventlog.Log
+	text slog.Handler
+}
+
+// newEventLogHandler returns a handler writing records to the event log
+// source of the service
+func newEventLogHandler(opts *slog.HandlerOptions) (slog.Handler, error) {
+	log, err := eventlog.Open(config.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("event log source %s: %w", config.ServiceName, err)
+	}
+
+	buf := &bytes.Buffer{}
+	return &eventLogHandler{mu: &sync.Mutex{}, buf: buf, log: log, text: slog.NewTextHandler(buf, withoutTime(opts))}, nil
+}
+
+// Enabled implements slog.Handler
+func (h *eventLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.text.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.text.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	msg := strings.TrimSuffix(h.buf.String(), "\n")
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.log.Error(1, msg)
+	case r.Level >= slog.LevelWarn:
+		return h.log.Warning(1, msg)
+	default:
+		return h.log.Info(1, msg)
+	}
+}
+
+// WithAttrs implements slog.Handler
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{mu: h.mu, buf: h.buf, log: h.log, text: h.text.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{mu: h.mu, buf: h.buf, log: h.log, text: h.text.WithGroup(name)}
+}
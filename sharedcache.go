@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// connectSharedCache returns the cache the organization and custom field
+// lookups are shared in with other replicas, nil without --cache.redis-url.
+// An unreachable server is not fatal, lookups fall back to the API and the
+// local cache until it is back.
+func connectSharedCache() supportpal.SharedStore {
+	if config.CacheRedis == nil {
+		return nil
+	}
+
+	if err := config.CacheRedis.Ping(); err != nil {
+		slog.Warn("shared cache is unreachable, lookups fall back to the API", "err", err)
+	}
+	return config.CacheRedis
+}
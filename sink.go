@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Sink is an output of the exporter. Every snapshot produced by a collection
+// cycle is published to all configured sinks, so new outputs are added by
+// implementing Sink without touching the collection pipeline.
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Publish outputs a complete snapshot. It must not modify s.
+	Publish(s *snapshot) error
+}
+
+var supportPalSinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_sink_errors_total",
+	Help: "Number of snapshots a sink failed to publish",
+}, []string{"sink"})
+
+// sinks are the outputs snapshots are published to, set up in main
+var sinks []Sink
+
+// publish fans s out to every sink. A failing sink does not stop the others.
+func publish(s *snapshot) {
+	for _, sink := range sinks {
+		if err := sink.Publish(s); err != nil {
+			supportPalSinkErrors.WithLabelValues(sink.Name()).Inc()
+			slog.Error("failed to publish snapshot", "sink", sink.Name(), "err", err)
+		}
+	}
+
+	setSnapshotStale(false)
+}
+
+// registrySink serves snapshots to Prometheus scrapes of /metrics
+type registrySink struct {
+	collector *ticketCollector
+}
+
+// Name implements Sink
+func (registrySink) Name() string { return "prometheus" }
+
+// Publish implements Sink
+func (r registrySink) Publish(s *snapshot) error {
+	r.collector.store(s)
+	return nil
+}
+
+// stateSink persists snapshots together with the counter state
+type stateSink struct {
+	path string
+}
+
+// Name implements Sink
+func (stateSink) Name() string { return "state" }
+
+// Publish implements Sink
+func (f stateSink) Publish(s *snapshot) error {
+	return saveState(f.path, currentState(s))
+}
+
+// configureSinks returns the sinks enabled by the configuration
+func configureSinks() []Sink {
+	enabled := []Sink{registrySink{collector: supportPalTickets}}
+
+	if config.StatePath != "" {
+		enabled = append(enabled, stateSink{path: config.StatePath})
+	}
+
+	if config.TextfileDir != "" {
+		enabled = append(enabled, textfileSink{dir: config.TextfileDir, gatherer: exporterGatherer()})
+	}
+
+	if config.PushURL != "" {
+		enabled = append(enabled, newPushSink(exporterGatherer()))
+	}
+
+	if config.OTLPEndpoint != "" {
+		enabled = append(enabled, newOTLPSink(exporterGatherer()))
+	}
+
+	if config.InfluxURL != "" {
+		enabled = append(enabled, newInfluxSink(exporterGatherer()))
+	}
+
+	if config.DatadogAPIKeyFile != "" {
+		enabled = append(enabled, newDatadogSink(exporterGatherer()))
+	}
+
+	return enabled
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// pauseSLA records the time an open ticket waited on the customer according
+// to its messages, with business the working time of the waits as well, so
+// the SLA clocks can be paused during them
+func pauseSLA(series *ticketSeries, business *businessCalendar, messages []*supportpal.Message) {
+	waits, since := customerWaits(messages)
+	for _, wait := range waits {
+		from, to := time.Unix(max(wait[0], series.Created), 0), time.Unix(wait[1], 0)
+		if !to.After(from) {
+			continue
+		}
+
+		series.CustomerWait += int64(to.Sub(from).Seconds())
+		if business != nil {
+			series.CustomerWaitBusiness += int64(business.duration(from, to).Seconds())
+		}
+	}
+	series.CustomerWaitSince = since
+}
+
+// customerWait returns the time and, with business, the working time an open
+// ticket waited on the customer until now
+func customerWait(series *ticketSeries, business *businessCalendar, now time.Time) (wall, working time.Duration) {
+	wall = time.Duration(series.CustomerWait) * time.Second
+	working = time.Duration(series.CustomerWaitBusiness) * time.Second
+
+	if series.CustomerWaitSince != 0 {
+		since := time.Unix(max(series.CustomerWaitSince, series.Created), 0)
+		wall += time.Duration(now.Unix()-since.Unix()) * time.Second
+		if business != nil {
+			working += business.duration(since, now)
+		}
+	}
+
+	return wall, working
+}
@@ -0,0 +1,40 @@
+package main
+
+import "github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+
+// TicketSource is the backend tickets and their enrichment data are collected
+// from. The SupportPal API client implements it; other SupportPal compatible
+// backends, mocks and recorded fixtures can be served through the same
+// collection and exposition pipeline by implementing it.
+type TicketSource interface {
+	// ListTickets lists a page of tickets, newest first
+	ListTickets(start, limit int) (*supportpal.ListTicketsResponse, error)
+	// FetchTickets fetches tickets newest first while inBound returns true
+	FetchTickets(inBound func(*supportpal.Ticket) bool) ([]*supportpal.Ticket, error)
+	// StreamTickets passes tickets to fn newest first while inBound returns
+	// true, one page at a time
+	StreamTickets(inBound func(*supportpal.Ticket) bool, fn func(*supportpal.Ticket)) error
+	// GetTicket gets a single ticket, wrapping supportpal.ErrNotFound if it does not exist
+	GetTicket(id int) (*supportpal.Ticket, error)
+	// GetOrganization gets an organization, wrapping supportpal.ErrNotFound if it was deleted
+	GetOrganization(id int) (*supportpal.GetOrganizationResponse, error)
+	// GetCustomField gets a custom field, wrapping supportpal.ErrNotFound if it was deleted
+	GetCustomField(id int) (*supportpal.GetCustomFieldResponse, error)
+	// ListCustomFields lists all ticket custom fields
+	ListCustomFields() ([]*supportpal.CustomField, error)
+	// ListMessages lists the messages of a ticket, oldest first
+	ListMessages(ticketID int) ([]*supportpal.Message, error)
+	// TicketMessages lists the messages of a ticket like ListMessages, it may
+	// answer from a cache as long as the ticket was not updated
+	TicketMessages(ticket *supportpal.Ticket) ([]*supportpal.Message, error)
+	// TicketLogs lists the audit log of a ticket, oldest first, it may answer
+	// from a cache as long as the ticket was not updated
+	TicketLogs(ticket *supportpal.Ticket) ([]*supportpal.TicketLog, error)
+	// ListFeedback lists the ticket feedback created at or after since, newest first
+	ListFeedback(since int64) ([]*supportpal.Feedback, error)
+}
+
+// source is the backend the collection cycle reads tickets from
+var source TicketSource
+
+var _ TicketSource = (*supportpal.Client)(nil)
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	supportPalCollectionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "supportpal_collection_errors_total",
+		Help: "Number of failed API requests during collection by stage, the affected data is kept from earlier cycles where possible",
+	}, []string{"stage"})
+
+	supportPalDataStale = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "supportpal_data_stale",
+		Help: "Whether the exported data holds values of earlier cycles because the last collection failed partially or completely",
+	})
+
+	supportPalSnapshotInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "supportpal_snapshot_info",
+		Help: "Origin of the served ticket metrics, stale is true while the snapshot persisted by an earlier run is served until the first collection completes, always 1",
+	}, []string{"stale"})
+)
+
+// setSnapshotStale records whether the served snapshot was persisted by an
+// earlier run of the exporter
+func setSnapshotStale(stale bool) {
+	supportPalSnapshotInfo.Reset()
+	supportPalSnapshotInfo.WithLabelValues(strconv.FormatBool(stale)).Set(1)
+	if stale {
+		supportPalDataStale.Set(1)
+	}
+}
+
+// cycleErrors is the number of collection errors of the running cycle
+var cycleErrors atomic.Int64
+
+// collectionError counts a failed API request of a collection stage
+func collectionError(stage string) {
+	supportPalCollectionErrors.WithLabelValues(stage).Inc()
+	cycleErrors.Add(1)
+}
+
+// unknownOrganizationLabel is the client label of tickets whose organization
+// lookup failed without a last known value
+const unknownOrganizationLabel = "unknown"
+
+// lastKnownValues holds the last successfully fetched organizations and
+// custom fields, used when a lookup fails
+type lastKnownValues struct {
+	mu            sync.Mutex
+	organizations map[int]*supportpal.GetOrganizationResponse
+	customFields  map[int]*supportpal.GetCustomFieldResponse
+}
+
+var lastKnown = &lastKnownValues{
+	organizations: make(map[int]*supportpal.GetOrganizationResponse),
+	customFields:  make(map[int]*supportpal.GetCustomFieldResponse),
+}
+
+// organization looks up an organization, falling back to its last known
+// value when the lookup fails with an error other than supportpal.ErrNotFound
+func (l *lastKnownValues) organization(id int) (*supportpal.GetOrganizationResponse, error) {
+	org, err := source.GetOrganization(id)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil {
+		l.organizations[id] = org
+		return org, nil
+	}
+	if errors.Is(err, supportpal.ErrNotFound) {
+		delete(l.organizations, id)
+		return nil, err
+	}
+
+	collectionError("organization")
+	if org, ok := l.organizations[id]; ok {
+		slog.Warn("using last known organization", "organization_id", id, "err", err)
+		return org, nil
+	}
+
+	return nil, err
+}
+
+// knownCustomFields returns the last known custom fields ordered by ID
+func (l *lastKnownValues) knownCustomFields() []*supportpal.GetCustomFieldResponse {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fields := make([]*supportpal.GetCustomFieldResponse, 0, len(l.customFields))
+	for _, field := range l.customFields {
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b *supportpal.GetCustomFieldResponse) int { return a.Data.ID - b.Data.ID })
+
+	return fields
+}
+
+// customField looks up a custom field, falling back to its last known value
+// when the lookup fails with an error other than supportpal.ErrNotFound
+func (l *lastKnownValues) customField(id int) (*supportpal.GetCustomFieldResponse, error) {
+	field, err := source.GetCustomField(id)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil {
+		l.customFields[id] = field
+		return field, nil
+	}
+	if errors.Is(err, supportpal.ErrNotFound) {
+		delete(l.customFields, id)
+		return nil, err
+	}
+
+	collectionError("customfield")
+	if field, ok := l.customFields[id]; ok {
+		slog.Warn("using last known custom field", "field_id", id, "err", err)
+		return field, nil
+	}
+
+	return nil, err
+}
+
+// keepMessageFields copies the fields computed from messages and the
+// last_reply_by label of the ticket from the previous snapshot
+func keepMessageFields(labels prometheus.Labels, series *ticketSeries) bool {
+	prev := supportPalTickets.current.Load()
+	if prev == nil {
+		return false
+	}
+
+	old := prev.ticket(series.ID)
+	if old == nil || !old.MessagesFetched {
+		return false
+	}
+
+	series.MessagesFetched = true
+	series.FirstResponse = old.FirstResponse
+	series.FirstResponseBusiness = old.FirstResponseBusiness
+	series.FirstResponder = old.FirstResponder
+	series.CustomerWait = old.CustomerWait
+	series.CustomerWaitBusiness = old.CustomerWaitBusiness
+	series.CustomerWaitSince = old.CustomerWaitSince
+	series.LastResponse = old.LastResponse
+	series.Replies = old.Replies
+
+	if slices.Contains(prev.schema(old), "last_reply_by") {
+		labels["last_reply_by"] = prev.labelValue(old, "last_reply_by")
+	}
+
+	return true
+}
+
+// keepReassignments copies the reassignments of the ticket from the previous
+// snapshot after its audit log could not be listed
+func keepReassignments(series *ticketSeries) {
+	prev := supportPalTickets.current.Load()
+	if prev == nil {
+		return
+	}
+
+	if old := prev.ticket(series.ID); old != nil && old.ReassignmentsFetched {
+		series.ReassignmentsFetched = true
+		series.Reassignments = old.Reassignments
+	}
+}
+
+// keepRatings copies the ratings of the tickets from the previous snapshot
+func keepRatings(entries []ticketEntry) {
+	prev := supportPalTickets.current.Load()
+	if prev == nil {
+		return
+	}
+
+	byID := make(map[int]*ticketSeries, len(prev.Series))
+	for _, old := range prev.Series {
+		byID[old.ID] = old
+	}
+
+	for _, e := range entries {
+		if old, ok := byID[e.series.ID]; ok {
+			e.series.Rated, e.series.Rating, e.series.RatedOperator = old.Rated, old.Rating, old.RatedOperator
+		}
+	}
+}
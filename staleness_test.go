@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+)
+
+// failingOrganizations serves the fixtures with every organization lookup
+// failing
+type failingOrganizations struct {
+	*fixtureSource
+}
+
+func (failingOrganizations) GetOrganization(id int) (*supportpal.GetOrganizationResponse, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestBuildTicketWithFailedOrganization(t *testing.T) {
+	defer func(s TicketSource) { source = s }(source)
+	source = failingOrganizations{newFixtureSource(t)}
+
+	ticket := &supportpal.Ticket{ID: 1}
+	ticket.User.OrganizationID = 987654
+
+	labels, series := buildTicket(ticket)
+	defer releaseLabels(labels)
+
+	if labels["client"] != unknownOrganizationLabel {
+		t.Errorf("client = %q, want %q", labels["client"], unknownOrganizationLabel)
+	}
+	if series.Organization != "" {
+		t.Errorf("organization = %q, want empty", series.Organization)
+	}
+}
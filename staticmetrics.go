@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// staticMetric is a constant gauge declared in the static metrics file,
+// e.g. the team owning the deployment or its environment
+type staticMetric struct {
+	Name   string            `yaml:"name"`
+	Help   string            `yaml:"help"`
+	Labels map[string]string `yaml:"labels"`
+	Value  float64           `yaml:"value"`
+}
+
+// staticMetricsFile is the file format of --metrics.static-file
+type staticMetricsFile struct {
+	Metrics []staticMetric `yaml:"metrics"`
+}
+
+// loadStaticMetrics reads and validates a static metrics file. Metrics of the
+// same name must have the same help and label names, like any metric family.
+func loadStaticMetrics(path string) ([]staticMetric, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f staticMetricsFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	families := make(map[string]staticMetric)
+	series := make(map[string]bool)
+	for i, m := range f.Metrics {
+		if !model.IsValidMetricName(model.LabelValue(m.Name)) {
+			return nil, fmt.Errorf("%s: metric %d: invalid name %q", path, i+1, m.Name)
+		}
+		for name := range m.Labels {
+			if !model.LabelName(name).IsValid() {
+				return nil, fmt.Errorf("%s: metric %s: invalid label name %q", path, m.Name, name)
+			}
+		}
+
+		if m.Help == "" {
+			f.Metrics[i].Help = "Static metric declared in the exporter configuration"
+			m = f.Metrics[i]
+		}
+
+		key := m.Name + fmt.Sprint(m.Labels)
+		if series[key] {
+			return nil, fmt.Errorf("%s: metric %s is declared twice with the same labels", path, m.Name)
+		}
+		series[key] = true
+
+		if first, ok := families[m.Name]; ok {
+			if first.Help != m.Help || !slices.Equal(labelNames(first.Labels), labelNames(m.Labels)) {
+				return nil, fmt.Errorf("%s: metric %s is declared with different help or label names", path, m.Name)
+			}
+			continue
+		}
+		families[m.Name] = m
+	}
+
+	return f.Metrics, nil
+}
+
+// labelNames returns the names of labels in order
+func labelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// staticCollector exposes the static metrics
+type staticCollector struct {
+	metrics []prometheus.Metric
+	descs   []*prometheus.Desc
+}
+
+// newStaticCollector returns a collector of the given static metrics
+func newStaticCollector(metrics []staticMetric) *staticCollector {
+	c := &staticCollector{}
+	for _, m := range metrics {
+		desc := prometheus.NewDesc(m.Name, m.Help, nil, m.Labels)
+		c.descs = append(c.descs, desc)
+		c.metrics = append(c.metrics, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, m.Value))
+	}
+
+	return c
+}
+
+// Describe implements prometheus.Collector
+func (c *staticCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *staticCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
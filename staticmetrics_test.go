@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStaticMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "static.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`metrics:
+  - name: supportpal_deployment_info
+    labels: {team: support-tools, environment: production}
+    value: 1
+  - name: supportpal_deployment_info
+    labels: {team: helpdesk, environment: staging}
+    value: 1
+  - name: supportpal_ticket_sla_target_seconds
+    help: Agreed first response time
+    value: 3600
+`)
+	metrics, err := loadStaticMetrics(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(newStaticCollector(metrics))
+	want := `# HELP supportpal_deployment_info Static metric declared in the exporter configuration
+# TYPE supportpal_deployment_info gauge
+supportpal_deployment_info{environment="production",team="support-tools"} 1
+supportpal_deployment_info{environment="staging",team="helpdesk"} 1
+# HELP supportpal_ticket_sla_target_seconds Agreed first response time
+# TYPE supportpal_ticket_sla_target_seconds gauge
+supportpal_ticket_sla_target_seconds 3600
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	for name, content := range map[string]string{
+		"invalid name":       `metrics: [{name: "team-info", value: 1}]`,
+		"invalid label name": `metrics: [{name: team_info, labels: {"team name": a}, value: 1}]`,
+		"duplicate series":   `metrics: [{name: team_info, labels: {team: a}, value: 1}, {name: team_info, labels: {team: a}, value: 2}]`,
+		"different labels":   `metrics: [{name: team_info, labels: {team: a}, value: 1}, {name: team_info, labels: {owner: a}, value: 1}]`,
+		"different help":     `metrics: [{name: team_info, help: a, value: 1}, {name: team_info, help: b, labels: {team: a}, value: 1}]`,
+	} {
+		write(content)
+		if _, err := loadStaticMetrics(path); err == nil {
+			t.Errorf("%s: loaded without error", name)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"maps"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// startedAt is the time the exporter started
+var startedAt = time.Now()
+
+// status describes the state of the exporter for remote debugging
+type status struct {
+	Version     string     `json:"version"`
+	GoVersion   string     `json:"go_version"`
+	StartedAt   time.Time  `json:"started_at"`
+	CollectedAt *time.Time `json:"collected_at,omitempty"`
+	Ready       bool       `json:"ready"`
+	Tickets     int        `json:"tickets"`
+	PageSize    int        `json:"page_size"`
+	// Shard is the shard of the tickets collected as index/count
+	Shard string `json:"shard,omitempty"`
+	// Role is leader or standby with --ha.lock
+	Role string `json:"role,omitempty"`
+	// LastCycle is the report of the last collection cycle, nil before the
+	// first one completed
+	LastCycle *cycleReport `json:"last_cycle,omitempty"`
+	// Series is the number of ticket metric series served by family
+	Series map[string]int `json:"series,omitempty"`
+	// Labels is the label schema of the served tickets
+	Labels *labelReport `json:"labels,omitempty"`
+	// RecentErrors are the most recent failed API requests, newest first
+	RecentErrors []supportpal.APIError `json:"recent_errors"`
+}
+
+// cycleReport describes a collection cycle
+type cycleReport struct {
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Duration  string    `json:"duration"`
+	Succeeded bool      `json:"succeeded"`
+	// Error is the error that failed the cycle
+	Error   string `json:"error,omitempty"`
+	Tickets int    `json:"tickets"`
+	// Errors are the collection errors of the cycle by stage
+	Errors map[string]float64 `json:"errors"`
+	// API are the ticket pages, requests and cache lookups of the cycle
+	API supportpal.Stats `json:"api"`
+	// CacheHitRatio is the share of the lookups of every cache answered
+	// from the cache
+	CacheHitRatio map[string]float64 `json:"cache_hit_ratio"`
+
+	apiBefore    supportpal.Stats
+	errorsBefore map[string]float64
+}
+
+// labelReport describes the label schema of the served tickets
+type labelReport struct {
+	Names []string `json:"names"`
+	// Departments holds the schema of every department with department label sets
+	Departments map[string][]string `json:"departments,omitempty"`
+	// Deprecated are the labels no ticket uses anymore, exported empty until
+	// their grace period ends
+	Deprecated []string `json:"deprecated,omitempty"`
+	// Truncated are the custom field labels whose values were truncated to
+	// --customfields.max-values
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+// lastCycle is the report of the last collection cycle
+var lastCycle atomic.Pointer[cycleReport]
+
+// newCycleReport starts the report of a collection cycle
+func newCycleReport() *cycleReport {
+	return &cycleReport{
+		Started:      time.Now(),
+		apiBefore:    supportpal.ReadStats(),
+		errorsBefore: collectionErrorCounts(),
+	}
+}
+
+// finish completes the report with the requests and errors since it started
+// and publishes it as the last cycle
+func (r *cycleReport) finish() {
+	r.Finished = time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Round(time.Millisecond).String()
+	r.API = supportpal.ReadStats().Sub(r.apiBefore)
+
+	r.Errors = make(map[string]float64)
+	for stage, n := range collectionErrorCounts() {
+		if d := n - r.errorsBefore[stage]; d > 0 {
+			r.Errors[stage] = d
+		}
+	}
+
+	r.CacheHitRatio = make(map[string]float64)
+	for cache, misses := range r.API.CacheMisses {
+		r.CacheHitRatio[cache] = r.API.CacheHits[cache] / (r.API.CacheHits[cache] + misses)
+	}
+	for cache := range r.API.CacheHits {
+		if _, ok := r.CacheHitRatio[cache]; !ok {
+			r.CacheHitRatio[cache] = 1
+		}
+	}
+
+	lastCycle.Store(r)
+}
+
+// collectionErrorCounts returns the collection errors since the start by stage
+func collectionErrorCounts() map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		supportPalCollectionErrors.Collect(ch)
+		close(ch)
+	}()
+
+	counts := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err == nil && len(m.Label) == 1 {
+			counts[m.Label[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+
+	return counts
+}
+
+// seriesCounts returns the number of series the ticket collector serves by
+// family name
+func seriesCounts() map[string]int {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(supportPalTickets)
+	families, _ := registry.Gather()
+
+	counts := make(map[string]int, len(families))
+	for _, family := range families {
+		counts[family.GetName()] = len(family.Metric)
+	}
+
+	return counts
+}
+
+// newLabelReport returns the label schema of s
+func newLabelReport(s *snapshot) *labelReport {
+	return &labelReport{
+		Names:       s.LabelNames,
+		Departments: s.Groups,
+		Deprecated:  slices.Sorted(maps.Keys(s.Deprecated)),
+		Truncated:   slices.Sorted(maps.Keys(s.KeptValues)),
+	}
+}
+
+// statusPage renders the status as HTML
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><title>SupportPal Exporter status</title></head>
+<body>
+<h1>SupportPal Exporter status</h1>
+<p>Version {{.Version}} ({{.GoVersion}}), started {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}.
+{{if .Ready}}Serving {{.Tickets}} tickets collected {{.CollectedAt.Format "2006-01-02 15:04:05 MST"}}.{{else}}Initial collection in progress.{{end}}
+Page size {{.PageSize}}{{with .Shard}}, shard {{.}}{{end}}{{with .Role}}, {{.}}{{end}}.</p>
+{{with .LastCycle}}
+<h2>Last collection cycle</h2>
+<table>
+<tr><th align="left">Started</th><td>{{.Started.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th align="left">Duration</th><td>{{.Duration}}</td></tr>
+<tr><th align="left">Result</th><td>{{if .Succeeded}}succeeded{{else}}failed: {{.Error}}{{end}}</td></tr>
+<tr><th align="left">Tickets</th><td>{{.Tickets}}</td></tr>
+<tr><th align="left">Pages</th><td>{{.API.Pages}}</td></tr>
+<tr><th align="left">Requests</th><td>{{range $code, $n := .API.Requests}}{{$code}}: {{$n}} {{end}}</td></tr>
+<tr><th align="left">Errors</th><td>{{range $stage, $n := .Errors}}{{$stage}}: {{$n}} {{else}}none{{end}}</td></tr>
+<tr><th align="left">Cache hit ratio</th><td>{{range $cache, $r := .CacheHitRatio}}{{$cache}}: {{printf "%.2f" $r}} {{end}}</td></tr>
+</table>
+{{end}}
+{{with .Series}}
+<h2>Series</h2>
+<table>{{range $name, $n := .}}<tr><td>{{$name}}</td><td align="right">{{$n}}</td></tr>{{end}}</table>
+{{end}}
+{{with .Labels}}
+<h2>Labels</h2>
+<p>{{range .Names}}<code>{{.}}</code> {{end}}</p>
+{{with .Deprecated}}<p>Deprecated: {{range .}}<code>{{.}}</code> {{end}}</p>{{end}}
+{{with .Truncated}}<p>Truncated: {{range .}}<code>{{.}}</code> {{end}}</p>{{end}}
+{{range $department, $names := .Departments}}<p>{{$department}}: {{range $names}}<code>{{.}}</code> {{end}}</p>{{end}}
+{{end}}
+<h2>Recent API errors</h2>
+<table>
+{{range .RecentErrors}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Method}} {{.Path}}</td><td>{{.Code}}</td><td>{{.Error}}</td></tr>
+{{else}}<tr><td>none</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// statusHandler serves the state of the exporter, the report of the last
+// collection cycle, the served series and labels and the most recent API
+// errors as indented JSON, readable on screenshots, or as HTML to browsers
+// and with ?format=html
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	st := status{
+		Version:      exporterVersion(),
+		GoVersion:    runtime.Version(),
+		StartedAt:    startedAt,
+		PageSize:     apiClient.PageSize(),
+		Shard:        config.Shard.String(),
+		Role:         leaderRole(),
+		LastCycle:    lastCycle.Load(),
+		RecentErrors: apiClient.RecentErrors(),
+	}
+
+	if s := supportPalTickets.current.Load(); s != nil {
+		st.CollectedAt = &s.CollectedAt
+		st.Ready = true
+		st.Tickets = len(s.Series)
+		st.Series = seriesCounts()
+		st.Labels = newLabelReport(s)
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "html" || format == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusPage.Execute(w, st); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(st); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// readyHandler answers 200 once ticket metrics are served, from the first
+// collection or a persisted snapshot, and 503 while the initial collection
+// still runs. The self-metrics on /metrics are served in both cases.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if supportPalTickets.current.Load() == nil {
+		http.Error(w, "initial collection in progress", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("ready\n"))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statusDurationBuckets are the upper bounds of the time in status histogram
+var statusDurationBuckets = []time.Duration{
+	time.Hour, 4 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 2 * 24 * time.Hour, 4 * 24 * time.Hour, 7 * 24 * time.Hour, 14 * 24 * time.Hour, 30 * 24 * time.Hour,
+}
+
+// statusDuration is the distribution of the time tickets of a department
+// spent in a status before it changed. Sum and the bucket bounds are in
+// seconds, the configured duration unit is applied when it is exported.
+type statusDuration struct {
+	Status     string  `json:"status"`
+	Department string  `json:"department"`
+	Count      uint64  `json:"count"`
+	Sum        float64 `json:"sum"`
+	// Buckets holds the cumulative count of every statusDurationBuckets bound
+	Buckets []uint64 `json:"buckets"`
+}
+
+// statusDurationTracker accumulates the time tickets spent in a status
+// across collection cycles. It is persisted with the state, so the
+// histogram continues across restarts like the lifecycle counters.
+type statusDurationTracker struct {
+	mu         sync.Mutex
+	histograms map[[2]string]*statusDuration
+}
+
+var statusDurations = &statusDurationTracker{histograms: make(map[[2]string]*statusDuration)}
+
+// observe records that a ticket of department left status after d
+func (t *statusDurationTracker) observe(status, department string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := [2]string{status, department}
+	h, ok := t.histograms[key]
+	if !ok {
+		h = &statusDuration{Status: status, Department: department, Buckets: make([]uint64, len(statusDurationBuckets))}
+		t.histograms[key] = h
+	}
+
+	h.Count++
+	h.Sum += d.Seconds()
+	for i, bound := range statusDurationBuckets {
+		if d <= bound {
+			h.Buckets[i]++
+		}
+	}
+}
+
+// Describe implements prometheus.Collector. The metric name depends on the
+// duration unit, so the collector is unchecked.
+func (t *statusDurationTracker) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (t *statusDurationTracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	desc := prometheus.NewDesc(durationName("supportpal_ticket_status_duration"),
+		durationHelp("Time tickets spent in a status before it changed"), []string{"status", "department"}, nil)
+	perSecond := durationUnit().perSecond
+
+	for _, h := range t.histograms {
+		buckets := make(map[float64]uint64, len(statusDurationBuckets))
+		for i, bound := range statusDurationBuckets {
+			buckets[bound.Seconds()*perSecond] = h.Buckets[i]
+		}
+
+		ch <- prometheus.MustNewConstHistogram(desc, h.Count, h.Sum*perSecond, buckets, h.Status, h.Department)
+	}
+}
+
+// export returns a copy of the histograms for persistence, ordered by
+// status and department
+func (t *statusDurationTracker) export() []statusDuration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	histograms := make([]statusDuration, 0, len(t.histograms))
+	for _, h := range t.histograms {
+		c := *h
+		c.Buckets = append([]uint64(nil), h.Buckets...)
+		histograms = append(histograms, c)
+	}
+	sort.Slice(histograms, func(i, j int) bool {
+		if histograms[i].Status != histograms[j].Status {
+			return histograms[i].Status < histograms[j].Status
+		}
+		return histograms[i].Department < histograms[j].Department
+	})
+
+	return histograms
+}
+
+// restore replaces the histograms with persisted ones. Histograms persisted
+// with other bucket bounds are dropped.
+func (t *statusDurationTracker) restore(histograms []statusDuration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.histograms = make(map[[2]string]*statusDuration, len(histograms))
+	for _, h := range histograms {
+		if len(h.Buckets) != len(statusDurationBuckets) {
+			continue
+		}
+		t.histograms[[2]string{h.Status, h.Department}] = &h
+	}
+}
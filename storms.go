@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var supportPalTicketStormDesc = prometheus.NewDesc("supportpal_ticket_storm",
+	"Number of tickets created within the storm window by the senders of a domain that each created at least the storm threshold of tickets, only exported while one did",
+	[]string{"sender_domain"}, nil)
+
+// senderDomain returns the lowercase domain of an email address or "" if it has none
+func senderDomain(email string) string {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// senderKey identifies the sender of a ticket by a hash of its lowercase
+// email address, so the address itself is not kept. It is "" without address.
+func senderKey(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return hashValue(email)
+}
+
+// collectStorms sends the number of recent tickets of the senders that
+// created at least the threshold of tickets within the storm window, by the
+// domain of their address. Ordinary mail from many senders of a domain, such
+// as a freemail provider, is no storm.
+func collectStorms(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if config.StormThreshold <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-config.StormWindow).Unix()
+	recent := make(map[string]int)
+	domains := make(map[string]string)
+
+	for _, series := range s.Series {
+		if series.Sender == "" || series.Created < cutoff {
+			continue
+		}
+		recent[series.Sender]++
+		domains[series.Sender] = series.SenderDomain
+	}
+
+	storms := make(labelCounts)
+	for sender, n := range recent {
+		if n >= config.StormThreshold {
+			storms.add(float64(n), domains[sender])
+		}
+	}
+
+	storms.emit(ch, supportPalTicketStormDesc, prometheus.GaugeValue)
+}
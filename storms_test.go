@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// stormCollector collects the storms of a snapshot
+type stormCollector struct{ s *snapshot }
+
+func (c stormCollector) Describe(ch chan<- *prometheus.Desc) { ch <- supportPalTicketStormDesc }
+
+func (c stormCollector) Collect(ch chan<- prometheus.Metric) { collectStorms(ch, c.s, time.Now()) }
+
+func TestStorms(t *testing.T) {
+	defer func(c Config) { config = c }(config)
+	config = Config{StormThreshold: 3, StormWindow: 10 * time.Minute}
+
+	now := time.Now().Unix()
+	s := newSnapshot(nil)
+	add := func(email string, created int64) {
+		s.Series = append(s.Series, &ticketSeries{ID: len(s.Series) + 1, Created: created,
+			SenderDomain: senderDomain(email), Sender: senderKey(email)})
+	}
+	// a looping auto-responder, many customers of a freemail provider and
+	// an old burst
+	for range 4 {
+		add("Robot@Loop.example", now)
+	}
+	for _, user := range []string{"a", "b", "c", "d"} {
+		add(user+"@gmail.com", now)
+	}
+	for range 3 {
+		add("old@burst.example", now-3600)
+	}
+
+	want := `
+# HELP supportpal_ticket_storm Number of tickets created within the storm window by the senders of a domain that each created at least the storm threshold of tickets, only exported while one did
+# TYPE supportpal_ticket_storm gauge
+supportpal_ticket_storm{sender_domain="loop.example"} 4
+`
+	if err := testutil.CollectAndCompare(stormCollector{s}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// departmentSummary holds the ticket counts of a department
+type departmentSummary struct {
+	Total    int            `json:"total"`
+	Open     int            `json:"open"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// slaSummary describes how many tickets met their due time
+type slaSummary struct {
+	Tickets         int     `json:"tickets"`
+	Breached        int     `json:"breached"`
+	ComplianceRatio float64 `json:"compliance_ratio"`
+}
+
+// summary is a compact overview of the snapshot for status pages
+type summary struct {
+	GeneratedAt           time.Time                     `json:"generated_at"`
+	Total                 int                           `json:"total"`
+	Open                  int                           `json:"open"`
+	ByStatus              map[string]int                `json:"by_status"`
+	ByDepartment          map[string]*departmentSummary `json:"by_department"`
+	OldestWaitingSeconds  int64                         `json:"oldest_waiting_seconds"`
+	OldestWaitingTicketID int                           `json:"oldest_waiting_ticket_id,omitempty"`
+	SLA                   slaSummary                    `json:"sla"`
+}
+
+// summarize computes the summary of s at now
+func summarize(s *snapshot, now time.Time) *summary {
+	sum := &summary{
+		GeneratedAt:  s.CollectedAt,
+		ByStatus:     make(map[string]int),
+		ByDepartment: make(map[string]*departmentSummary),
+	}
+
+	for _, series := range s.Series {
+		if series.Deleted != 0 {
+			continue
+		}
+
+		dept, ok := sum.ByDepartment[series.Department]
+		if !ok {
+			dept = &departmentSummary{ByStatus: make(map[string]int)}
+			sum.ByDepartment[series.Department] = dept
+		}
+
+		sum.Total++
+		dept.Total++
+		sum.ByStatus[series.Status]++
+		dept.ByStatus[series.Status]++
+
+		if series.open() {
+			sum.Open++
+			dept.Open++
+
+			if waiting := now.Unix() - series.Created; waiting > sum.OldestWaitingSeconds {
+				sum.OldestWaitingSeconds = waiting
+				sum.OldestWaitingTicketID = series.ID
+			}
+		}
+
+		if series.Due != 0 {
+			sum.SLA.Tickets++
+
+			if (series.open() && now.Unix() > series.Due) || (!series.open() && series.Resolved > series.Due) {
+				sum.SLA.Breached++
+			}
+		}
+	}
+
+	if sum.SLA.Tickets > 0 {
+		sum.SLA.ComplianceRatio = float64(sum.SLA.Tickets-sum.SLA.Breached) / float64(sum.SLA.Tickets)
+	}
+
+	return sum
+}
+
+// summaryHandler serves the summary of the current snapshot as JSON
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	s := supportPalTickets.current.Load()
+	if s == nil {
+		http.Error(w, "no data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(collectionInterval.Seconds())))
+	w.Header().Set("Last-Modified", s.CollectedAt.UTC().Format(http.TimeFormat))
+
+	if err := json.NewEncoder(w).Encode(summarize(s, time.Now())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
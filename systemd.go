@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// systemdNotifier sends service state notifications to systemd through the
+// socket in NOTIFY_SOCKET, see sd_notify(3). It does nothing when the
+// exporter is not started by systemd with Type=notify or NotifyAccess set.
+type systemdNotifier struct {
+	socket string
+	// watchdog is the WatchdogSec of the unit, 0 if it is not set
+	watchdog time.Duration
+	ready    sync.Once
+}
+
+// notifier is the systemd notifier, nil unless --systemd.notify is set
+var notifier *systemdNotifier
+
+// newSystemdNotifier returns a notifier for the socket and watchdog passed by
+// systemd in the environment
+func newSystemdNotifier() *systemdNotifier {
+	n := &systemdNotifier{socket: os.Getenv("NOTIFY_SOCKET")}
+
+	// the watchdog applies to the main process only
+	pid := os.Getenv("WATCHDOG_PID")
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && (pid == "" || pid == strconv.Itoa(os.Getpid())) {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+
+	return n
+}
+
+// notify sends a state like READY=1 to systemd. Failures are logged, since
+// systemd restarts or fails the service on missing notifications anyway.
+func (n *systemdNotifier) notify(state string) {
+	if n == nil || n.socket == "" {
+		return
+	}
+
+	socket := n.socket
+	if socket[0] == '@' {
+		// abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		slog.Warn("failed to notify systemd", "state", state, "err", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Warn("failed to notify systemd", "state", state, "err", err)
+	}
+}
+
+// notifyReady tells systemd that the exporter is ready, only the first call
+// notifies
+func (n *systemdNotifier) notifyReady() {
+	if n == nil {
+		return
+	}
+
+	n.ready.Do(func() {
+		n.notify("READY=1")
+		slog.Info("notified systemd of readiness", "watchdog", n.watchdog)
+	})
+}
+
+// notifyAlive resets the watchdog of systemd, if the unit has one
+func (n *systemdNotifier) notifyAlive() {
+	if n == nil || n.watchdog == 0 {
+		return
+	}
+
+	n.notify("WATCHDOG=1")
+}
+
+// cycleDone tells systemd that a collection cycle completed: the exporter is
+// ready once the first cycle completed and the watchdog is reset by every
+// cycle, so a collection loop that got stuck is restarted by systemd
+func (n *systemdNotifier) cycleDone() {
+	n.notifyReady()
+	n.notifyAlive()
+}
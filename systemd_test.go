@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSystemdNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unix datagram sockets")
+	}
+
+	// socket paths are limited to about 100 bytes, more than t.TempDir()
+	// leaves on some systems
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	n := newSystemdNotifier()
+	if n.watchdog != 30*time.Second {
+		t.Errorf("watchdog = %v, want 30s", n.watchdog)
+	}
+
+	// readiness is notified once, the watchdog on every cycle
+	n.cycleDone()
+	n.cycleDone()
+	var states []string
+	buf := make([]byte, 64)
+	for range 3 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		size, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, string(buf[:size]))
+	}
+	if want := []string{"READY=1", "WATCHDOG=1", "WATCHDOG=1"}; !slices.Equal(states, want) {
+		t.Errorf("notified %q, want %q", states, want)
+	}
+
+	// the watchdog of another process is left alone
+	t.Setenv("WATCHDOG_PID", "1")
+	if n := newSystemdNotifier(); n.watchdog != 0 {
+		t.Errorf("watchdog of another process = %v, want 0", n.watchdog)
+	}
+
+	// without systemd nothing is sent
+	var none *systemdNotifier
+	none.cycleDone()
+	t.Setenv("NOTIFY_SOCKET", "")
+	newSystemdNotifier().cycleDone()
+}
+
+func TestJournaldHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(newJournaldHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Error("failed", "err", "timeout")
+	logger.With("cycle", 2).Info("collected")
+
+	want := "<3>level=ERROR msg=failed err=timeout\n<6>level=INFO msg=collected cycle=2\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// telemetryTimeout bounds a single telemetry request
+const telemetryTimeout = 10 * time.Second
+
+// lastCycleDuration is the duration of the last successful collection cycle
+var lastCycleDuration atomic.Int64
+
+// telemetryPacket is the anonymized usage report of an exporter instance. It
+// holds no ticket data, names or URLs.
+type telemetryPacket struct {
+	InstanceID           string  `json:"instance_id"`
+	Version              string  `json:"version"`
+	GoVersion            string  `json:"go_version"`
+	TicketCountBucket    string  `json:"ticket_count_bucket"`
+	CycleDurationSeconds float64 `json:"cycle_duration_seconds"`
+}
+
+// ticketCountBucket returns the power of ten range n falls in, e.g. 100-999
+func ticketCountBucket(n int) string {
+	if n == 0 {
+		return "0"
+	}
+
+	low := 1
+	for low*10 <= n {
+		low *= 10
+	}
+
+	return strconv.Itoa(low) + "-" + strconv.Itoa(low*10-1)
+}
+
+// newInstanceID returns a random identifier for an exporter instance
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sendTelemetry reports the usage statistics of the exporter to the
+// configured endpoint every telemetry interval, starting one collection
+// interval after startup
+func sendTelemetry() {
+	instanceID := config.TelemetryInstanceID
+	if instanceID == "" {
+		instanceID = newInstanceID()
+	}
+
+	httpClient := &http.Client{Timeout: telemetryTimeout}
+
+	time.Sleep(collectionInterval)
+	for {
+		tickets := 0
+		if s := supportPalTickets.current.Load(); s != nil {
+			tickets = len(s.Series)
+		}
+
+		packet := telemetryPacket{
+			InstanceID:           instanceID,
+			Version:              exporterVersion(),
+			GoVersion:            runtime.Version(),
+			TicketCountBucket:    ticketCountBucket(tickets),
+			CycleDurationSeconds: time.Duration(lastCycleDuration.Load()).Seconds(),
+		}
+
+		if err := postTelemetry(httpClient, packet); err != nil {
+			slog.Warn("failed to send telemetry", "endpoint", config.TelemetryEndpoint, "err", err)
+		} else {
+			slog.Debug("sent telemetry", "endpoint", config.TelemetryEndpoint)
+		}
+
+		time.Sleep(config.TelemetryInterval)
+	}
+}
+
+// postTelemetry posts packet as JSON to the telemetry endpoint
+func postTelemetry(httpClient *http.Client, packet telemetryPacket) error {
+	body, err := json.Marshal(packet)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(config.TelemetryEndpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// textfileName is the file the textfile sink writes in its directory
+const textfileName = "supportpal.prom"
+
+// textfileSink writes the exposition of all metrics to a file for the
+// textfile collector of node_exporter
+type textfileSink struct {
+	dir      string
+	gatherer prometheus.Gatherer
+}
+
+// Name implements Sink
+func (textfileSink) Name() string { return "textfile" }
+
+// Publish implements Sink. The file is replaced atomically, so the textfile
+// collector never reads a partially written file. Runtime metrics of the Go
+// process are left out, node_exporter exposes its own under the same names,
+// and sample timestamps, which the textfile collector rejects.
+func (t textfileSink) Publish(s *snapshot) error {
+	families, err := t.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, family := range withoutTimestamps(withoutRuntimeMetrics(families)) {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(filepath.Join(t.dir, textfileName), buf.Bytes())
+}
+
+// withoutRuntimeMetrics removes the process and Go runtime metrics of
+// client_golang from families
+func withoutRuntimeMetrics(families []*dto.MetricFamily) []*dto.MetricFamily {
+	exported := families[:0]
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasPrefix(name, "go_") && !strings.HasPrefix(name, "process_") && !strings.HasPrefix(name, "promhttp_") {
+			exported = append(exported, family)
+		}
+	}
+
+	return exported
+}
+
+// withoutTimestamps removes the sample timestamps from families, for outputs
+// that only take samples at the time they are read
+func withoutTimestamps(families []*dto.MetricFamily) []*dto.MetricFamily {
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			metric.TimestampMs = nil
+		}
+	}
+
+	return families
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file in the same directory. The textfile collector only reads files ending
+// in .prom and skips the temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// node_exporter usually runs as another user
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// firstResponseBuckets are the upper bounds of the first response histogram
+var firstResponseBuckets = []time.Duration{
+	5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour,
+	4 * time.Hour, 8 * time.Hour, 24 * time.Hour, 2 * 24 * time.Hour,
+}
+
+// nativeHistogramBucketFactor is the maximum growth from one native
+// histogram bucket to the next, 1.1 gives about 8 buckets per doubling
+const nativeHistogramBucketFactor = 1.1
+
+// nativeHistogramMaxBuckets limits the buckets of a native histogram, the
+// resolution is reduced instead of growing beyond it
+const nativeHistogramMaxBuckets = 160
+
+// Ticket duration histograms, created by initializeMetrics in the
+// configured duration unit
+var (
+	supportPalTicketResolutionDuration    *prometheus.HistogramVec
+	supportPalTicketFirstResponseDuration *prometheus.HistogramVec
+)
+
+// newDurationHistogram returns a histogram of durations in the configured
+// unit with the given classic buckets, and with native buckets as well when
+// config.NativeHistograms is set
+func newDurationHistogram(base, help string, buckets []time.Duration, labels ...string) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{
+		Name: durationName(base),
+		Help: durationHelp(help),
+	}
+	for _, bound := range buckets {
+		opts.Buckets = append(opts.Buckets, bound.Seconds()*durationUnit().perSecond)
+	}
+	if config.NativeHistograms {
+		opts.NativeHistogramBucketFactor = nativeHistogramBucketFactor
+		opts.NativeHistogramMaxBucketNumber = nativeHistogramMaxBuckets
+	}
+
+	return promauto.NewHistogramVec(opts, labels)
+}
+
+// initializeDurationHistograms creates the ticket duration histograms
+func initializeDurationHistograms() {
+	supportPalTicketResolutionDuration = newDurationHistogram("supportpal_ticket_resolution_duration",
+		"Time from the creation of tickets to their resolution", statusDurationBuckets, "department", "priority")
+	supportPalTicketFirstResponseDuration = newDurationHistogram("supportpal_ticket_first_response_duration",
+		"Time from the creation of tickets to the first operator response", firstResponseBuckets, "department", "priority")
+}
+
+// observeDuration observes the time from start to end, unix timestamps in
+// seconds, in h unless the histograms are not initialized or end is before
+// start
+func observeDuration(h *prometheus.HistogramVec, start, end int64, labelValues ...string) {
+	if h == nil || end < start {
+		return
+	}
+
+	h.WithLabelValues(labelValues...).Observe(float64(end-start) * durationUnit().perSecond)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelPool recycles the label maps of built tickets across cycles, which
+// would otherwise allocate one map per ticket and cycle
+var labelPool = sync.Pool{
+	New: func() any { return make(prometheus.Labels, len(CommonLabels)+8) },
+}
+
+// newTicketLabels returns an empty label map from labelPool
+func newTicketLabels() prometheus.Labels {
+	return labelPool.Get().(prometheus.Labels)
+}
+
+// releaseLabels returns labels to labelPool. They must not be used afterwards.
+func releaseLabels(labels prometheus.Labels) {
+	clear(labels)
+	labelPool.Put(labels)
+}
+
+// pinnedName returns the name pinned to id in names, or the normalized name
+// given by SupportPal if none is
+func pinnedName(names map[int]string, id int, name string) string {
+	if pinned, ok := names[id]; ok {
+		return pinned
+	}
+	return normalizeName(name)
+}
+
+// catalogName returns the label value of a status, priority or department
+// of the catalog collector, the same as in the ticket metrics
+func catalogName(object string, id int, name string) string {
+	switch object {
+	case "status":
+		return pinnedName(config.StatusNames, id, name)
+	case "priority":
+		return pinnedName(config.PriorityNames, id, name)
+	default:
+		return normalizeName(name)
+	}
+}
+
+// buildTicket enriches a ticket with its organization, custom fields and
+// messages and returns its labels and series. The labels are taken from
+// labelPool and released by the caller once added to a snapshot.
+func buildTicket(ticket *supportpal.Ticket) (prometheus.Labels, *ticketSeries) {
+	status := pinnedName(config.StatusNames, ticket.Status.ID, ticket.Status.Name)
+	priority := pinnedName(config.PriorityNames, ticket.Priority.ID, ticket.Priority.Name)
+
+	labels := newTicketLabels()
+	labels["status"] = status
+	labels["priority"] = priority
+	if config.StatusIDLabels {
+		labels["status_id"] = strconv.Itoa(ticket.Status.ID)
+		labels["priority_id"] = strconv.Itoa(ticket.Priority.ID)
+	}
+	labels["user"] = normalizeName(ticket.User.FormattedName)
+	subjectName, subject := subjectLabel(ticket.Subject)
+	labels[subjectName] = subject
+	labels["ticket_url"] = ticket.OperatorURL
+	labels["frontend_url"] = ticket.FrontendURL
+	labels["in_maintenance"] = strconv.FormatBool(inMaintenance(time.Unix(ticket.CreatedAt, 0)))
+	labels["channel"] = normalizeName(ticket.Channel)
+	if config.TicketIDLabel && !config.TicketInfo {
+		labels["ticket_id"] = strconv.Itoa(ticket.ID)
+	}
+
+	organization := ""
+	if ticket.User.OrganizationID != 0 {
+		org, err := lastKnown.organization(ticket.User.OrganizationID)
+
+		switch {
+		case errors.Is(err, supportpal.ErrNotFound):
+			slog.Debug("organization was deleted", "ticket_id", ticket.ID, "organization_id", ticket.User.OrganizationID)
+			labels["client"] = config.DeletedOrganizationLabel
+		case err != nil:
+			slog.Error("failed to get organization", "ticket_id", ticket.ID, "organization_id", ticket.User.OrganizationID, "err", err)
+			labels["client"] = unknownOrganizationLabel
+		default:
+			organization = org.Data.Name
+			labels["client"] = organizationLabel(organization)
+			for id, name := range config.OrganizationFieldLabels {
+				if value, ok := org.Data.Field(id); ok {
+					labels[name] = value
+				}
+			}
+		}
+
+		if config.OrganizationIDLabel {
+			labels["organization_id"] = strconv.Itoa(ticket.User.OrganizationID)
+		}
+	}
+
+	providerKey := LabelKey{Kind: labelKeyOrganization, ID: ticket.User.OrganizationID}
+	if config.LabelProviderKey.Kind == labelKeyField {
+		providerKey = LabelKey{}
+	}
+
+	dates := make(map[string]int64)
+	values := make(map[string]float64)
+	for _, customField := range ticket.CustomFields {
+		cField, err := lastKnown.customField(customField.FieldID)
+
+		if errors.Is(err, supportpal.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to get custom field", "ticket_id", ticket.ID, "field_id", customField.FieldID, "err", err)
+			continue
+		}
+
+		if config.LabelProviderKey.Kind == labelKeyField && customField.FieldID == config.LabelProviderKey.ID {
+			providerKey = LabelKey{Kind: labelKeyField, ID: customField.FieldID, Value: renderCustomField(cField, string(customField.Value))}
+		}
+
+		name := customFieldLabel(cField)
+
+		if isNumericField(cField) {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(string(customField.Value)), 64); err == nil {
+				values[name] = v
+			}
+			continue
+		}
+
+		if !isLabelField(cField) {
+			continue
+		}
+
+		labels[name] = renderCustomField(cField, string(customField.Value))
+
+		if cField.Data.Type == fieldTypeDate {
+			if t, ok := parseFieldDate(string(customField.Value)); ok {
+				dates[name] = t.Unix()
+			}
+		}
+	}
+
+	addProviderLabels(labels, providerKey)
+
+	series := &ticketSeries{
+		ID:             ticket.ID,
+		Status:         status,
+		Department:     normalizeName(ticket.Department.Name),
+		DepartmentID:   ticket.Department.ID,
+		Priority:       priority,
+		Channel:        normalizeName(ticket.Channel),
+		Organization:   organization,
+		OrganizationID: ticket.User.OrganizationID,
+		SenderDomain:   senderDomain(ticket.User.Email),
+		Sender:         senderKey(ticket.User.Email),
+		URL:            ticket.OperatorURL,
+		Created:        ticket.CreatedAt,
+		Updated:        ticket.UpdatedAt,
+		Deleted:        ticket.DeletedAt,
+		Resolved:       ticket.ResolvedTime,
+		Due:            ticket.DueTime,
+		Dates:          dates,
+		Values:         values,
+	}
+
+	if series.Updated == 0 {
+		series.Updated = ticket.CreatedAt
+	}
+
+	business := businessCalendarOf(ticket.Department.ID)
+	if business != nil && series.open() {
+		now := time.Now()
+		series.BusinessAge = int64(business.duration(time.Unix(series.Created, 0), now).Seconds())
+		series.BusinessAgeAt = now.Unix()
+	}
+
+	if config.FetchMessages {
+		messages, err := source.TicketMessages(ticket)
+		if err != nil {
+			slog.Error("failed to list messages", "ticket_id", ticket.ID, "err", err)
+			collectionError("messages")
+			keepMessageFields(labels, series)
+		} else {
+			series.MessagesFetched = true
+			if first := firstResponse(ticket, messages); first != nil {
+				series.FirstResponse = first.CreatedAt
+				series.FirstResponder = operatorName(first.User.FormattedName)
+			}
+			series.LastResponse = lastResponse(messages)
+			series.Replies = replyCount(messages)
+			if config.ResolvedBy == resolvedByLastReply && series.Resolved != 0 {
+				series.ResolvedBy = resolvingOperator(messages, series.Resolved)
+			}
+			labels["last_reply_by"] = lastReplier(messages)
+			if business != nil && series.FirstResponse != 0 {
+				series.FirstResponseBusiness = int64(business.duration(
+					time.Unix(series.Created, 0), time.Unix(series.FirstResponse, 0)).Seconds())
+			}
+			if config.SLAPause && series.open() {
+				pauseSLA(series, business, messages)
+			}
+			series.messages = messages
+		}
+	}
+
+	if config.FetchReassignments && series.open() {
+		fetchReassignments(ticket, series)
+	}
+
+	series.WaitingOnCustomer = waitingOnCustomer(ticket, labels["last_reply_by"])
+	if config.SLAPause && series.open() && series.CustomerWaitSince == 0 && slices.Contains(config.CustomerStatusIDs, ticket.Status.ID) {
+		// the API has no status history, the status was set at the latest update at most
+		series.CustomerWaitSince = series.Updated
+	}
+
+	for _, operator := range ticket.Assigned {
+		series.Assigned = append(series.Assigned, operatorName(operator.FormattedName))
+	}
+	resolvedBy(series)
+
+	for _, tag := range ticket.Tags {
+		series.Tags = append(series.Tags, normalizeName(tag.Name))
+	}
+	sort.Strings(series.Tags)
+
+	if config.TagsLabel {
+		labels["tags"] = strings.Join(series.Tags, ",")
+	}
+
+	if config.AssignedLabel {
+		labels["assigned"] = strconv.FormatBool(len(series.Assigned) > 0)
+	}
+
+	rewriteTicket(labels, series)
+	anonymizeTicket(labels, series)
+	series.Client = labels["client"]
+
+	series.Hidden = closedBefore(series, time.Now().Add(-config.ClosedGracePeriod)) || trashedExcluded(series)
+
+	return labels, series
+}
+
+// closedBefore reports whether the ticket was resolved or deleted before
+// cutoff. It is always false without grace period.
+func closedBefore(series *ticketSeries, cutoff time.Time) bool {
+	if config.ClosedGracePeriod == 0 {
+		return false
+	}
+
+	closed := series.Deleted
+	if closed == 0 {
+		closed = series.Resolved
+	}
+
+	return closed != 0 && closed < cutoff.Unix()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ticketDetails is a ticket of the current snapshot as served by /api/tickets
+type ticketDetails struct {
+	ID           int               `json:"id"`
+	Status       string            `json:"status"`
+	Priority     string            `json:"priority,omitempty"`
+	Department   string            `json:"department"`
+	Channel      string            `json:"channel"`
+	Organization string            `json:"organization,omitempty"`
+	Assigned     []string          `json:"assigned"`
+	Tags         []string          `json:"tags"`
+	URL          string            `json:"url,omitempty"`
+	Created      string            `json:"created"`
+	Updated      string            `json:"updated"`
+	Resolved     string            `json:"resolved,omitempty"`
+	Deleted      string            `json:"deleted,omitempty"`
+	Due          string            `json:"due,omitempty"`
+	Labels       map[string]string `json:"labels"`
+}
+
+// formatUnix returns a unix timestamp as RFC 3339 time, empty for 0
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+// listTicketDetails returns the tickets of s exported as per ticket series
+// whose status and department are in the given lists, any if a list is
+// empty. Values are compared case-insensitively.
+func listTicketDetails(s *snapshot, statuses, departments []string) []*ticketDetails {
+	matches := func(values []string, v string) bool {
+		return len(values) == 0 || slices.ContainsFunc(values, func(value string) bool { return strings.EqualFold(value, v) })
+	}
+
+	tickets := []*ticketDetails{}
+	for _, series := range s.Series {
+		if series.Hidden || !matches(statuses, series.Status) || !matches(departments, series.Department) {
+			continue
+		}
+
+		labels := make(map[string]string)
+		for i, name := range s.schema(series) {
+			if i < len(series.LabelValues) && series.LabelValues[i] != "" {
+				labels[name] = series.LabelValues[i]
+			}
+		}
+
+		tickets = append(tickets, &ticketDetails{
+			ID:           series.ID,
+			Status:       series.Status,
+			Priority:     series.Priority,
+			Department:   series.Department,
+			Channel:      series.Channel,
+			Organization: series.Organization,
+			Assigned:     append([]string{}, series.Assigned...),
+			Tags:         append([]string{}, series.Tags...),
+			URL:          series.URL,
+			Created:      formatUnix(series.Created),
+			Updated:      formatUnix(series.Updated),
+			Resolved:     formatUnix(series.Resolved),
+			Deleted:      formatUnix(series.Deleted),
+			Due:          formatUnix(series.Due),
+			Labels:       labels,
+		})
+	}
+
+	return tickets
+}
+
+// ticketsHandler serves the tickets of the current snapshot as JSON,
+// filtered by the comma separated status and department query parameters
+func ticketsHandler(w http.ResponseWriter, r *http.Request) {
+	s := supportPalTickets.current.Load()
+	if s == nil {
+		http.Error(w, "no data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	tickets := listTicketDetails(s, splitList(query.Get("status")), splitList(query.Get("department")))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(collectionInterval.Seconds())))
+	w.Header().Set("Last-Modified", s.CollectedAt.UTC().Format(http.TimeFormat))
+
+	if err := json.NewEncoder(w).Encode(tickets); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxBufferedSpans limits the spans kept between two exports, further spans
+// are dropped
+const maxBufferedSpans = 10000
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+// traceSpan is an ended span waiting to be exported
+type traceSpan struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	start, end time.Time
+	attributes map[string]string
+	err        error
+}
+
+// otlpTracer records a trace of every collection cycle, with the ticket pages
+// and API requests of the cycle as its children, and exports it with OTLP
+// over HTTP once the cycle ended. Spans outside of cycles, e.g. of webhook
+// updates, are traces of their own exported with the next cycle.
+type otlpTracer struct {
+	endpoint string
+	headers  map[string]string
+	resource map[string]string
+	client   *http.Client
+
+	mu sync.Mutex
+	// cycle is the span of the running cycle, nil between cycles
+	cycle   *traceSpan
+	spans   []*traceSpan
+	dropped int
+}
+
+// tracer is the OTLP tracer, nil unless --otlp.traces-endpoint is set
+var tracer *otlpTracer
+
+// newOTLPTracer returns the tracer of the configuration
+func newOTLPTracer() *otlpTracer {
+	return &otlpTracer{
+		endpoint: config.OTLPTracesEndpoint,
+		headers:  config.OTLPHeaders,
+		resource: otlpResource(),
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+}
+
+// clientTracer returns the tracer of the API client, nil without tracing
+func clientTracer() supportpal.Tracer {
+	if tracer == nil {
+		return nil
+	}
+	return tracer
+}
+
+// newSpanID returns a random span ID
+func newSpanID() (id [8]byte) {
+	binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	return id
+}
+
+// newTraceID returns a random trace ID
+func newTraceID() (id [16]byte) {
+	binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+	binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	return id
+}
+
+// startCycle starts the span of a collection cycle
+func (t *otlpTracer) startCycle() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cycle = &traceSpan{traceID: newTraceID(), spanID: newSpanID(), name: "collection cycle", start: time.Now()}
+}
+
+// endCycle ends the span of the running collection cycle and exports the
+// buffered spans. Failed exports are logged and the spans dropped.
+func (t *otlpTracer) endCycle(attributes map[string]string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if t.cycle != nil {
+		t.cycle.end = time.Now()
+		t.cycle.attributes = attributes
+		t.spans = append(t.spans, t.cycle)
+		t.cycle = nil
+	}
+	spans, dropped := t.spans, t.dropped
+	t.spans, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("dropped trace spans beyond the buffer limit", "dropped", dropped, "limit", maxBufferedSpans)
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	if err := otlpPost(t.client, t.endpoint, t.headers, otlpTraceRequest(spans, t.resource)); err != nil {
+		slog.Warn("failed to export traces", "spans", len(spans), "err", err)
+	}
+}
+
+// Span implements supportpal.Tracer. Spans ending during a cycle become
+// children of the cycle span.
+func (t *otlpTracer) Span(name string, start, end time.Time, attributes map[string]string, err error) {
+	span := &traceSpan{spanID: newSpanID(), name: name, start: start, end: end, attributes: attributes, err: err}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.spans) >= maxBufferedSpans {
+		t.dropped++
+		return
+	}
+
+	if t.cycle != nil {
+		span.traceID = t.cycle.traceID
+		span.parentID = t.cycle.spanID
+	} else {
+		span.traceID = newTraceID()
+	}
+	t.spans = append(t.spans, span)
+}
+
+// span records a span of the exporter itself that started at start and ended now
+func (t *otlpTracer) span(name string, start time.Time, attributes map[string]string) {
+	if t == nil {
+		return
+	}
+
+	t.Span(name, start, time.Now(), attributes, nil)
+}
+
+// otlpTraceRequest encodes the spans as OTLP ExportTraceServiceRequest
+// protobuf. Spans of API requests are client spans, the others internal.
+func otlpTraceRequest(spans []*traceSpan, resource map[string]string) []byte {
+	var scopeSpans []byte
+	scopeSpans = appendMessage(scopeSpans, 1, otlpScope())
+	for _, span := range spans {
+		scopeSpans = appendMessage(scopeSpans, 2, otlpSpan(span))
+	}
+
+	var resourceSpans []byte
+	resourceSpans = appendMessage(resourceSpans, 1, otlpResourceMessage(resource))
+	resourceSpans = appendMessage(resourceSpans, 2, scopeSpans)
+
+	return appendMessage(nil, 1, resourceSpans)
+}
+
+// otlpSpan encodes a Span message
+func otlpSpan(span *traceSpan) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, span.traceID[:])
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, span.spanID[:])
+	if span.parentID != [8]byte{} {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, span.parentID[:])
+	}
+	b = protowire.AppendTag(b, 5, protowire.BytesType)
+	b = protowire.AppendString(b, span.name)
+
+	kind := otlpSpanKindInternal
+	if _, ok := span.attributes["http.request.method"]; ok {
+		kind = otlpSpanKindClient
+	}
+	b = protowire.AppendTag(b, 6, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(kind))
+
+	b = appendFixed64(b, 7, uint64(span.start.UnixNano()))
+	b = appendFixed64(b, 8, uint64(span.end.UnixNano()))
+
+	for _, key := range sortedKeys(span.attributes) {
+		b = appendMessage(b, 9, otlpAttribute(key, span.attributes[key]))
+	}
+
+	if span.err != nil {
+		var status []byte
+		status = protowire.AppendTag(status, 2, protowire.BytesType)
+		status = protowire.AppendString(status, span.err.Error())
+		status = protowire.AppendTag(status, 3, protowire.VarintType)
+		status = protowire.AppendVarint(status, otlpStatusError)
+		b = appendMessage(b, 15, status)
+	}
+
+	return b
+}
+
+// cycleAttributes returns the attributes of the span of the cycle that just ended
+func cycleAttributes() map[string]string {
+	return map[string]string{"supportpal.collection_errors": strconv.FormatInt(cycleErrors.Load(), 10)}
+}
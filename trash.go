@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// handling of tickets moved to the trash
+const (
+	// trashedInclude exports trashed tickets like any other ticket, the
+	// aggregates still leave them out
+	trashedInclude = "include"
+	// trashedExclude collects trashed tickets for the trash metrics and the
+	// deleted counter only, they are left out of every other metric
+	trashedExclude = "exclude"
+	// trashedDrop does not collect trashed tickets at all
+	trashedDrop = "drop"
+)
+
+// trashedWindow is the trailing window of supportpal_tickets_deleted_recent
+const trashedWindow = 24 * time.Hour
+
+var supportPalTicketsTrashedDesc = prometheus.NewDesc("supportpal_tickets_trashed",
+	"Number of collected tickets of a department in the trash", []string{"department"}, nil)
+
+var supportPalTicketsDeletedRecentDesc = prometheus.NewDesc("supportpal_tickets_deleted_recent",
+	"Number of collected tickets of a department moved to the trash within the last 24 hours", []string{"department"}, nil)
+
+var supportPalTicketsPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_tickets_purged_total",
+	Help: "Number of tickets that disappeared between collection cycles because they were deleted permanently",
+}, []string{"department"})
+
+// trashedExcluded reports whether the ticket is in the trash and left out of
+// the metrics other than the trash metrics
+func trashedExcluded(series *ticketSeries) bool {
+	return config.Trashed == trashedExclude && series.Deleted != 0
+}
+
+// collectTrash sends the number of trashed tickets and of tickets trashed
+// within the trash window per department, 0 for the other departments of s
+func collectTrash(ch chan<- prometheus.Metric, s *snapshot, now time.Time) {
+	if config.Trashed == trashedDrop {
+		return
+	}
+
+	trashed := make(labelCounts)
+	recent := make(labelCounts)
+	since := now.Add(-trashedWindow).Unix()
+
+	for _, series := range s.Series {
+		var t, r float64
+		if series.Deleted != 0 {
+			t = 1
+		}
+		if series.Deleted != 0 && series.Deleted >= since {
+			r = 1
+		}
+		trashed.add(t, series.Department)
+		recent.add(r, series.Department)
+	}
+
+	trashed.emit(ch, supportPalTicketsTrashedDesc, prometheus.GaugeValue)
+	recent.emit(ch, supportPalTicketsDeletedRecentDesc, prometheus.GaugeValue)
+}
+
+// countPurged counts the tickets of the previous snapshot missing from the
+// current one that the API no longer knows. Tickets that left the sync
+// window or the ticket filters still exist and are not counted, tickets that
+// could not be checked are logged and not counted either.
+func countPurged(previous, current *snapshot) {
+	collected := make(map[int]bool, len(current.Series))
+	for _, series := range current.Series {
+		collected[series.ID] = true
+	}
+
+	for _, series := range previous.Series {
+		if collected[series.ID] {
+			continue
+		}
+
+		_, err := source.GetTicket(series.ID)
+		if errors.Is(err, supportpal.ErrNotFound) {
+			incTicketCounter(supportPalTicketsPurged.WithLabelValues(series.Department), series.ID, series.URL)
+			continue
+		}
+		if err != nil {
+			slog.Warn("failed to check whether a ticket was deleted", "id", series.ID, "err", err)
+		}
+	}
+}
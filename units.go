@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// metricUnit describes how values of a unit are named, scaled and documented
+type metricUnit struct {
+	// suffix is appended to the metric name
+	suffix string
+	// help is appended to the HELP string
+	help string
+	// perSecond is the number of units in one second
+	perSecond float64
+}
+
+// units is the single source of truth for the units used by the exporter
+var units = map[string]metricUnit{
+	"seconds":           {suffix: "_seconds", help: "in seconds", perSecond: 1},
+	"milliseconds":      {suffix: "_milliseconds", help: "in milliseconds", perSecond: 1000},
+	"timestamp_seconds": {suffix: "", help: "unix timestamp in seconds", perSecond: 1},
+}
+
+// durationUnit returns the unit used for duration metrics
+func durationUnit() metricUnit {
+	return units[config.DurationUnit]
+}
+
+// durationName returns the name of the duration metric base in the configured unit
+func durationName(base string) string {
+	return base + durationUnit().suffix
+}
+
+// unitHelp documents the unit u in the help string
+func unitHelp(help string, u metricUnit) string {
+	return fmt.Sprintf("%s (%s)", help, u.help)
+}
+
+// durationHelp documents the configured duration unit in the help string
+func durationHelp(help string) string {
+	return unitHelp(help, durationUnit())
+}
+
+// durationValue converts d to the configured unit rounded to the configured precision
+func durationValue(d time.Duration) float64 {
+	v := d.Seconds() * durationUnit().perSecond
+	if config.DurationPrecision < 0 {
+		return v
+	}
+
+	p := math.Pow10(config.DurationPrecision)
+	return math.Round(v*p) / p
+}
+
+// validateDurationUnit checks that unit is a known duration unit
+func validateDurationUnit(unit string) error {
+	if unit != "seconds" && unit != "milliseconds" {
+		return fmt.Errorf("invalid duration unit %q, expected seconds or milliseconds", unit)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// version is the exporter version, set at build time with
+// -ldflags "-X main.version=<version>"
+var version = ""
+
+// revision is the VCS revision the exporter was built from, set at build
+// time with -ldflags "-X main.revision=<commit>"
+var revision = ""
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "supportpal_exporter_build_info",
+	Help: "Version, VCS revision and Go version the exporter was built with, always 1",
+	ConstLabels: prometheus.Labels{
+		"version":    exporterVersion(),
+		"revision":   exporterRevision(),
+		"go_version": runtime.Version(),
+	},
+}, func() float64 { return 1 })
+
+// exporterVersion returns the build time version, falling back to the module
+// version recorded by go install
+func exporterVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "unknown"
+}
+
+// exporterRevision returns the build time revision, falling back to the
+// revision recorded by go build in a VCS checkout
+func exporterRevision() string {
+	if revision != "" {
+		return revision
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// versionString returns the version line printed by --version
+func versionString() string {
+	return fmt.Sprintf("supportpal-prom-exporter %s (revision %s, %s)", exporterVersion(), exporterRevision(), runtime.Version())
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// webConfig is the web configuration file shared by Prometheus exporters,
+// supporting TLS and basic authentication of the HTTP endpoints
+type webConfig struct {
+	TLSServerConfig *tlsServerConfig `yaml:"tls_server_config"`
+	// BasicAuthUsers maps user names to bcrypt hashed passwords
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// tlsServerConfig holds the TLS settings of the web configuration file
+type tlsServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// loadWebConfig reads a web configuration file
+func loadWebConfig(path string) (*webConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c webConfig
+	if err := yaml.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &c, nil
+}
+
+// tlsConfig returns the TLS configuration of the HTTP server, nil if TLS is
+// not configured. A client CA without client auth type requires and verifies
+// client certificates.
+func (c *webConfig) tlsConfig() (*tls.Config, error) {
+	t := c.TLSServerConfig
+	if t == nil {
+		return nil, nil
+	}
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, errors.New("tls_server_config requires cert_file and key_file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", t.ClientCAFile)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if t.ClientAuthType != "" {
+		authType, ok := clientAuthTypes[t.ClientAuthType]
+		if !ok {
+			return nil, fmt.Errorf("invalid client_auth_type %q", t.ClientAuthType)
+		}
+		cfg.ClientAuth = authType
+	}
+
+	return cfg, nil
+}
+
+// authenticate wraps next with basic authentication if users are configured.
+// Requests to paths in skip are passed through, they authenticate themselves.
+func (c *webConfig) authenticate(next http.Handler, skip ...string) http.Handler {
+	if len(c.BasicAuthUsers) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range skip {
+			if r.URL.Path == path {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		user, password, ok := r.BasicAuth()
+		hash, known := c.BasicAuthUsers[user]
+		if !ok || !known || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="supportpal-prom-exporter"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
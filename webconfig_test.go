@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeCertificate writes a self-signed certificate for localhost and its
+// key to dir and returns their paths
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestWebConfigBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "web.yml")
+	if err := os.WriteFile(path, []byte("basic_auth_users:\n  prometheus: "+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadWebConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := c.authenticate(ok, "/webhook")
+	for _, tt := range []struct {
+		path, user, password string
+		want                 int
+	}{
+		{"/metrics", "", "", http.StatusUnauthorized},
+		{"/metrics", "prometheus", "wrong", http.StatusUnauthorized},
+		{"/metrics", "grafana", "secret", http.StatusUnauthorized},
+		{"/metrics", "prometheus", "secret", http.StatusOK},
+		// webhooks authenticate with their own secret
+		{"/webhook", "", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s as %q/%q: status %d, want %d", tt.path, tt.user, tt.password, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tt.path)
+		}
+	}
+
+	// without users every request passes
+	rec := httptest.NewRecorder()
+	(&webConfig{}).authenticate(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status without users %d, want 200", rec.Code)
+	}
+}
+
+func TestWebConfigTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir)
+
+	if cfg, err := (&webConfig{}).tlsConfig(); cfg != nil || err != nil {
+		t.Errorf("tlsConfig() without TLS = %v, %v, want nil", cfg, err)
+	}
+	for name, c := range map[string]*tlsServerConfig{
+		"missing key":      {CertFile: certFile},
+		"invalid CA":       {CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+		"invalid authtype": {CertFile: certFile, KeyFile: keyFile, ClientAuthType: "Always"},
+	} {
+		if _, err := (&webConfig{TLSServerConfig: c}).tlsConfig(); err == nil {
+			t.Errorf("%s: tlsConfig() succeeded", name)
+		}
+	}
+
+	// a client CA requires client certificates unless the auth type says
+	// otherwise
+	c := &webConfig{TLSServerConfig: &tlsServerConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}}
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("client auth %v, min version %x", cfg.ClientAuth, cfg.MinVersion)
+	}
+	c.TLSServerConfig.ClientAuthType = "VerifyClientCertIfGiven"
+	if cfg, err := c.tlsConfig(); err != nil || cfg.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("client auth with client_auth_type = %v, %v", cfg, err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	ca, _ := os.ReadFile(certFile)
+	pool.AppendCertsFromPEM(ca)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{{"without client certificate", nil, false}, {"with client certificate", []tls.Certificate{cert}, true}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost", Certificates: tt.certs}}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
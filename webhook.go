@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoseCarlosGarcia95/supportpal-prom-exporter/pkg/supportpal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// webhookQueueSize is the number of ticket updates waiting to be applied
+// before further webhook events are dropped until the next reconciliation
+const webhookQueueSize = 256
+
+// webhookMaxBody is the largest webhook payload accepted
+const webhookMaxBody = 1 << 20
+
+// webhookReplayWindow is how long the signature of an accepted event is kept
+// to recognize the same event sent again
+const webhookReplayWindow = 10 * time.Minute
+
+var supportPalWebhookEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "supportpal_webhook_events_total",
+	Help: "Number of received webhook events by result (applied, removed, failed, dropped, rejected, replayed, standby)",
+}, []string{"result"})
+
+// webhookQueue holds the IDs of tickets to refresh
+var webhookQueue = make(chan int, webhookQueueSize)
+
+// webhookEvent is the payload of a SupportPal ticket event. The ticket ID is
+// read from ticket_id or from the id of the ticket in data.
+type webhookEvent struct {
+	Event    string `json:"event"`
+	TicketID int    `json:"ticket_id"`
+	Data     struct {
+		ID int `json:"id"`
+	} `json:"data"`
+}
+
+// replayGuard remembers the signatures of accepted events within
+// webhookReplayWindow
+type replayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var webhookReplays = &replayGuard{seen: make(map[string]time.Time)}
+
+// replayed reports whether an event with signature was accepted within the
+// window before now and records it otherwise
+func (g *replayGuard) replayed(signature string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for s, accepted := range g.seen {
+		if now.Sub(accepted) > webhookReplayWindow {
+			delete(g.seen, s)
+		}
+	}
+
+	if _, ok := g.seen[signature]; ok {
+		return true
+	}
+	g.seen[signature] = now
+	return false
+}
+
+// validSignature reports whether signature is the hex encoded HMAC-SHA256 of
+// body keyed with the webhook secret, optionally prefixed with "sha256="
+func validSignature(body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.WebhookSecret))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// webhookHandler accepts signed ticket events and queues the ticket for a
+// refresh. Events are answered before they are applied.
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	signature := r.Header.Get("X-Signature")
+	if !validSignature(body, signature) {
+		supportPalWebhookEvents.WithLabelValues("rejected").Inc()
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event webhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		supportPalWebhookEvents.WithLabelValues("rejected").Inc()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := event.TicketID
+	if id == 0 {
+		id = event.Data.ID
+	}
+	if id == 0 {
+		supportPalWebhookEvents.WithLabelValues("rejected").Inc()
+		http.Error(w, "event holds no ticket id", http.StatusBadRequest)
+		return
+	}
+
+	// the ticket is refreshed already, a replayed event need not queue it
+	// again
+	if webhookReplays.replayed(strings.ToLower(strings.TrimPrefix(signature, "sha256=")), time.Now()) {
+		supportPalWebhookEvents.WithLabelValues("replayed").Inc()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	select {
+	case webhookQueue <- id:
+		slog.Debug("queued webhook event", "event", event.Event, "ticket_id", id)
+	default:
+		supportPalWebhookEvents.WithLabelValues("dropped").Inc()
+		slog.Warn("webhook queue is full, dropping event", "event", event.Event, "ticket_id", id)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// processWebhooks applies the queued ticket refreshes one at a time
+func processWebhooks() {
+	for id := range webhookQueue {
+		// the leader receives the events as well or reconciles them
+		if !leading.Load() {
+			supportPalWebhookEvents.WithLabelValues("standby").Inc()
+			continue
+		}
+		supportPalWebhookEvents.WithLabelValues(refreshTicket(id)).Inc()
+	}
+}
+
+// refreshTicket fetches a single ticket and replaces it in the current
+// snapshot, or removes it if it no longer exists or is out of bounds. It
+// returns the result counted in supportpal_webhook_events_total.
+func refreshTicket(id int) string {
+	ticket, err := source.GetTicket(id)
+	if err != nil && !errors.Is(err, supportpal.ErrNotFound) {
+		slog.Error("failed to refresh ticket", "ticket_id", id, "err", err)
+		return "failed"
+	}
+
+	if ticket == nil || !ticketInSyncBound(ticket) || !ticketSelected(ticket) || ticketExpired(ticket) {
+		supportPalTickets.update(func(s *snapshot) *snapshot {
+			return s.replaceTicket(id, nil, nil)
+		})
+		for _, j := range config.Jobs {
+			j.replaceTicket(ticket, id, nil, nil)
+		}
+		return "removed"
+	}
+
+	labels, series := buildTicket(ticket)
+	ticketLifecycle.observe(series, labels["priority"])
+	operatorReplies.observe(series)
+
+	// the snapshots truncate copies of the labels, every one its own
+	supportPalTickets.update(func(s *snapshot) *snapshot {
+		if old := s.ticket(id); old != nil {
+			series.Rated, series.Rating, series.RatedOperator = old.Rated, old.Rating, old.RatedOperator
+		}
+		copied := copyLabels(labels)
+		defer releaseLabels(copied)
+		return s.replaceTicket(id, copied, series)
+	})
+	for _, j := range config.Jobs {
+		j.replaceTicket(ticket, id, labels, series)
+	}
+	releaseLabels(labels)
+
+	return "applied"
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// sign returns the signature header value of body keyed with secret
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	config = Config{WebhookSecret: "secret"}
+	webhookReplays = &replayGuard{seen: make(map[string]time.Time)}
+	t.Cleanup(func() { config = Config{} })
+
+	body := `{"event":"ticket.updated","ticket_id":42}`
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+		queued    bool
+	}{
+		{"valid", sign("secret", body), http.StatusAccepted, true},
+		{"replay", sign("secret", body), http.StatusAccepted, false},
+		{"bad signature", sign("other", body), http.StatusUnauthorized, false},
+		{"malformed signature", "sha256=zz", http.StatusUnauthorized, false},
+		{"missing header", "", http.StatusUnauthorized, false},
+	}
+
+	replayed := testutil.ToFloat64(supportPalWebhookEvents.WithLabelValues("replayed"))
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		if tt.signature != "" {
+			req.Header.Set("X-Signature", tt.signature)
+		}
+		rec := httptest.NewRecorder()
+		webhookHandler(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+
+		select {
+		case id := <-webhookQueue:
+			if !tt.queued || id != 42 {
+				t.Errorf("%s: queued ticket %d", tt.name, id)
+			}
+		default:
+			if tt.queued {
+				t.Errorf("%s: ticket was not queued", tt.name)
+			}
+		}
+	}
+
+	if got := testutil.ToFloat64(supportPalWebhookEvents.WithLabelValues("replayed")) - replayed; got != 1 {
+		t.Errorf("counted %v replayed events, want 1", got)
+	}
+
+	// a signature is forgotten after the replay window
+	if webhookReplays.replayed("abc", time.Now().Add(-2*webhookReplayWindow)) || webhookReplays.replayed("abc", time.Now()) {
+		t.Error("signature outside the replay window was taken as replay")
+	}
+}
+
+func TestRefreshTicket(t *testing.T) {
+	config = Config{}
+	t.Cleanup(func() { config = Config{} })
+
+	fixtures := newFixtureSource(t)
+	source = fixtures
+	tickets, err := fixtures.FetchTickets(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	supportPalTickets.store(buildSnapshot(t, tickets))
+
+	updated := *fixtures.tickets[0]
+	updated.Subject = "Website up again"
+	// the fixtures are older than the collection window
+	updated.CreatedAt = time.Now().Unix()
+	fixtures.tickets[0] = &updated
+
+	if result := refreshTicket(updated.ID); result != "applied" {
+		t.Fatalf("refreshTicket(%d) = %q, want applied", updated.ID, result)
+	}
+	s := supportPalTickets.current.Load()
+	if subject := s.labelValue(s.ticket(updated.ID), "subject"); subject != "Website up again" {
+		t.Errorf("subject after the refresh = %q", subject)
+	}
+	if len(s.Series) != len(tickets) {
+		t.Errorf("%d series after the refresh, want %d", len(s.Series), len(tickets))
+	}
+
+	fixtures.tickets = fixtures.tickets[1:]
+	if result := refreshTicket(updated.ID); result != "removed" {
+		t.Fatalf("refreshTicket(%d) of a deleted ticket = %q, want removed", updated.ID, result)
+	}
+	if s := supportPalTickets.current.Load(); s.ticket(updated.ID) != nil || len(s.Series) != len(tickets)-1 {
+		t.Errorf("deleted ticket is still in the snapshot of %d series", len(s.Series))
+	}
+}